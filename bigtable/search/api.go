@@ -0,0 +1,160 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The JSON API mirrors the HTML forms:
+//
+//	POST   /api/docs          {"name": "...", "content": "..."}  adds a document
+//	GET    /api/docs?name=NAME                                   gets a document
+//	DELETE /api/docs?name=NAME                                   deletes a document
+//	GET    /api/search?q=QUERY&n=N                               searches
+
+// apiResult is a search result in the JSON API.
+type apiResult struct {
+	Name    string  `json:"name"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error response of the form {"error": "..."}.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// handleAPIDocs adds, gets or deletes a document, depending on the method.
+func handleAPIDocs(w http.ResponseWriter, r *http.Request, ix *index) {
+	switch r.Method {
+	case http.MethodGet:
+		handleAPIGet(w, r, ix)
+	case http.MethodPost:
+		handleAPIAdd(w, r, ix)
+	case http.MethodDelete:
+		handleAPIDelete(w, r, ix)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "GET, POST and DELETE requests only")
+	}
+}
+
+func handleAPIAdd(w http.ResponseWriter, r *http.Request, ix *index) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	var doc document
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&doc); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if doc.Name == "" || doc.Content == "" {
+		writeJSONError(w, http.StatusBadRequest, "Document name and content are required.")
+		return
+	}
+	if err := ix.put(ctx, doc.Name, doc.Content); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error writing to Bigtable: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"name": doc.Name})
+}
+
+func handleAPIGet(w http.ResponseWriter, r *http.Request, ix *index) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	name := r.FormValue("name")
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "No document name supplied.")
+		return
+	}
+	content, found, err := ix.readDoc(ctx, name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error reading content: "+err.Error())
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Document not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, document{Name: name, Content: content})
+}
+
+func handleAPIDelete(w http.ResponseWriter, r *http.Request, ix *index) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+
+	name := r.FormValue("name")
+	if name == "" {
+		writeJSONError(w, http.StatusBadRequest, "No document name supplied.")
+		return
+	}
+	found, err := ix.remove(ctx, name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error deleting from Bigtable: "+err.Error())
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Document not found.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPISearch returns the best matching documents for the q parameter.
+func handleAPISearch(w http.ResponseWriter, r *http.Request, ix *index) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q := r.FormValue("q")
+	parsed := parseQuery(q)
+	if len(parsed.terms) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Empty query.")
+		return
+	}
+	n := defaultResults
+	if s := r.FormValue("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid number of results.")
+			return
+		}
+	}
+
+	results, err := ix.search(ctx, parsed, n)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error searching: "+err.Error())
+		return
+	}
+	resp := struct {
+		Query   string      `json:"query"`
+		Results []apiResult `json:"results"`
+	}{Query: q, Results: []apiResult{}}
+	for _, res := range results {
+		resp.Results = append(resp.Results, apiResult{Name: res.Title, Score: res.Score, Snippet: res.Snippet})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		if strings.HasPrefix(target, "/api/search") {
+			handleAPISearch(rr, req, ix)
+		} else {
+			handleAPIDocs(rr, req, ix)
+		}
+		return rr
+	}
+
+	if rr := do("POST", "/api/docs", `{"name": "gopher", "content": "The gopher digs a hole."}`); rr.Code != http.StatusCreated {
+		t.Fatalf("add: status %d: %s", rr.Code, rr.Body)
+	}
+
+	rr := do("GET", "/api/docs?name=gopher", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", rr.Code, rr.Body)
+	}
+	var doc document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if want := (document{Name: "gopher", Content: "The gopher digs a hole."}); doc != want {
+		t.Errorf("get = %+v, want %+v", doc, want)
+	}
+
+	rr = do("GET", "/api/search?q=digs+hole", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("search: status %d: %s", rr.Code, rr.Body)
+	}
+	var resp struct {
+		Query   string      `json:"query"`
+		Results []apiResult `json:"results"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Name != "gopher" || resp.Results[0].Score <= 0 {
+		t.Errorf("search results = %+v, want one scored result for gopher", resp.Results)
+	}
+
+	if rr := do("DELETE", "/api/docs?name=gopher", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, tc := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{"GET", "/api/docs?name=gopher", "", http.StatusNotFound},
+		{"DELETE", "/api/docs?name=gopher", "", http.StatusNotFound},
+		{"GET", "/api/docs", "", http.StatusBadRequest},
+		{"POST", "/api/docs", `{"name": "x"}`, http.StatusBadRequest},
+		{"POST", "/api/docs", `not json`, http.StatusBadRequest},
+		{"PUT", "/api/docs", "", http.StatusMethodNotAllowed},
+		{"GET", "/api/search?q=", "", http.StatusBadRequest},
+		{"GET", "/api/search?q=gopher&n=0", "", http.StatusBadRequest},
+	} {
+		rr := do(tc.method, tc.target, tc.body)
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.target, rr.Code, tc.want)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: Content-Type %q, want application/json", tc.method, tc.target, ct)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// copyBatchSize is the number of rows copied in each ApplyBulk call.
+const copyBatchSize = 500
+
+// copyCheckpointColumn returns the column of the stats row, in the
+// destination table, recording the last row copied from src.
+func copyCheckpointColumn(src string) string {
+	return "copy-" + src
+}
+
+// copyTable copies the documents and index from the src table to the dst
+// table in batches of batchSize rows. After each batch is written, the key of
+// the last copied row is recorded in dst, so that if the copy is interrupted,
+// copying from the same table again resumes after that row.
+func copyTable(ctx context.Context, src, dst string, client *bigtable.Client, batchSize int) error {
+	if src == "" || src == dst {
+		return nil
+	}
+
+	// Open the source and destination tables.
+	srcTable := client.Open(src)
+	dstTable := client.Open(dst)
+	checkpoint := copyCheckpointColumn(src)
+
+	// Resume from the checkpoint of a previous copy, if there is one.
+	var start string
+	row, err := dstTable.ReadRow(ctx, statsRow, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(statsColumnFamily), bigtable.ColumnFilter(checkpoint), bigtable.LatestNFilter(1))))
+	if err != nil {
+		return fmt.Errorf("reading checkpoint: %w", err)
+	}
+	if items := row[statsColumnFamily]; len(items) > 0 {
+		// Start just after the last copied row.
+		start = string(items[0].Value) + "\x00"
+	}
+
+	// Create a filter that only accepts the column families we're interested in.
+	filter := bigtable.ChainFilters(
+		bigtable.FamilyFilter(indexColumnFamily+"|"+contentColumnFamily),
+		bigtable.LatestNFilter(1))
+	for {
+		var (
+			rowKeys []string
+			muts    []*bigtable.Mutation
+		)
+		err := srcTable.ReadRows(ctx, bigtable.InfiniteRange(start), func(row bigtable.Row) bool {
+			mut := bigtable.NewMutation()
+			for family, items := range row {
+				for _, item := range items {
+					// Get the column name, excluding the column family name and ':' character.
+					columnWithoutFamily := item.Column[len(family)+1:]
+					mut.Set(family, columnWithoutFamily, bigtable.Now(), item.Value)
+				}
+			}
+			rowKeys = append(rowKeys, row.Key())
+			muts = append(muts, mut)
+			return true
+		}, bigtable.RowFilter(filter), bigtable.LimitRows(int64(batchSize)))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", src, err)
+		}
+		if len(rowKeys) == 0 {
+			break
+		}
+		if err := applyBulk(ctx, dstTable, rowKeys, muts); err != nil {
+			return err
+		}
+
+		last := rowKeys[len(rowKeys)-1]
+		mut := bigtable.NewMutation()
+		mut.Set(statsColumnFamily, checkpoint, bigtable.Now(), []byte(last))
+		if err := dstTable.Apply(ctx, statsRow, mut); err != nil {
+			return fmt.Errorf("writing checkpoint: %w", err)
+		}
+		start = last + "\x00"
+	}
+
+	// The copy is complete, so the next copy from src starts from the beginning.
+	mut := bigtable.NewMutation()
+	mut.DeleteCellsInColumn(statsColumnFamily, checkpoint)
+	if err := dstTable.Apply(ctx, statsRow, mut); err != nil {
+		return fmt.Errorf("clearing checkpoint: %w", err)
+	}
+
+	// The copied documents change the statistics used for ranking.
+	return (&index{table: dstTable}).rebuildStats(ctx)
+}
+
+// handleCopy copies data from one table to another.
+func handleCopy(w http.ResponseWriter, r *http.Request, dst string, client *bigtable.Client) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
+	}
+	src := r.FormValue("name")
+	if src == "" {
+		http.Error(w, "No source table specified.", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := copyTable(ctx, src, dst, client, copyBatchSize); err != nil {
+		http.Error(w, "Failed to copy table, copy again to resume: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "Copied table.\n")
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+)
+
+func TestCopyTable(t *testing.T) {
+	dst, client, adminClient := newTestIndex(t)
+	ctx := context.Background()
+
+	if err := createTable(ctx, adminClient, "source"); err != nil {
+		t.Fatalf("createTable: %v", err)
+	}
+	src := &index{table: client.Open("source")}
+	docs := map[string]string{}
+	for i := 0; i < 7; i++ {
+		docs[fmt.Sprintf("doc%d", i)] = fmt.Sprintf("copied words %d", i)
+	}
+	mustPut(t, src, docs)
+	mustPut(t, dst, map[string]string{"local": "local words"})
+
+	// Pretend an earlier copy was interrupted after doc3.
+	mut := bigtable.NewMutation()
+	mut.Set(statsColumnFamily, copyCheckpointColumn("source"), bigtable.Now(), []byte("doc3"))
+	if err := dst.table.Apply(ctx, statsRow, mut); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTable(ctx, "source", "docindex", client, 2); err != nil {
+		t.Fatalf("copyTable: %v", err)
+	}
+
+	var got []string
+	for name := range docs {
+		if _, found, _ := dst.readDoc(ctx, name); found {
+			got = append(got, name)
+		}
+	}
+	if want := 3; len(got) != want {
+		t.Errorf("resumed copy copied %v, want only doc4 to doc6", got)
+	}
+
+	// The checkpoint is cleared, so copying again copies everything.
+	if err := copyTable(ctx, "source", "docindex", client, 2); err != nil {
+		t.Fatalf("copyTable: %v", err)
+	}
+	results, err := dst.search(ctx, parseQuery("words"), 100)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 8 {
+		t.Errorf("search(words) found %d documents, want 8", len(results))
+	}
+	docCount, words, err := dst.stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	// Digits aren't words, so each copied document has two words.
+	if docCount != 8 || words != 16 {
+		t.Errorf("stats = %d docs, %d words, want 8, 16", docCount, words)
+	}
+}
@@ -0,0 +1,505 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// statsRow holds the collection statistics used for ranking, in the
+	// stats column family.
+	statsRow = "stats"
+
+	// BM25 parameters.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// index stores documents, and an inverted index of their words, in a
+// Bigtable table.
+//
+// Each document is stored in the row named after the document, in the
+// content column family. For each word, the row named after the word has
+// one column per document containing it in the index column family. The
+// cell holds the posting for that document: the document length and the
+// positions of the word in the document. The stats row counts the number
+// of documents and words in the table.
+type index struct {
+	table *bigtable.Table
+}
+
+// posting records where a word occurs in a document.
+//
+// Tables indexed before postings were stored hold empty cells. Those decode
+// to a legacy posting, which counts the word once and has no length or
+// positions, so such documents rank as average-length documents and never
+// match a phrase until they are added again.
+type posting struct {
+	docLen    int   // Number of words in the document, or 0 if unknown.
+	positions []int // Positions of the word in the document, in order.
+}
+
+// tf returns the number of times the word occurs in the document.
+func (p posting) tf() int {
+	if p.docLen == 0 {
+		return 1 // A legacy posting.
+	}
+	return len(p.positions)
+}
+
+// encodePosting encodes p as a sequence of uvarints: the document length,
+// the number of positions and the delta-encoded positions.
+func encodePosting(p posting) []byte {
+	b := binary.AppendUvarint(nil, uint64(p.docLen))
+	b = binary.AppendUvarint(b, uint64(len(p.positions)))
+	prev := 0
+	for _, pos := range p.positions {
+		b = binary.AppendUvarint(b, uint64(pos-prev))
+		prev = pos
+	}
+	return b
+}
+
+// decodePosting decodes a posting encoded by encodePosting, or an empty
+// legacy cell.
+func decodePosting(b []byte) (posting, error) {
+	if len(b) == 0 {
+		return posting{}, nil
+	}
+	next := func() (int, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, errors.New("malformed posting")
+		}
+		b = b[n:]
+		return int(v), nil
+	}
+	var p posting
+	var err error
+	if p.docLen, err = next(); err != nil {
+		return p, err
+	}
+	n, err := next()
+	if err != nil {
+		return p, err
+	}
+	prev := 0
+	for i := 0; i < n; i++ {
+		d, err := next()
+		if err != nil {
+			return p, err
+		}
+		prev += d
+		p.positions = append(p.positions, prev)
+	}
+	return p, nil
+}
+
+// tokens splits a string into lowercase words, in order.
+// This is very simple, it's not a good tokenization function.
+func tokens(s string) []string {
+	f := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	for i, word := range f {
+		f[i] = strings.ToLower(word)
+	}
+	return f
+}
+
+// termPositions maps each distinct word in words to its positions.
+func termPositions(words []string) map[string][]int {
+	terms := make(map[string][]int)
+	for i, word := range words {
+		terms[word] = append(terms[word], i)
+	}
+	return terms
+}
+
+// readDoc returns the content of the named document.
+func (ix *index) readDoc(ctx context.Context, name string) (content string, found bool, err error) {
+	row, err := ix.table.ReadRow(ctx, name, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(contentColumnFamily), bigtable.LatestNFilter(1))))
+	if err != nil {
+		return "", false, err
+	}
+	c := row[contentColumnFamily]
+	if len(c) == 0 {
+		return "", false, nil
+	}
+	return string(c[0].Value), true, nil
+}
+
+// readRows reads the latest cells in family of many rows concurrently.
+func (ix *index) readRows(ctx context.Context, rows []string, family string) ([]bigtable.Row, error) {
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(family), bigtable.LatestNFilter(1))
+	results := make([]bigtable.Row, len(rows))
+	errs := make([]error, len(rows))
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = ix.table.ReadRow(ctx, row, bigtable.RowFilter(filter))
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// document is a named document.
+type document struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// put adds a document, replacing any existing document with the same name.
+// Postings for words no longer in the document are removed.
+func (ix *index) put(ctx context.Context, name, content string) error {
+	return ix.putBatch(ctx, []document{{Name: name, Content: content}})
+}
+
+// putBatch adds documents, like put, writing all of them with a single
+// ApplyBulk call. If a name appears more than once, the last document wins.
+func (ix *index) putBatch(ctx context.Context, docs []document) error {
+	latest := make(map[string]string, len(docs))
+	var names []string
+	for _, d := range docs {
+		if _, ok := latest[d.Name]; !ok {
+			names = append(names, d.Name)
+		}
+		latest[d.Name] = d.Content
+	}
+
+	// Read the existing documents to find postings that must be removed.
+	existing, err := ix.readRows(ctx, names, contentColumnFamily)
+	if err != nil {
+		return fmt.Errorf("reading existing documents: %w", err)
+	}
+
+	var (
+		rowKeys    []string
+		muts       []*bigtable.Mutation
+		docsDelta  int64
+		wordsDelta int64
+		ts         = bigtable.Now()
+	)
+	add := func(row string, mut *bigtable.Mutation) {
+		rowKeys = append(rowKeys, row)
+		muts = append(muts, mut)
+	}
+	for i, name := range names {
+		words := tokens(latest[name])
+		terms := termPositions(words)
+
+		mut := bigtable.NewMutation()
+		mut.Set(contentColumnFamily, "", ts, []byte(latest[name]))
+		add(name, mut)
+		for term, positions := range terms {
+			mut := bigtable.NewMutation()
+			mut.Set(indexColumnFamily, name, ts, encodePosting(posting{docLen: len(words), positions: positions}))
+			add(term, mut)
+		}
+
+		docsDelta++
+		wordsDelta += int64(len(words))
+		c := existing[i][contentColumnFamily]
+		if len(c) == 0 {
+			continue
+		}
+		oldWords := tokens(string(c[0].Value))
+		for term := range termPositions(oldWords) {
+			if _, ok := terms[term]; ok {
+				continue
+			}
+			mut := bigtable.NewMutation()
+			mut.DeleteCellsInColumn(indexColumnFamily, name)
+			add(term, mut)
+		}
+		docsDelta--
+		wordsDelta -= int64(len(oldWords))
+	}
+
+	if err := applyBulk(ctx, ix.table, rowKeys, muts); err != nil {
+		return err
+	}
+	return ix.updateStats(ctx, docsDelta, wordsDelta)
+}
+
+// remove deletes a document and its postings. It reports whether the
+// document existed.
+func (ix *index) remove(ctx context.Context, name string) (bool, error) {
+	old, found, err := ix.readDoc(ctx, name)
+	if err != nil || !found {
+		return false, err
+	}
+
+	// The document's row may also be the index row for a word, so only
+	// delete the content column family.
+	rowKeys := []string{name}
+	mut := bigtable.NewMutation()
+	mut.DeleteCellsInFamily(contentColumnFamily)
+	muts := []*bigtable.Mutation{mut}
+	words := tokens(old)
+	for term := range termPositions(words) {
+		mut := bigtable.NewMutation()
+		mut.DeleteCellsInColumn(indexColumnFamily, name)
+		rowKeys = append(rowKeys, term)
+		muts = append(muts, mut)
+	}
+	if err := applyBulk(ctx, ix.table, rowKeys, muts); err != nil {
+		return false, err
+	}
+	return true, ix.updateStats(ctx, -1, -int64(len(words)))
+}
+
+// applyBulk applies the mutations, returning the first error.
+func applyBulk(ctx context.Context, table *bigtable.Table, rowKeys []string, muts []*bigtable.Mutation) error {
+	errs, err := table.ApplyBulk(ctx, rowKeys, muts)
+	if err != nil {
+		return err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("writing row %q: %w", rowKeys[i], err)
+		}
+	}
+	return nil
+}
+
+// updateStats adds to the document and word counts in the stats row.
+func (ix *index) updateStats(ctx context.Context, docs, words int64) error {
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(statsColumnFamily, "docs", docs)
+	rmw.Increment(statsColumnFamily, "words", words)
+	if _, err := ix.table.ApplyReadModifyWrite(ctx, statsRow, rmw); err != nil {
+		return fmt.Errorf("updating stats: %w", err)
+	}
+	return nil
+}
+
+// stats returns the number of documents and words in the table.
+func (ix *index) stats(ctx context.Context) (docs, words int64, err error) {
+	row, err := ix.table.ReadRow(ctx, statsRow, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, item := range row[statsColumnFamily] {
+		if len(item.Value) != 8 {
+			continue
+		}
+		v := int64(binary.BigEndian.Uint64(item.Value))
+		switch item.Column {
+		case statsColumnFamily + ":docs":
+			docs = v
+		case statsColumnFamily + ":words":
+			words = v
+		}
+	}
+	return docs, words, nil
+}
+
+// rebuildStats recomputes the stats row from the stored documents.
+func (ix *index) rebuildStats(ctx context.Context) error {
+	var docs, words int64
+	err := ix.table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		if c := row[contentColumnFamily]; len(c) > 0 {
+			docs++
+			words += int64(len(tokens(string(c[0].Value))))
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(bigtable.FamilyFilter(contentColumnFamily), bigtable.LatestNFilter(1))))
+	if err != nil {
+		return err
+	}
+
+	encode := func(v int64) []byte {
+		return binary.BigEndian.AppendUint64(nil, uint64(v))
+	}
+	mut := bigtable.NewMutation()
+	mut.Set(statsColumnFamily, "docs", bigtable.Now(), encode(docs))
+	mut.Set(statsColumnFamily, "words", bigtable.Now(), encode(words))
+	return ix.table.Apply(ctx, statsRow, mut)
+}
+
+// query is a parsed search query.
+type query struct {
+	terms   []string   // Distinct words, all of which must match.
+	phrases [][]string // Quoted phrases, which must match as consecutive words.
+}
+
+// parseQuery parses a query of words and quoted phrases, such as
+//
+//	bigtable "column family"
+func parseQuery(s string) query {
+	var q query
+	seen := make(map[string]bool)
+	for i, part := range strings.Split(s, `"`) {
+		words := tokens(part)
+		// Odd parts are inside quotes.
+		if i%2 == 1 && len(words) > 1 {
+			q.phrases = append(q.phrases, words)
+		}
+		for _, word := range words {
+			if !seen[word] {
+				seen[word] = true
+				q.terms = append(q.terms, word)
+			}
+		}
+	}
+	return q
+}
+
+// result is a document matching a query.
+type result struct {
+	Title   string
+	Score   float64
+	Snippet string
+}
+
+// search returns the k best matching documents for q, ranked by BM25.
+// Documents must contain every word in the query and every phrase.
+func (ix *index) search(ctx context.Context, q query, k int) ([]result, error) {
+	if len(q.terms) == 0 {
+		return nil, nil
+	}
+	docs, words, err := ix.stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading stats: %w", err)
+	}
+
+	// For each query word, get the postings of the documents containing it.
+	rows, err := ix.readRows(ctx, q.terms, indexColumnFamily)
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	postings := make(map[string]map[string]posting, len(q.terms))
+	for i, term := range q.terms {
+		postings[term] = make(map[string]posting)
+		for _, item := range rows[i][indexColumnFamily] {
+			p, err := decodePosting(item.Value)
+			if err != nil {
+				return nil, fmt.Errorf("index row %q: %w", term, err)
+			}
+			postings[term][item.Column[len(indexColumnFamily+":"):]] = p
+		}
+	}
+
+	// Score the documents that contain every word and phrase.
+	var avgLen float64
+	if docs > 0 {
+		avgLen = float64(words) / float64(docs)
+	}
+	var results []result
+	for doc := range postings[q.terms[0]] {
+		if !matches(postings, doc, q) {
+			continue
+		}
+		var score float64
+		for _, term := range q.terms {
+			p := postings[term][doc]
+			score += bm25(p.tf(), p.docLen, len(postings[term]), docs, avgLen)
+		}
+		results = append(results, result{Title: doc, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+
+	// Fetch the content of the top results from the Bigtable for snippets.
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Title
+	}
+	content, err := ix.readRows(ctx, names, contentColumnFamily)
+	if err != nil {
+		return nil, fmt.Errorf("reading results: %w", err)
+	}
+	for i := range results {
+		if c := content[i][contentColumnFamily]; len(c) > 0 {
+			text := string(c[0].Value)
+			if len(text) > 100 {
+				text = text[:100] + "..."
+			}
+			results[i].Snippet = text
+		}
+	}
+	return results, nil
+}
+
+// matches reports whether doc contains every word and phrase in q.
+func matches(postings map[string]map[string]posting, doc string, q query) bool {
+	for _, term := range q.terms {
+		if _, ok := postings[term][doc]; !ok {
+			return false
+		}
+	}
+PHRASE:
+	for _, phrase := range q.phrases {
+		positions := make([]map[int]bool, len(phrase))
+		for i, word := range phrase {
+			positions[i] = make(map[int]bool)
+			for _, pos := range postings[word][doc].positions {
+				positions[i][pos] = true
+			}
+		}
+		for _, start := range postings[phrase[0]][doc].positions {
+			found := true
+			for i := 1; i < len(phrase) && found; i++ {
+				found = positions[i][start+i]
+			}
+			if found {
+				continue PHRASE
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// bm25 returns the BM25 score contribution of a word that occurs tf times
+// in a document of docLen words, when df of the n documents in the table,
+// with an average length of avgLen, contain it. A docLen of 0 means the
+// length is unknown and the document is treated as average length.
+func bm25(tf, docLen, df int, n int64, avgLen float64) float64 {
+	if n < int64(df) {
+		// The stats are behind the index; don't let the IDF go negative.
+		n = int64(df)
+	}
+	idf := math.Log(1 + (float64(n)-float64(df)+0.5)/(float64(df)+0.5))
+	norm := 1.0
+	if avgLen > 0 && docLen > 0 {
+		norm = 1 - bm25B + bm25B*float64(docLen)/avgLen
+	}
+	return idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+}
@@ -0,0 +1,296 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestIndex returns an index backed by an in-memory Bigtable server.
+func newTestIndex(t *testing.T) (*index, *bigtable.Client, *bigtable.AdminClient) {
+	t.Helper()
+	ctx := context.Background()
+
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewAdminClient: %v", err)
+	}
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewClient: %v", err)
+	}
+	if err := createTable(ctx, adminClient, "docindex"); err != nil {
+		t.Fatalf("createTable: %v", err)
+	}
+	return &index{table: client.Open("docindex")}, client, adminClient
+}
+
+func mustPut(t *testing.T, ix *index, docs map[string]string) {
+	t.Helper()
+	for name, content := range docs {
+		if err := ix.put(context.Background(), name, content); err != nil {
+			t.Fatalf("put(%q): %v", name, err)
+		}
+	}
+}
+
+func titles(results []result) []string {
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Title)
+	}
+	return got
+}
+
+func TestPostingRoundTrip(t *testing.T) {
+	want := posting{docLen: 300, positions: []int{0, 7, 128, 299}}
+	got, err := decodePosting(encodePosting(want))
+	if err != nil {
+		t.Fatalf("decodePosting: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodePosting(encodePosting(%v)) = %v", want, got)
+	}
+	if _, err := decodePosting([]byte{0x80}); err == nil {
+		t.Errorf("decodePosting of truncated input succeeded, want error")
+	}
+	if got, err := decodePosting(nil); err != nil || got.tf() != 1 {
+		t.Errorf("decodePosting(legacy cell) = %v, %v; want a posting with tf 1", got, err)
+	}
+}
+
+func TestSearchLegacyIndex(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+	mustPut(t, ix, map[string]string{"new": "a gopher and a friend"})
+	// Index a document the way the sample did before postings were stored:
+	// an empty cell per word.
+	content := "the gopher has a friend"
+	mut := bigtable.NewMutation()
+	mut.Set(contentColumnFamily, "", bigtable.Now(), []byte(content))
+	if err := ix.table.Apply(ctx, "old", mut); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, word := range tokens(content) {
+		mut := bigtable.NewMutation()
+		mut.Set(indexColumnFamily, "old", bigtable.Now(), nil)
+		if err := ix.table.Apply(ctx, word, mut); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+
+	for q, want := range map[string][]string{
+		"gopher friend":     {"new", "old"},
+		`"a friend"`:        {"new"},
+		`gopher "a friend"`: {"new"},
+	} {
+		results, err := ix.search(ctx, parseQuery(q), 10)
+		if err != nil {
+			t.Fatalf("search(%s): %v", q, err)
+		}
+		got := titles(results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("search(%s) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	got := parseQuery(`Gopher "Column Family" gopher "single"`)
+	want := query{
+		terms:   []string{"gopher", "column", "family", "single"},
+		phrases: [][]string{{"column", "family"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseQuery = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+	mustPut(t, ix, map[string]string{
+		"once":   "the gopher went to the park with a friend and a ball",
+		"thrice": "gopher gopher gopher",
+		"twice":  "a gopher met another gopher at the park",
+		"none":   "the squirrel went to the park",
+	})
+
+	results, err := ix.search(ctx, parseQuery("gopher"), 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := titles(results), []string{"thrice", "twice", "once"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search(gopher) = %v, want %v", got, want)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("results not sorted by score: %+v", results)
+		}
+	}
+	if results[0].Snippet != "gopher gopher gopher" {
+		t.Errorf("snippet = %q, want document content", results[0].Snippet)
+	}
+
+	results, err = ix.search(ctx, parseQuery("gopher park"), 1)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := titles(results), []string{"twice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search(gopher park) top 1 = %v, want %v", got, want)
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	mustPut(t, ix, map[string]string{
+		"ordered":  "the quick brown fox",
+		"reversed": "the brown quick fox",
+		"apart":    "quick thinking and a brown fox",
+	})
+
+	for q, want := range map[string][]string{
+		`quick brown`:   {"apart", "ordered", "reversed"},
+		`"quick brown"`: {"ordered"},
+		`"brown quick"`: {"reversed"},
+		`"brown fox"`:   {"apart", "ordered"},
+		`"fox quick"`:   nil,
+	} {
+		results, err := ix.search(context.Background(), parseQuery(q), 10)
+		if err != nil {
+			t.Fatalf("search(%s): %v", q, err)
+		}
+		got := titles(results)
+		// Only the set of matches matters here, not the ranking.
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("search(%s) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestPutReplacesPostings(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+	mustPut(t, ix, map[string]string{"doc": "old words here"})
+	mustPut(t, ix, map[string]string{"doc": "new words"})
+
+	for q, want := range map[string][]string{
+		"old":   nil,
+		"new":   {"doc"},
+		"words": {"doc"},
+	} {
+		results, err := ix.search(ctx, parseQuery(q), 10)
+		if err != nil {
+			t.Fatalf("search(%s): %v", q, err)
+		}
+		if got := titles(results); !reflect.DeepEqual(got, want) {
+			t.Errorf("search(%s) = %v, want %v", q, got, want)
+		}
+	}
+
+	docs, words, err := ix.stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if docs != 1 || words != 2 {
+		t.Errorf("stats = %d docs, %d words, want 1, 2", docs, words)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+	// "park" is both a document name and a word in the other document.
+	mustPut(t, ix, map[string]string{
+		"park":  "a green space",
+		"walks": "walks in the park",
+	})
+
+	found, err := ix.remove(ctx, "park")
+	if err != nil || !found {
+		t.Fatalf("remove(park) = %v, %v, want true, nil", found, err)
+	}
+	if found, err := ix.remove(ctx, "park"); err != nil || found {
+		t.Errorf("second remove(park) = %v, %v, want false, nil", found, err)
+	}
+
+	if _, found, _ := ix.readDoc(ctx, "park"); found {
+		t.Errorf("document park still exists after remove")
+	}
+	results, err := ix.search(ctx, parseQuery("green"), 10)
+	if err != nil || len(results) != 0 {
+		t.Errorf("search(green) = %v, %v, want no results", titles(results), err)
+	}
+	results, err = ix.search(ctx, parseQuery("park"), 10)
+	if err != nil {
+		t.Fatalf("search(park): %v", err)
+	}
+	if got, want := titles(results), []string{"walks"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search(park) = %v, want %v", got, want)
+	}
+
+	docs, words, err := ix.stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if docs != 1 || words != 4 {
+		t.Errorf("stats = %d docs, %d words, want 1, 4", docs, words)
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	mustPut(t, ix, map[string]string{"hello": "hello world"})
+
+	form := url.Values{"q": {`"hello world"`}}
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handleSearch(rr, req, ix)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handleSearch status = %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), `<a href="/content?name=hello">hello</a>`) {
+		t.Errorf("handleSearch body missing result link:\n%s", rr.Body)
+	}
+}
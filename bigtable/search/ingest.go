@@ -0,0 +1,186 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runIngest implements the ingest command, which bulk-loads documents from
+// a directory or a JSONL file into the table.
+func runIngest(ctx context.Context, ix *index, args []string) error {
+	fset := flag.NewFlagSet("ingest", flag.ExitOnError)
+	var (
+		dir         = fset.String("dir", "", "Directory of documents to ingest. Each file is a document named by its path relative to the directory.")
+		jsonl       = fset.String("jsonl", "", `JSONL file of documents to ingest, one {"name": ..., "content": ...} object per line.`)
+		batchSize   = fset.Int("batch", 100, "Number of documents written in each ApplyBulk call.")
+		concurrency = fset.Int("concurrency", 4, "Maximum number of batches written concurrently.")
+	)
+	fset.Parse(args)
+	if (*dir == "") == (*jsonl == "") {
+		return errors.New("exactly one of -dir and -jsonl is required")
+	}
+	if *batchSize < 1 || *concurrency < 1 {
+		return errors.New("-batch and -concurrency must be at least 1")
+	}
+
+	docs := make(chan document)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(docs)
+		if *dir != "" {
+			readErr <- readDir(ctx, *dir, docs)
+			return
+		}
+		f, err := os.Open(*jsonl)
+		if err != nil {
+			readErr <- err
+			return
+		}
+		defer f.Close()
+		readErr <- readJSONL(ctx, f, docs)
+	}()
+
+	start := time.Now()
+	n, err := ingest(ctx, ix, docs, *batchSize, *concurrency, func(n int) {
+		log.Printf("Ingested %d documents (%.0f/s)", n, float64(n)/time.Since(start).Seconds())
+	})
+	if err := errors.Join(<-readErr, err); err != nil {
+		return fmt.Errorf("ingested %d documents before failing: %w", n, err)
+	}
+	log.Printf("Done: ingested %d documents in %v.", n, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// ingest writes the documents received on docs to the index in batches of
+// batchSize, with at most concurrency batches in flight. After each batch is
+// written, progress is called with the total number of documents written.
+// ingest returns the number of documents written and the first error.
+// Documents with the same name should not be ingested concurrently.
+func ingest(ctx context.Context, ix *index, docs <-chan document, batchSize, concurrency int, progress func(n int)) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex // Protects written and firstErr, and serializes progress.
+		written  int
+		firstErr error
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+	)
+	write := func(batch []document) {
+		defer func() {
+			<-sem
+			wg.Done()
+		}()
+		err := ix.putBatch(ctx, batch)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+		written += len(batch)
+		progress(written)
+	}
+
+	var batch []document
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go write(batch)
+		batch = nil
+	}
+	for doc := range docs {
+		if ctx.Err() != nil {
+			// Drain the channel so the reader can finish.
+			continue
+		}
+		batch = append(batch, doc)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+	flush()
+	wg.Wait()
+	return written, firstErr
+}
+
+// readDir sends each regular file under dir on docs, named by its slash
+// separated path relative to dir.
+func readDir(ctx context.Context, dir string, docs chan<- document) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		select {
+		case docs <- document{Name: filepath.ToSlash(rel), Content: string(b)}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	})
+}
+
+// readJSONL sends each document in r, one JSON object per line, on docs.
+// Blank lines are skipped.
+func readJSONL(ctx context.Context, r io.Reader, docs chan<- document) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 10<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var doc document
+		if err := json.Unmarshal(s.Bytes(), &doc); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if doc.Name == "" {
+			return fmt.Errorf("line %d: document has no name", line)
+		}
+		select {
+		case docs <- doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return s.Err()
+}
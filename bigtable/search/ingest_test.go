@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIngestDir(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.txt":     "alpha document",
+		"sub/b.txt": "beta document",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runIngest(ctx, ix, []string{"-dir", dir, "-batch", "1"}); err != nil {
+		t.Fatalf("runIngest: %v", err)
+	}
+	for name, want := range files {
+		got, found, err := ix.readDoc(ctx, name)
+		if err != nil || !found || got != want {
+			t.Errorf("readDoc(%q) = %q, %v, %v, want %q", name, got, found, err, want)
+		}
+	}
+}
+
+func TestIngestJSONL(t *testing.T) {
+	ix, _, _ := newTestIndex(t)
+	ctx := context.Background()
+
+	var lines []string
+	for i := 0; i < 25; i++ {
+		lines = append(lines, fmt.Sprintf(`{"name": "doc%02d", "content": "document number %d"}`, i, i))
+	}
+	docs := make(chan document)
+	go func() {
+		defer close(docs)
+		if err := readJSONL(ctx, strings.NewReader(strings.Join(lines, "\n\n")), docs); err != nil {
+			t.Errorf("readJSONL: %v", err)
+		}
+	}()
+
+	var reports []int
+	n, err := ingest(ctx, ix, docs, 10, 2, func(n int) { reports = append(reports, n) })
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if n != 25 {
+		t.Errorf("ingest wrote %d documents, want 25", n)
+	}
+	if len(reports) != 3 || reports[len(reports)-1] != 25 {
+		t.Errorf("progress reports = %v, want 3 ending with 25", reports)
+	}
+
+	docCount, _, err := ix.stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if docCount != 25 {
+		t.Errorf("stats docs = %d, want 25", docCount)
+	}
+	results, err := ix.search(ctx, parseQuery("document"), 100)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 25 {
+		t.Errorf("search found %d documents, want 25", len(results))
+	}
+}
+
+func TestReadJSONLErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"name": "a", "content": "x"}` + "\nnot json",
+		`{"content": "no name"}`,
+	} {
+		docs := make(chan document, 10)
+		if err := readJSONL(context.Background(), strings.NewReader(in), docs); err == nil {
+			t.Errorf("readJSONL(%q) succeeded, want error", in)
+		}
+	}
+}
@@ -14,15 +14,30 @@
 
 // Search is a sample web server that uses Cloud Bigtable as the storage layer
 // for a simple document-storage and full-text-search service.
-// It has four functions:
+// It has five functions:
 //   - Initialize and clear the table.
 //   - Add a document.  This adds the content of a user-supplied document to the
 //     Bigtable, and adds references to the document to an index in the Bigtable.
-//     The document is indexed under each unique word in the document.
-//   - Search the index.  This returns documents containing each word in a user
-//     query, with snippets and links to view the whole document.
+//     The document is indexed under each unique word in the document, along
+//     with the positions of the word.  Adding a document with an existing name
+//     replaces it.
+//   - Delete a document and its references in the index.
+//   - Search the index.  This returns the documents containing each word and
+//     quoted phrase in a user query, ranked by BM25, with snippets and links
+//     to view the whole document.
 //   - Copy table.  This copies the documents and index from another table and
-//     adds them to the current one.
+//     adds them to the current one, in batches.  An interrupted copy resumes
+//     where it left off when run again.
+//
+// Documents can also be added, fetched, deleted and searched through a JSON
+// API under /api/docs and /api/search.
+//
+// The program can also be run as a command instead of a server:
+//
+//	search -project P -instance I ingest -dir DIR | -jsonl FILE
+//	    Bulk-load documents from a directory or a JSONL file.
+//	search -project P -instance I copy -from TABLE
+//	    Copy the documents and index from TABLE.
 package main
 
 import (
@@ -35,10 +50,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
-	"sync"
 	"time"
-	"unicode"
 
 	"cloud.google.com/go/bigtable"
 )
@@ -56,7 +68,7 @@ Added {{.Title}}
 	searchTemplate = template.Must(template.New("").Parse(`<html><body>
 Results for <b>{{.Query}}</b>:<br><br>
 {{range .Results}}
-<a href="/content?name={{.Title}}">{{.Title}}</a><br>
+<a href="/content?name={{.Title}}">{{.Title}}</a> ({{printf "%.3f" .Score}})<br>
 <i>{{.Snippet}}</i><br><br>
 {{end}}
 </body></html>`))
@@ -65,6 +77,8 @@ Results for <b>{{.Query}}</b>:<br><br>
 const (
 	indexColumnFamily   = "i"
 	contentColumnFamily = "c"
+	statsColumnFamily   = "s"
+	defaultResults      = 10
 	mainPage            = `
 	<html>
 		<head>
@@ -76,7 +90,7 @@ const (
 				<div><input type="submit" value="Init"></div>
 			</form>
 
-			Search for documents:
+			Search for documents (use quotes for phrases):
 			<form action="/search" method="post">
 				<div><input type="text" name="q" size=80></div>
 				<div><input type="submit" value="Search"></div>
@@ -91,6 +105,13 @@ const (
 				<div><input type="submit" value="Submit"></div>
 			</form>
 
+			Delete a document:
+			<form action="/delete" method="post">
+				Document name:
+				<div><input type="text" name="name" size=80></div>
+				<div><input type="submit" value="Delete"></div>
+			</form>
+
 			Copy data from another table:
 			<form action="/copy" method="post">
 				Source table name:
@@ -124,14 +145,37 @@ func main() {
 	}
 
 	// Open the table.
-	table := client.Open(*tableName)
+	ix := &index{table: client.Open(*tableName)}
+
+	// Run a command instead of the server, if one was given.
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "ingest":
+		if err := runIngest(context.Background(), ix, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "copy":
+		fset := flag.NewFlagSet("copy", flag.ExitOnError)
+		from := fset.String("from", "", "The name of the table to copy from.")
+		fset.Parse(flag.Args()[1:])
+		if err := copyTable(context.Background(), *from, *tableName, client, copyBatchSize); err != nil {
+			log.Fatalf("Copy failed, run again to resume: %v", err)
+		}
+		return
+	default:
+		log.Fatalf("Unknown command %q", cmd)
+	}
 
 	// Set up HTML handlers, and start the web server.
-	http.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) { handleSearch(w, r, table) })
-	http.HandleFunc("/content", func(w http.ResponseWriter, r *http.Request) { handleContent(w, r, table) })
-	http.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) { handleAddDoc(w, r, table) })
+	http.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) { handleSearch(w, r, ix) })
+	http.HandleFunc("/content", func(w http.ResponseWriter, r *http.Request) { handleContent(w, r, ix) })
+	http.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) { handleAddDoc(w, r, ix) })
+	http.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) { handleDeleteDoc(w, r, ix) })
 	http.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) { handleReset(w, r, *tableName, adminClient) })
-	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, *tableName, client, adminClient) })
+	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, *tableName, client) })
+	http.HandleFunc("/api/docs", func(w http.ResponseWriter, r *http.Request) { handleAPIDocs(w, r, ix) })
+	http.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) { handleAPISearch(w, r, ix) })
 	http.HandleFunc("/", handleMain)
 	if err := http.ListenAndServe(":"+strconv.Itoa(*port), nil); err != nil {
 		log.Fatal(err)
@@ -143,24 +187,8 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, mainPage)
 }
 
-// tokenize splits a string into tokens.
-// This is very simple, it's not a good tokenization function.
-func tokenize(s string) []string {
-	wordMap := make(map[string]bool)
-	f := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
-	for _, word := range f {
-		word = strings.ToLower(word)
-		wordMap[word] = true
-	}
-	words := make([]string, 0, len(wordMap))
-	for word := range wordMap {
-		words = append(words, word)
-	}
-	return words
-}
-
 // handleContent fetches the content of a document from the Bigtable and returns it.
-func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+func handleContent(w http.ResponseWriter, r *http.Request, ix *index) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	name := r.FormValue("name")
@@ -169,105 +197,55 @@ func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table
 		return
 	}
 
-	row, err := table.ReadRow(ctx, name)
+	content, found, err := ix.readDoc(ctx, name)
 	if err != nil {
 		http.Error(w, "Error reading content: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	content := row[contentColumnFamily]
-	if len(content) == 0 {
+	if !found {
 		http.Error(w, "Document not found.", http.StatusNotFound)
 		return
 	}
 	var buf bytes.Buffer
-	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, string(content[0].Value)}); err != nil {
+	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, content}); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	io.Copy(w, &buf)
 }
 
-// handleSearch responds to search queries, returning links and snippets for matching documents.
-func handleSearch(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+// handleSearch responds to search queries, returning links and snippets for
+// the best matching documents. The optional n parameter sets the number of
+// results.
+func handleSearch(w http.ResponseWriter, r *http.Request, ix *index) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	query := r.FormValue("q")
-	// Split the query into words.
-	words := tokenize(query)
-	if len(words) == 0 {
+	q := r.FormValue("q")
+	// Split the query into words and phrases.
+	parsed := parseQuery(q)
+	if len(parsed.terms) == 0 {
 		http.Error(w, "Empty query.", http.StatusBadRequest)
 		return
 	}
-
-	// readRows reads from many rows concurrently.
-	readRows := func(rows []string) ([]bigtable.Row, error) {
-		results := make([]bigtable.Row, len(rows))
-		errors := make([]error, len(rows))
-		var wg sync.WaitGroup
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row string) {
-				defer wg.Done()
-				results[i], errors[i] = table.ReadRow(ctx, row, bigtable.RowFilter(bigtable.LatestNFilter(1)))
-			}(i, row)
-		}
-		wg.Wait()
-		for _, err := range errors {
-			if err != nil {
-				return nil, err
-			}
-		}
-		return results, nil
-	}
-
-	// For each query word, get the list of documents containing it.
-	results, err := readRows(words)
-	if err != nil {
-		http.Error(w, "Error reading index: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Count how many of the query words each result contained.
-	hits := make(map[string]int)
-	for _, r := range results {
-		for _, r := range r[indexColumnFamily] {
-			hits[r.Column]++
-		}
-	}
-
-	// Build a slice of all the documents that matched every query word.
-	var matches []string
-	for doc, count := range hits {
-		if count == len(words) {
-			matches = append(matches, doc[len(indexColumnFamily+":"):])
+	n := defaultResults
+	if s := r.FormValue("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n <= 0 {
+			http.Error(w, "Invalid number of results.", http.StatusBadRequest)
+			return
 		}
 	}
 
-	// Fetch the content of those documents from the Bigtable.
-	content, err := readRows(matches)
+	results, err := ix.search(ctx, parsed, n)
 	if err != nil {
-		http.Error(w, "Error reading results: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error searching: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	type result struct{ Title, Snippet string }
 	data := struct {
 		Query   string
 		Results []result
-	}{query, nil}
-
-	// Output links and snippets.
-	for i, doc := range matches {
-		var text string
-		c := content[i][contentColumnFamily]
-		if len(c) > 0 {
-			text = string(c[0].Value)
-		}
-		if len(text) > 100 {
-			text = text[:100] + "..."
-		}
-		data.Results = append(data.Results, result{doc, text})
-	}
+	}{q, results}
 	var buf bytes.Buffer
 	if err := searchTemplate.ExecuteTemplate(&buf, "", data); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
@@ -277,7 +255,7 @@ func handleSearch(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 }
 
 // handleAddDoc adds a document to the index.
-func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+func handleAddDoc(w http.ResponseWriter, r *http.Request, ix *index) {
 	if r.Method != "POST" {
 		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
 		return
@@ -298,52 +276,10 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 		return
 	}
 
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
-
-	// writeOneColumn writes one column in one row, updates err if there is an error,
-	// and signals wg that one operation has finished.
-	writeOneColumn := func(row, family, column, value string, ts bigtable.Timestamp) {
-		mut := bigtable.NewMutation()
-		mut.Set(family, column, ts, []byte(value))
-		err := table.Apply(ctx, row, mut)
-		if err != nil {
-			mu.Lock()
-			writeErr = err
-			mu.Unlock()
-		}
-	}
-
-	// Start a write to store the document content.
-	wg.Add(1)
-	go func() {
-		writeOneColumn(name, contentColumnFamily, "", content, bigtable.Now())
-		wg.Done()
-	}()
-
-	// Start writes to store the document name in the index for each word in the document.
-	words := tokenize(content)
-	for _, word := range words {
-		var (
-			row    = word
-			family = indexColumnFamily
-			column = name
-			value  = ""
-			ts     = bigtable.Now()
-		)
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			writeOneColumn(row, family, column, value, ts)
-			wg.Done()
-		}()
-	}
-	wg.Wait()
-	if writeErr != nil {
-		http.Error(w, "Error writing to Bigtable: "+writeErr.Error(), http.StatusInternalServerError)
+	// Store the document content, and the document's postings in the index
+	// for each word in the document.
+	if err := ix.put(ctx, name, content); err != nil {
+		http.Error(w, "Error writing to Bigtable: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	var buf bytes.Buffer
@@ -354,106 +290,58 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	io.Copy(w, &buf)
 }
 
-// handleReset deletes the table if it exists, creates it again, and creates its column families.
-func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClient *bigtable.AdminClient) {
+// handleDeleteDoc deletes a document and removes it from the index.
+func handleDeleteDoc(w http.ResponseWriter, r *http.Request, ix *index) {
 	if r.Method != "POST" {
 		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
 
-	adminClient.DeleteTable(ctx, table)
-	if err := adminClient.CreateTable(ctx, table); err != nil {
-		http.Error(w, "Error creating Bigtable: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	time.Sleep(20 * time.Second)
-	// Create two column families, and set the GC policy for each one to keep one version.
-	for _, family := range []string{indexColumnFamily, contentColumnFamily} {
-		if err := adminClient.CreateColumnFamily(ctx, table, family); err != nil {
-			http.Error(w, "Error creating column family: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := adminClient.SetGCPolicy(ctx, table, family, bigtable.MaxVersionsPolicy(1)); err != nil {
-			http.Error(w, "Error setting GC policy: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-	w.Write([]byte("<html><body>Done.</body></html>"))
-	return
-}
-
-// copyTable copies data from one table to another.
-func copyTable(src, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) error {
-	if src == "" || src == dst {
-		return nil
-	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	// Open the source and destination tables.
-	srcTable := client.Open(src)
-	dstTable := client.Open(dst)
-
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
-	copyRowToTable := func(row bigtable.Row) bool {
-		mu.Lock()
-		failed := writeErr != nil
-		mu.Unlock()
-		if failed {
-			return false
-		}
-		mut := bigtable.NewMutation()
-		for family, items := range row {
-			for _, item := range items {
-				// Get the column name, excluding the column family name and ':' character.
-				columnWithoutFamily := item.Column[len(family)+1:]
-				mut.Set(family, columnWithoutFamily, bigtable.Now(), item.Value)
-			}
-		}
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			if err := dstTable.Apply(ctx, row.Key(), mut); err != nil {
-				mu.Lock()
-				writeErr = err
-				mu.Unlock()
-			}
-			wg.Done()
-		}()
-		return true
+	name := r.FormValue("name")
+	if len(name) == 0 {
+		http.Error(w, "Empty document name!", http.StatusBadRequest)
+		return
 	}
 
-	// Create a filter that only accepts the column families we're interested in.
-	filter := bigtable.FamilyFilter(indexColumnFamily + "|" + contentColumnFamily)
-	// Read every row from srcTable, and call copyRowToTable to copy it to our table.
-	err := srcTable.ReadRows(ctx, bigtable.InfiniteRange(""), copyRowToTable, bigtable.RowFilter(filter))
-	wg.Wait()
+	found, err := ix.remove(ctx, name)
 	if err != nil {
-		return err
+		http.Error(w, "Error deleting from Bigtable: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	return writeErr
+	if !found {
+		http.Error(w, "Document not found.", http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, "<html><body>Deleted.</body></html>")
 }
 
-// handleCopy copies data from one table to another.
-func handleCopy(w http.ResponseWriter, r *http.Request, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) {
+// handleReset deletes the table if it exists, creates it again, and creates its column families.
+func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClient *bigtable.AdminClient) {
 	if r.Method != "POST" {
 		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
 		return
 	}
-	src := r.FormValue("name")
-	if src == "" {
-		http.Error(w, "No source table specified.", http.StatusBadRequest)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	adminClient.DeleteTable(ctx, table)
+	if err := createTable(ctx, adminClient, table); err != nil {
+		http.Error(w, "Error creating Bigtable: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := copyTable(src, dst, client, adminClient); err != nil {
-		http.Error(w, "Failed to rebuild index: "+err.Error(), http.StatusInternalServerError)
-		return
+	w.Write([]byte("<html><body>Done.</body></html>"))
+	return
+}
+
+// createTable creates the table with its column families, setting the GC
+// policy for each one to keep one version.
+func createTable(ctx context.Context, adminClient *bigtable.AdminClient, table string) error {
+	families := make(map[string]bigtable.Family)
+	for _, family := range []string{indexColumnFamily, contentColumnFamily, statsColumnFamily} {
+		families[family] = bigtable.Family{GCPolicy: bigtable.MaxVersionsPolicy(1)}
 	}
-	fmt.Fprint(w, "Copied table.\n")
+	return adminClient.CreateTableFromConf(ctx, &bigtable.TableConf{TableID: table, ColumnFamilies: families})
 }
@@ -0,0 +1,217 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/idtoken"
+)
+
+// validateFunc validates a Google-signed JWT for the given audience.
+// It is idtoken.Validate, except in tests.
+type validateFunc func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+
+// authenticator identifies the user making a request.
+type authenticator interface {
+	// email returns the verified email address of the user making r,
+	// or "" if the user is not signed in.
+	email(r *http.Request) (string, error)
+	// loginURL returns the URL that signs the user in and then returns to
+	// r's URL, or "" if the app can't sign users in itself.
+	loginURL(r *http.Request) string
+	// logoutURL returns the URL that signs the user out.
+	logoutURL() string
+	// register adds the handlers the authenticator needs to mux.
+	register(mux *http.ServeMux)
+}
+
+// emailClaim returns the verified email address in an ID token payload.
+func emailClaim(p *idtoken.Payload) (string, error) {
+	email, _ := p.Claims["email"].(string)
+	if email == "" {
+		return "", errors.New("token has no email claim")
+	}
+	// IAP tokens only contain verified addresses and have no email_verified claim.
+	if v, ok := p.Claims["email_verified"].(bool); ok && !v {
+		return "", fmt.Errorf("email %q is not verified", email)
+	}
+	return email, nil
+}
+
+// iapAuth identifies users by the JWT that Identity-Aware Proxy adds to each
+// request. IAP signs users in before requests reach the app.
+type iapAuth struct {
+	// audience is the expected audience of the JWT, e.g.
+	// /projects/PROJECT_NUMBER/apps/PROJECT_ID on App Engine.
+	audience string
+	validate validateFunc
+}
+
+func (a *iapAuth) email(r *http.Request) (string, error) {
+	assertion := r.Header.Get("X-Goog-IAP-JWT-Assertion")
+	if assertion == "" {
+		return "", errors.New("no Cloud IAP header found, is the app behind IAP?")
+	}
+	payload, err := a.validate(r.Context(), assertion, a.audience)
+	if err != nil {
+		return "", fmt.Errorf("idtoken.Validate: %w", err)
+	}
+	return emailClaim(payload)
+}
+
+func (a *iapAuth) loginURL(*http.Request) string { return "" }
+
+func (a *iapAuth) logoutURL() string { return "/_gcp_iap/clear_login_cookie" }
+
+func (a *iapAuth) register(*http.ServeMux) {}
+
+// oidcAuth signs users in with Google using OpenID Connect. After the user
+// signs in, the ID token is kept in a cookie and validated on each request;
+// the user signs in again once it expires.
+type oidcAuth struct {
+	config   *oauth2.Config
+	validate validateFunc
+}
+
+const (
+	tokenCookie = "id_token"
+	stateCookie = "oidc_state"
+)
+
+// newOIDCAuth returns an oidcAuth for the given OAuth client. redirectURL
+// must point to the /oauth2callback handler.
+func newOIDCAuth(clientID, clientSecret, redirectURL string) *oidcAuth {
+	return &oidcAuth{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     google.Endpoint,
+			Scopes:       []string{"openid", "email"},
+		},
+		validate: idtoken.Validate,
+	}
+}
+
+func (a *oidcAuth) email(r *http.Request) (string, error) {
+	c, err := r.Cookie(tokenCookie)
+	if err != nil {
+		return "", nil
+	}
+	payload, err := a.validate(r.Context(), c.Value, a.config.ClientID)
+	if err != nil {
+		// Most likely the token expired: treat the user as signed out.
+		return "", nil
+	}
+	return emailClaim(payload)
+}
+
+func (a *oidcAuth) loginURL(r *http.Request) string {
+	return "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+}
+
+func (a *oidcAuth) logoutURL() string { return "/logout" }
+
+func (a *oidcAuth) register(mux *http.ServeMux) {
+	mux.HandleFunc("/login", a.handleLogin)
+	mux.HandleFunc("/oauth2callback", a.handleCallback)
+	mux.HandleFunc("/logout", a.handleLogout)
+}
+
+// handleLogin redirects to Google's sign in page. A random state, and the
+// page to return to, are kept in a cookie to be checked by handleCallback.
+func (a *oidcAuth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "Error generating state", http.StatusInternalServerError)
+		return
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state + "|" + next,
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, a.config.AuthCodeURL(state), http.StatusFound)
+}
+
+// handleCallback exchanges the authorization code for an ID token and
+// stores the token in a cookie.
+func (a *oidcAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "Missing sign in state", http.StatusBadRequest)
+		return
+	}
+	state, next, _ := strings.Cut(c.Value, "|")
+	if state == "" || r.FormValue("state") != state {
+		http.Error(w, "Invalid sign in state", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := a.config.Exchange(r.Context(), r.FormValue("code"))
+	if err != nil {
+		http.Error(w, "Error exchanging code", http.StatusUnauthorized)
+		return
+	}
+	idToken, _ := tok.Extra("id_token").(string)
+	payload, err := a.validate(r.Context(), idToken, a.config.ClientID)
+	if err != nil {
+		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{
+		Name:     tokenCookie,
+		Value:    idToken,
+		Path:     "/",
+		Expires:  time.Unix(payload.Expires, 0),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func (a *oidcAuth) handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: tokenCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// isHTTPS reports whether the user made r over HTTPS, either directly or
+// through the load balancer in front of Cloud Run and App Engine.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
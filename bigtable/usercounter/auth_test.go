@@ -0,0 +1,186 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/idtoken"
+)
+
+// fakeValidate accepts tokens of the form "valid:AUDIENCE:EMAIL".
+func fakeValidate(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+	parts := strings.SplitN(token, ":", 3)
+	if len(parts) != 3 || parts[0] != "valid" {
+		return nil, errors.New("invalid token")
+	}
+	if parts[1] != audience {
+		return nil, fmt.Errorf("audience %q, want %q", parts[1], audience)
+	}
+	return &idtoken.Payload{
+		Audience: audience,
+		Expires:  time.Now().Add(time.Hour).Unix(),
+		Claims:   map[string]interface{}{"email": parts[2], "email_verified": true},
+	}, nil
+}
+
+func TestIAPAuth(t *testing.T) {
+	a := &iapAuth{audience: "/projects/1/apps/p", validate: fakeValidate}
+	tests := []struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{header: "valid:/projects/1/apps/p:gopher@example.com", want: "gopher@example.com"},
+		{header: "valid:/projects/2/apps/p:gopher@example.com", wantErr: true},
+		{header: "forged", wantErr: true},
+		{header: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			r.Header.Set("X-Goog-IAP-JWT-Assertion", tc.header)
+		}
+		got, err := a.email(r)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("email(%q) = %q, %v, want %q, error %v", tc.header, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestOIDCAuth(t *testing.T) {
+	// Fake Google's token endpoint, returning an ID token for the code.
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.FormValue("code") != "good-code" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "at", "token_type": "Bearer", "id_token": "valid:client-id:gopher@example.com"}`)
+	}))
+	defer tokenServer.Close()
+
+	a := newOIDCAuth("client-id", "secret", "https://example.com/oauth2callback")
+	a.config.Endpoint = oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth", TokenURL: tokenServer.URL}
+	a.validate = fakeValidate
+	mux := http.NewServeMux()
+	a.register(mux)
+
+	// Not signed in: no email, and a login URL that returns to the page.
+	r := httptest.NewRequest("GET", "/?x=1", nil)
+	if email, err := a.email(r); email != "" || err != nil {
+		t.Fatalf("email without cookie = %q, %v, want no user", email, err)
+	}
+	login := a.loginURL(r)
+	if want := "/login?next=" + url.QueryEscape("/?x=1"); login != want {
+		t.Errorf("loginURL = %q, want %q", login, want)
+	}
+
+	// Login redirects to the provider with a state that is kept in a cookie.
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", login, nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d", rr.Code, http.StatusFound)
+	}
+	authURL, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := authURL.Query().Get("state")
+	stateCookie := rr.Result().Cookies()[0]
+
+	// A callback with the wrong state is rejected.
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/oauth2callback?state=wrong&code=good-code", nil)
+	req.AddCookie(stateCookie)
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("callback with wrong state: status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	// A bad code is rejected.
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/oauth2callback?state="+state+"&code=bad-code", nil)
+	req.AddCookie(stateCookie)
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("callback with bad code: status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+
+	// A good callback sets the token cookie and returns to the page.
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/oauth2callback?state="+state+"&code=good-code", nil)
+	req.AddCookie(stateCookie)
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/?x=1" {
+		t.Fatalf("callback: status %d, Location %q, want redirect to /?x=1", rr.Code, rr.Header().Get("Location"))
+	}
+	var tokenCookieValue string
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == tokenCookie {
+			tokenCookieValue = c.Value
+		}
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: tokenCookie, Value: tokenCookieValue})
+	if email, err := a.email(r); email != "gopher@example.com" || err != nil {
+		t.Errorf("email with cookie = %q, %v, want gopher@example.com", email, err)
+	}
+}
+
+func TestLoginRejectsOpenRedirect(t *testing.T) {
+	a := newOIDCAuth("client-id", "secret", "https://example.com/oauth2callback")
+	rr := httptest.NewRecorder()
+	a.handleLogin(rr, httptest.NewRequest("GET", "/login?next=//evil.example.com/", nil))
+	c := rr.Result().Cookies()[0]
+	if _, next, _ := strings.Cut(c.Value, "|"); next != "/" {
+		t.Errorf("next = %q, want /", next)
+	}
+}
+
+func TestMainHandler(t *testing.T) {
+	c, _ := newTestCounter(t)
+	s := &server{auth: &iapAuth{audience: "aud", validate: fakeValidate}, counter: c}
+
+	for i, want := range []string{"You have visited 1", "You have visited 2"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Goog-IAP-JWT-Assertion", "valid:aud:gopher@example.com")
+		rr := httptest.NewRecorder()
+		appHandler(s.mainHandler).ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d: %s", i, rr.Code, rr.Body)
+		}
+		if body := rr.Body.String(); !strings.Contains(body, want) || !strings.Contains(body, "gopher@example.com") {
+			t.Errorf("request %d: body missing %q:\n%s", i, want, body)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	appHandler(s.mainHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("request without IAP header: status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	tableName = "user-visit-counter"
+
+	// familyName holds the total number of visits of each user, in the row
+	// keyed by the user's email address.
+	familyName = "emails"
+
+	// dailyFamilyName holds the number of visits of each user per day, in
+	// rows keyed by the user's email address and the day, e.g.
+	// "gopher@example.com#20260131". Keying by day keeps each user's history
+	// in a contiguous, date-ordered range of rows.
+	dailyFamilyName = "daily"
+	dailyColumn     = "visits"
+	dayFormat       = "20060102"
+)
+
+// counter counts how often each user visits, in a Bigtable table.
+type counter struct {
+	table *bigtable.Table
+	now   func() time.Time
+}
+
+// dayCount is the number of visits of a user on one day.
+type dayCount struct {
+	Day    time.Time
+	Visits uint64
+}
+
+// setupTable creates the table and its column families, if they don't exist.
+func setupTable(ctx context.Context, adminClient *bigtable.AdminClient) error {
+	tables, err := adminClient.Tables(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to fetch table list: %w", err)
+	}
+	if !slices.Contains(tables, tableName) {
+		if err := adminClient.CreateTable(ctx, tableName); err != nil {
+			return fmt.Errorf("unable to create table %v: %w", tableName, err)
+		}
+	}
+	tblInfo, err := adminClient.TableInfo(ctx, tableName)
+	if err != nil {
+		return fmt.Errorf("unable to read info for table %v: %w", tableName, err)
+	}
+	for _, family := range []string{familyName, dailyFamilyName} {
+		if !slices.Contains(tblInfo.Families, family) {
+			if err := adminClient.CreateColumnFamily(ctx, tableName, family); err != nil {
+				return fmt.Errorf("unable to create column family %v: %w", family, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dayKey returns the row key of the visits of the user on the day of t, in UTC.
+func dayKey(email string, t time.Time) string {
+	return email + "#" + t.UTC().Format(dayFormat)
+}
+
+// visit records a visit of the user and returns the user's total visits.
+func (c *counter) visit(ctx context.Context, email string) (uint64, error) {
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(familyName, email, 1)
+	row, err := c.table.ApplyReadModifyWrite(ctx, email, rmw)
+	if err != nil {
+		return 0, fmt.Errorf("ApplyReadModifyWrite(%q): %w", email, err)
+	}
+
+	daily := bigtable.NewReadModifyWrite()
+	daily.Increment(dailyFamilyName, dailyColumn, 1)
+	key := dayKey(email, c.now())
+	if _, err := c.table.ApplyReadModifyWrite(ctx, key, daily); err != nil {
+		return 0, fmt.Errorf("ApplyReadModifyWrite(%q): %w", key, err)
+	}
+
+	// Retrieve the most recently edited column.
+	return binary.BigEndian.Uint64(row[familyName][0].Value), nil
+}
+
+// history returns the user's visits on each of the last days days, oldest
+// first, including days without visits.
+func (c *counter) history(ctx context.Context, email string, days int) ([]dayCount, error) {
+	today := c.now().UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -(days - 1))
+
+	visits := make(map[string]uint64)
+	rr := bigtable.NewRange(dayKey(email, first), dayKey(email, today.AddDate(0, 0, 1)))
+	err := c.table.ReadRows(ctx, rr, func(row bigtable.Row) bool {
+		if items := row[dailyFamilyName]; len(items) > 0 && len(items[0].Value) == 8 {
+			visits[row.Key()] = binary.BigEndian.Uint64(items[0].Value)
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(bigtable.FamilyFilter(dailyFamilyName), bigtable.LatestNFilter(1))))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRows: %w", err)
+	}
+
+	history := make([]dayCount, 0, days)
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		history = append(history, dayCount{Day: day, Visits: visits[dayKey(email, day)]})
+	}
+	return history, nil
+}
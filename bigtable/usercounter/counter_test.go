@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestCounter returns a counter backed by an in-memory Bigtable server,
+// and a pointer to the time it uses as now.
+func newTestCounter(t *testing.T) (*counter, *time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewAdminClient: %v", err)
+	}
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("setupTable: %v", err)
+	}
+	// Setting up an existing table is a no-op.
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("second setupTable: %v", err)
+	}
+
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewClient: %v", err)
+	}
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	return &counter{table: client.Open(tableName), now: func() time.Time { return now }}, &now
+}
+
+func TestVisit(t *testing.T) {
+	c, _ := newTestCounter(t)
+	ctx := context.Background()
+
+	for want := uint64(1); want <= 3; want++ {
+		got, err := c.visit(ctx, "gopher@example.com")
+		if err != nil {
+			t.Fatalf("visit: %v", err)
+		}
+		if got != want {
+			t.Errorf("visit = %d, want %d", got, want)
+		}
+	}
+	got, err := c.visit(ctx, "other@example.com")
+	if err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+	if got != 1 {
+		t.Errorf("visit for another user = %d, want 1", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	c, now := newTestCounter(t)
+	ctx := context.Background()
+
+	visits := map[int]int{-8: 5, -3: 2, -1: 1, 0: 3} // Visits per day relative to today.
+	today := *now
+	for day, n := range visits {
+		*now = today.AddDate(0, 0, day)
+		for i := 0; i < n; i++ {
+			if _, err := c.visit(ctx, "gopher@example.com"); err != nil {
+				t.Fatalf("visit: %v", err)
+			}
+		}
+	}
+	*now = today
+	// Visits by another user must not show up.
+	if _, err := c.visit(ctx, "gopher@example.co"); err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+
+	history, err := c.history(ctx, "gopher@example.com", 7)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	want := []uint64{0, 0, 0, 2, 0, 1, 3}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d days, want %d: %v", len(history), len(want), history)
+	}
+	for i, h := range history {
+		wantDay := today.Truncate(24*time.Hour).AddDate(0, 0, i-6)
+		if !h.Day.Equal(wantDay) || h.Visits != want[i] {
+			t.Errorf("history[%d] = %v: %d, want %v: %d", i, h.Day, h.Visits, wantDay, want[i])
+		}
+	}
+}
+
+func TestDayKey(t *testing.T) {
+	// Days are in UTC, whatever the server's time zone.
+	tz := time.FixedZone("UTC-8", -8*60*60)
+	if got, want := dayKey("a@example.com", time.Date(2026, 1, 31, 20, 0, 0, 0, tz)), "a@example.com#20260201"; got != want {
+		t.Errorf("dayKey = %q, want %q", got, want)
+	}
+}
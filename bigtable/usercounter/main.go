@@ -15,117 +15,152 @@
 /*
 User counter is a program that tracks how often a user has visited the index page.
 
-This program demonstrates usage of the Cloud Bigtable API for App Engine flexible environment and Go.
-Instructions for running this program are in the README.md.
+This program demonstrates usage of the Cloud Bigtable API from Cloud Run or
+the App Engine standard environment and Go. Users are identified either by
+the JWT that Identity-Aware Proxy adds to requests, or by signing in with
+Google using OpenID Connect. Instructions for running this program are in
+the README.md.
 */
 package main
 
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"cloud.google.com/go/bigtable"
-	"google.golang.org/appengine"
-	aelog "google.golang.org/appengine/log"
-	"google.golang.org/appengine/user"
+	"google.golang.org/api/idtoken"
 )
 
-// User-provided constants.
-const (
-	project  = "PROJECT_ID"
-	instance = "INSTANCE"
-)
-
-var (
-	tableName  = "user-visit-counter"
-	familyName = "emails"
-
-	// Client is initialized by main.
-	client *bigtable.Client
-)
+// historyDays is the number of days of visit history shown to the user.
+const historyDays = 7
 
 func main() {
 	ctx := context.Background()
 
+	project := os.Getenv("BIGTABLE_PROJECT")
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	instance := os.Getenv("BIGTABLE_INSTANCE")
+	if project == "" || instance == "" {
+		log.Fatal("BIGTABLE_PROJECT (or GOOGLE_CLOUD_PROJECT) and BIGTABLE_INSTANCE must be set")
+	}
+
+	auth, err := newAuthenticator()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Set up admin client, tables, and column families.
 	// NewAdminClient uses Application Default Credentials to authenticate.
 	adminClient, err := bigtable.NewAdminClient(ctx, project, instance)
 	if err != nil {
 		log.Fatalf("Unable to create a table admin client. %v", err)
 	}
-	tables, err := adminClient.Tables(ctx)
-	if err != nil {
-		log.Fatalf("Unable to fetch table list. %v", err)
-	}
-	if !sliceContains(tables, tableName) {
-		if err := adminClient.CreateTable(ctx, tableName); err != nil {
-			log.Fatalf("Unable to create table: %v. %v", tableName, err)
-		}
-	}
-	tblInfo, err := adminClient.TableInfo(ctx, tableName)
-	if err != nil {
-		log.Fatalf("Unable to read info for table: %v. %v", tableName, err)
-	}
-	if !sliceContains(tblInfo.Families, familyName) {
-		if err := adminClient.CreateColumnFamily(ctx, tableName, familyName); err != nil {
-			log.Fatalf("Unable to create column family: %v. %v", familyName, err)
-		}
+	if err := setupTable(ctx, adminClient); err != nil {
+		log.Fatal(err)
 	}
 	adminClient.Close()
 
 	// Set up Bigtable data operations client.
 	// NewClient uses Application Default Credentials to authenticate.
-	client, err = bigtable.NewClient(ctx, project, instance)
+	client, err := bigtable.NewClient(ctx, project, instance)
 	if err != nil {
 		log.Fatalf("Unable to create data operations client. %v", err)
 	}
 
-	http.Handle("/", appHandler(mainHandler))
-	appengine.Main() // Never returns.
+	s := &server{
+		auth:    auth,
+		counter: &counter{table: client.Open(tableName), now: time.Now},
+	}
+	mux := http.NewServeMux()
+	auth.register(mux)
+	mux.Handle("/", appHandler(s.mainHandler))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+		log.Printf("Defaulting to port %s", port)
+	}
+	log.Printf("Listening on port %s", port)
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newAuthenticator returns the authenticator selected by the AUTH_MODE
+// environment variable: "iap" (the default) or "oidc".
+func newAuthenticator() (authenticator, error) {
+	switch mode := os.Getenv("AUTH_MODE"); mode {
+	case "", "iap":
+		aud := os.Getenv("IAP_AUDIENCE")
+		if aud == "" {
+			return nil, errors.New("IAP_AUDIENCE must be set, e.g. /projects/PROJECT_NUMBER/apps/PROJECT_ID")
+		}
+		return &iapAuth{audience: aud, validate: idtoken.Validate}, nil
+	case "oidc":
+		id, secret, redirect := os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), os.Getenv("OIDC_REDIRECT_URL")
+		if id == "" || secret == "" || redirect == "" {
+			return nil, errors.New("OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL must be set")
+		}
+		return newOIDCAuth(id, secret, redirect), nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_MODE %q, want iap or oidc", mode)
+	}
+}
+
+// server serves the visit counter page.
+type server struct {
+	auth    authenticator
+	counter *counter
 }
 
 // mainHandler tracks how many times each user has visited this page.
-func mainHandler(w http.ResponseWriter, r *http.Request) *appError {
+func (s *server) mainHandler(w http.ResponseWriter, r *http.Request) *appError {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return nil
 	}
 
-	ctx := appengine.NewContext(r)
-	u := user.Current(ctx)
-	if u == nil {
-		login, err := user.LoginURL(ctx, r.URL.String())
-		if err != nil {
-			return &appError{err, "Error finding login URL", http.StatusInternalServerError}
+	email, err := s.auth.email(r)
+	if err != nil {
+		return &appError{err, "Could not verify your identity", http.StatusUnauthorized}
+	}
+	if email == "" {
+		login := s.auth.loginURL(r)
+		if login == "" {
+			return &appError{errors.New("no signed in user"), "Not signed in", http.StatusUnauthorized}
 		}
 		http.Redirect(w, r, login, http.StatusFound)
 		return nil
 	}
-	logoutURL, err := user.LogoutURL(ctx, "/")
-	if err != nil {
-		return &appError{err, "Error finding logout URL", http.StatusInternalServerError}
-	}
 
 	// Increment visit count for user.
-	tbl := client.Open(tableName)
-	rmw := bigtable.NewReadModifyWrite()
-	rmw.Increment(familyName, u.Email, 1)
-	row, err := tbl.ApplyReadModifyWrite(ctx, u.Email, rmw)
+	ctx := r.Context()
+	visits, err := s.counter.visit(ctx, email)
 	if err != nil {
-		return &appError{err, "Error applying ReadModifyWrite to row: " + u.Email, http.StatusInternalServerError}
+		return &appError{err, "Error counting visit for: " + email, http.StatusInternalServerError}
+	}
+	history, err := s.counter.history(ctx, email, historyDays)
+	if err != nil {
+		return &appError{err, "Error reading visit history for: " + email, http.StatusInternalServerError}
 	}
 	data := struct {
 		Username, Logout string
 		Visits           uint64
+		History          []dayCount
 	}{
-		Username: u.Email,
-		// Retrieve the most recently edited column.
-		Visits: binary.BigEndian.Uint64(row[familyName][0].Value),
-		Logout: logoutURL,
+		Username: email,
+		Visits:   visits,
+		History:  history,
+		Logout:   s.auth.logoutURL(),
 	}
 
 	// Display hello page.
@@ -150,17 +185,12 @@ var tmpl = template.Must(template.New("").Parse(`
 You have visited {{.Visits}}
 </p>
 
-</body></html>`))
+<table>
+<tr><th>Day</th><th>Visits</th></tr>
+{{range .History}}<tr><td>{{.Day.Format "2006-01-02"}}</td><td>{{.Visits}}</td></tr>
+{{end}}</table>
 
-// sliceContains reports whether the provided string is present in the given slice of strings.
-func sliceContains(list []string, target string) bool {
-	for _, s := range list {
-		if s == target {
-			return true
-		}
-	}
-	return false
-}
+</body></html>`))
 
 // More info about this method of error handling can be found at: http://blog.golang.org/error-handling-and-go
 type appHandler func(http.ResponseWriter, *http.Request) *appError
@@ -173,8 +203,7 @@ type appError struct {
 
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if e := fn(w, r); e != nil {
-		ctx := appengine.NewContext(r)
-		aelog.Errorf(ctx, "%v", e.Error)
+		log.Printf("%v", e.Error)
 		http.Error(w, e.Message, e.Code)
 	}
 }
@@ -0,0 +1,201 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command cdnsign generates signing keys and signs and verifies URLs, URL
+// prefixes and cookies for Cloud CDN and Media CDN. It can also run a local
+// reverse proxy that enforces signatures like the CDN edge.
+//
+// Usage:
+//
+//	cdnsign keygen -alg hmac-sha1|ed25519 -out KEYFILE
+//	cdnsign url    -alg ALG -key KEYFILE -name KEYNAME [-ttl 1h] URL
+//	cdnsign prefix -alg ALG -key KEYFILE -name KEYNAME [-ttl 1h] URL_PREFIX
+//	cdnsign cookie -alg ALG -key KEYFILE -name KEYNAME [-ttl 1h] URL_PREFIX
+//	cdnsign verify -alg ALG -key KEYFILE -name KEYNAME [-cookie VALUE] URL
+//	cdnsign proxy  -alg ALG -key KEYFILE -name KEYNAME [-listen :8080] -upstream URL
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/cdn/signing"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cdnsign: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+const usage = `usage: cdnsign <keygen|url|prefix|cookie|verify|proxy> [flags] [args]
+
+Run cdnsign <command> -h for the flags of a command.`
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	var (
+		alg      = fs.String("alg", string(signing.HMACSHA1), "signing algorithm: hmac-sha1 (Cloud CDN) or ed25519 (Media CDN)")
+		keyFile  = fs.String("key", "", "base64url-encoded key file; an Ed25519 public key is enough to verify")
+		keyName  = fs.String("name", "", "key name, as configured on the backend or keyset")
+		ttl      = fs.Duration("ttl", time.Hour, "how long the signature is valid")
+		out      = fs.String("out", "", "keygen: file to write the key to")
+		cookie   = fs.String("cookie", "", "verify: signed cookie value to check against URL")
+		listen   = fs.String("listen", ":8080", "proxy: address to listen on")
+		upstream = fs.String("upstream", "", "proxy: origin URL to forward signed requests to")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "keygen":
+		return keygen(w, signing.Algorithm(*alg), *out)
+	case "url", "prefix", "cookie":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%s takes exactly one URL", cmd)
+		}
+		k, err := loadKey(signing.Algorithm(*alg), *keyFile, *keyName)
+		if err != nil {
+			return err
+		}
+		return sign(w, k, cmd, fs.Arg(0), time.Now().Add(*ttl))
+	case "verify":
+		if fs.NArg() != 1 {
+			return errors.New("verify takes exactly one URL")
+		}
+		k, err := loadKey(signing.Algorithm(*alg), *keyFile, *keyName)
+		if err != nil {
+			return err
+		}
+		v := signing.NewVerifier(k)
+		u := fs.Arg(0)
+		switch {
+		case *cookie != "":
+			err = v.VerifyCookie(u, *cookie)
+		case strings.Contains(u, "URLPrefix="):
+			err = v.VerifyPrefix(u)
+		default:
+			err = v.VerifyURL(u)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "OK")
+		return nil
+	case "proxy":
+		k, err := loadKey(signing.Algorithm(*alg), *keyFile, *keyName)
+		if err != nil {
+			return err
+		}
+		up, err := url.Parse(*upstream)
+		if err != nil || !up.IsAbs() {
+			return fmt.Errorf("-upstream must be an absolute URL, got %q", *upstream)
+		}
+		log.Printf("Proxying signed requests on %s to %s", *listen, up)
+		return http.ListenAndServe(*listen, signing.NewProxy(up, signing.NewVerifier(k)))
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+}
+
+// keygen generates a key, writes it to path and prints what to configure on
+// the CDN.
+func keygen(w io.Writer, alg signing.Algorithm, path string) error {
+	if path == "" {
+		return errors.New("keygen needs -out")
+	}
+	k, err := signing.GenerateKey("generated", alg)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(signing.EncodeKey(alg, k.Secret())+"\n"), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Wrote %s key to %s\n", alg, path)
+	if alg == signing.Ed25519 {
+		// Media CDN keysets hold the public key; keep the private key secret.
+		fmt.Fprintf(w, "Public key for the Media CDN keyset: %s\n", signing.EncodeKey(alg, k.Public()))
+	}
+	return nil
+}
+
+// loadKey reads a key of alg from path. Ed25519 key files may hold either a
+// private key or, for verifying, a public key.
+func loadKey(alg signing.Algorithm, path, name string) (*signing.Key, error) {
+	if path == "" || name == "" {
+		return nil, errors.New("-key and -name are required")
+	}
+	b, err := signing.ReadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch alg {
+	case signing.HMACSHA1:
+		return signing.NewHMACKey(name, b)
+	case signing.Ed25519:
+		if len(b) == ed25519.PublicKeySize {
+			return signing.NewEd25519VerifyKey(name, b)
+		}
+		return signing.NewEd25519Key(name, b)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", alg)
+	}
+}
+
+// sign prints a signed URL, prefix parameters or a Set-Cookie header.
+func sign(w io.Writer, k *signing.Key, what, u string, expires time.Time) error {
+	var (
+		s   string
+		err error
+	)
+	switch what {
+	case "url":
+		s, err = k.SignURL(u, expires)
+	case "prefix":
+		s, err = k.SignPrefix(u, expires)
+	case "cookie":
+		s, err = k.SignCookie(u, expires)
+		if err == nil {
+			p, _ := url.Parse(u)
+			c := &http.Cookie{
+				Name:   k.CookieName(),
+				Value:  s,
+				Domain: p.Hostname(),
+				Path:   p.Path,
+				MaxAge: int(time.Until(expires).Seconds()),
+			}
+			s = "Set-Cookie: " + c.String()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, s)
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, alg := range []string{"hmac-sha1", "ed25519"} {
+		t.Run(alg, func(t *testing.T) {
+			key := filepath.Join(dir, alg)
+			var b bytes.Buffer
+			if err := run([]string{"keygen", "-alg", alg, "-out", key}, &b); err != nil {
+				t.Fatalf("keygen: %v", err)
+			}
+			flags := []string{"-alg", alg, "-key", key, "-name", "my-key"}
+
+			b.Reset()
+			if err := run(append(append([]string{"url"}, flags...), "https://cdn.example.com/a.mp4"), &b); err != nil {
+				t.Fatalf("url: %v", err)
+			}
+			signed := strings.TrimSpace(b.String())
+			if err := run(append(append([]string{"verify"}, flags...), signed), &b); err != nil {
+				t.Errorf("verify %s: %v", signed, err)
+			}
+			if err := run(append(append([]string{"verify"}, flags...), strings.Replace(signed, "a.mp4", "b.mp4", 1)), &b); err == nil {
+				t.Errorf("verify of a tampered URL succeeded")
+			}
+
+			b.Reset()
+			if err := run(append(append([]string{"prefix"}, flags...), "https://cdn.example.com/videos/"), &b); err != nil {
+				t.Fatalf("prefix: %v", err)
+			}
+			prefixed := "https://cdn.example.com/videos/1.mp4?" + strings.TrimSpace(b.String())
+			if err := run(append(append([]string{"verify"}, flags...), prefixed), &b); err != nil {
+				t.Errorf("verify %s: %v", prefixed, err)
+			}
+
+			b.Reset()
+			if err := run(append(append([]string{"cookie"}, flags...), "https://cdn.example.com/videos/"), &b); err != nil {
+				t.Fatalf("cookie: %v", err)
+			}
+			header := strings.TrimSpace(b.String())
+			if !strings.HasPrefix(header, "Set-Cookie: ") || !strings.Contains(header, "Path=/videos/") {
+				t.Errorf("cookie printed %q, want a Set-Cookie header for /videos/", header)
+			}
+			value := strings.SplitN(strings.SplitN(header, "=", 2)[1], ";", 2)[0]
+			if err := run(append(append([]string{"verify"}, flags...), "-cookie", value, "https://cdn.example.com/videos/2.mp4"), &b); err != nil {
+				t.Errorf("verify cookie %s: %v", value, err)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(key, []byte("nZtRohdNF9m3cKM24IcK4w=="), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		nil,
+		{"sign"},
+		{"keygen", "-alg", "rsa", "-out", key + ".new"},
+		{"url", "-key", key, "https://example.com/"},
+		{"url", "-key", key, "-name", "k"},
+		{"url", "-alg", "ed25519", "-key", key, "-name", "k", "https://example.com/"},
+		{"proxy", "-key", key, "-name", "k", "-upstream", "not-a-url"},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%q) succeeded, want error", args)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// RequestURL returns the absolute URL the client requested. The scheme is
+// https for TLS connections and when a fronting proxy sets
+// X-Forwarded-Proto: https.
+func RequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// Middleware enforces signatures the way the CDN edge does: requests with a
+// valid signed URL, signed prefix or signed cookie are passed to next, and
+// all others are rejected with 403 Forbidden. It's a local stand-in for
+// testing origins and signing code without deploying a CDN.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.VerifyRequest(r, RequestURL(r)); err != nil {
+			log.Printf("signing: rejected %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewProxy returns a reverse proxy to upstream that only forwards requests
+// with valid signatures.
+func NewProxy(upstream *url.URL, v *Verifier) http.Handler {
+	return v.Middleware(httputil.NewSingleHostReverseProxy(upstream))
+}
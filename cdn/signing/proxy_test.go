@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "origin %s", r.URL.Path)
+	}))
+	defer origin.Close()
+	upstream, err := url.Parse(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacKey, edKey := testKeys(t)
+	edKey.name = "media-key"
+	edge := httptest.NewServer(NewProxy(upstream, NewVerifier(hmacKey, edKey)))
+	defer edge.Close()
+
+	exp := time.Now().Add(time.Hour)
+	signedURL, err := hmacKey.SignURL(edge.URL+"/a/1.ts", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, err := edKey.SignPrefix(edge.URL+"/a/", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie, err := edKey.SignCookie(edge.URL+"/b/", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		cookie   *http.Cookie
+		wantCode int
+	}{
+		{name: "signed URL", url: signedURL, wantCode: http.StatusOK},
+		{name: "signed prefix", url: edge.URL + "/a/2.ts?" + prefix, wantCode: http.StatusOK},
+		{name: "signed cookie", url: edge.URL + "/b/3.ts", cookie: &http.Cookie{Name: MediaCDNCookie, Value: cookie}, wantCode: http.StatusOK},
+		{name: "unsigned", url: edge.URL + "/a/1.ts", wantCode: http.StatusForbidden},
+		{name: "signed URL for another path", url: edge.URL + "/a/2.ts" + signedURL[len(edge.URL+"/a/1.ts"):], wantCode: http.StatusForbidden},
+		{name: "cookie outside prefix", url: edge.URL + "/a/1.ts", cookie: &http.Cookie{Name: MediaCDNCookie, Value: cookie}, wantCode: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.cookie != nil {
+			req.AddCookie(tc.cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantCode {
+			t.Errorf("%s: status = %d (%s), want %d", tc.name, resp.StatusCode, body, tc.wantCode)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing signs and verifies URLs, URL prefixes and cookies for
+// Cloud CDN, which uses HMAC-SHA1 keys, and Media CDN, which uses Ed25519
+// keys.
+//
+// A signed URL carries Expires, KeyName and Signature query parameters. A
+// signed URL prefix is a set of URLPrefix, Expires, KeyName and Signature
+// query parameters that can be added to any URL starting with the prefix. A
+// signed cookie holds the same fields separated by colons.
+//
+// The region-tagged samples in cdn/signedurls, cdn/signedcookies and mediacdn
+// show each operation on its own; this package is the reusable version.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Algorithm is a signing algorithm.
+type Algorithm string
+
+// The supported algorithms.
+const (
+	// HMACSHA1 is used by Cloud CDN with 128-bit keys.
+	HMACSHA1 Algorithm = "hmac-sha1"
+	// Ed25519 is used by Media CDN.
+	Ed25519 Algorithm = "ed25519"
+)
+
+// hmacKeySize is the size of Cloud CDN signing keys.
+const hmacKeySize = 16
+
+// Cookie names used by each CDN.
+const (
+	CloudCDNCookie = "Cloud-CDN-Cookie"
+	MediaCDNCookie = "Edge-Cache-Cookie"
+)
+
+// A Key is a named signing key. Keys made from an Ed25519 public key can
+// verify but not sign.
+type Key struct {
+	name   string
+	alg    Algorithm
+	secret []byte
+	priv   ed25519.PrivateKey
+	pub    ed25519.PublicKey
+}
+
+// NewHMACKey returns a Cloud CDN key. secret is the raw, not base64-encoded,
+// 16 byte key and name must match the key added to the backend service or
+// bucket.
+func NewHMACKey(name string, secret []byte) (*Key, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	if len(secret) != hmacKeySize {
+		return nil, fmt.Errorf("HMAC key is %d bytes, want %d", len(secret), hmacKeySize)
+	}
+	return &Key{name: name, alg: HMACSHA1, secret: secret}, nil
+}
+
+// NewEd25519Key returns a Media CDN signing key. name must match the key in
+// the keyset of the route.
+func NewEd25519Key(name string, priv ed25519.PrivateKey) (*Key, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("Ed25519 private key is %d bytes, want %d", len(priv), ed25519.PrivateKeySize)
+	}
+	return &Key{name: name, alg: Ed25519, priv: priv, pub: priv.Public().(ed25519.PublicKey)}, nil
+}
+
+// NewEd25519VerifyKey returns a Media CDN key that can only verify.
+func NewEd25519VerifyKey(name string, pub ed25519.PublicKey) (*Key, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Ed25519 public key is %d bytes, want %d", len(pub), ed25519.PublicKeySize)
+	}
+	return &Key{name: name, alg: Ed25519, pub: pub}, nil
+}
+
+// GenerateKey returns a new random key for alg.
+func GenerateKey(name string, alg Algorithm) (*Key, error) {
+	switch alg {
+	case HMACSHA1:
+		secret := make([]byte, hmacKeySize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(name, secret)
+	case Ed25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519Key(name, priv)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", alg)
+	}
+}
+
+// checkName rejects key names that would break the signed formats.
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, "&:=?# ") {
+		return fmt.Errorf("invalid key name %q", name)
+	}
+	return nil
+}
+
+// Name returns the key name.
+func (k *Key) Name() string { return k.name }
+
+// Algorithm returns the key's algorithm.
+func (k *Key) Algorithm() Algorithm { return k.alg }
+
+// CanSign reports whether the key holds the material needed to sign.
+func (k *Key) CanSign() bool { return k.secret != nil || k.priv != nil }
+
+// Public returns the Ed25519 public key to upload to a Media CDN keyset, or
+// nil for an HMAC key.
+func (k *Key) Public() ed25519.PublicKey { return k.pub }
+
+// Secret returns the raw key material to store: the HMAC secret or the
+// Ed25519 private key.
+func (k *Key) Secret() []byte {
+	if k.alg == HMACSHA1 {
+		return k.secret
+	}
+	return k.priv
+}
+
+// CookieName returns the name of the cookie the key's CDN reads.
+func (k *Key) CookieName() string {
+	if k.alg == HMACSHA1 {
+		return CloudCDNCookie
+	}
+	return MediaCDNCookie
+}
+
+// encoding returns the base64 encoding used for prefixes and signatures:
+// Cloud CDN pads them and Media CDN doesn't.
+func (k *Key) encoding() *base64.Encoding {
+	if k.alg == HMACSHA1 {
+		return base64.URLEncoding
+	}
+	return base64.RawURLEncoding
+}
+
+func (k *Key) sign(input string) (string, error) {
+	var sig []byte
+	switch {
+	case k.alg == HMACSHA1:
+		mac := hmac.New(sha1.New, k.secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case k.priv != nil:
+		sig = ed25519.Sign(k.priv, []byte(input))
+	default:
+		return "", fmt.Errorf("key %q can only verify", k.name)
+	}
+	return k.encoding().EncodeToString(sig), nil
+}
+
+// verify reports whether sig is a valid signature of input. Signatures are
+// accepted with or without padding.
+func (k *Key) verify(input, sig string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sig, "="))
+	if err != nil {
+		return false
+	}
+	if k.alg == HMACSHA1 {
+		mac := hmac.New(sha1.New, k.secret)
+		mac.Write([]byte(input))
+		return hmac.Equal(raw, mac.Sum(nil))
+	}
+	return ed25519.Verify(k.pub, []byte(input), raw)
+}
+
+// reservedParams are the query parameters added by signing.
+var reservedParams = []string{"Expires", "KeyName", "Signature", "URLPrefix"}
+
+// SignURL returns rawURL with Expires, KeyName and Signature query
+// parameters that allow access to it until expires. rawURL must not already
+// have any of those parameters.
+func (k *Key) SignURL(rawURL string, expires time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+	if !u.IsAbs() {
+		return "", fmt.Errorf("URL %q is not absolute", rawURL)
+	}
+	if u.Fragment != "" {
+		return "", fmt.Errorf("URL %q has a fragment", rawURL)
+	}
+	q := u.Query()
+	for _, p := range reservedParams {
+		if q.Has(p) {
+			return "", fmt.Errorf("URL %q already has a %s parameter", rawURL, p)
+		}
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	input := fmt.Sprintf("%s%sExpires=%d&KeyName=%s", rawURL, sep, expires.Unix(), k.name)
+	sig, err := k.sign(input)
+	if err != nil {
+		return "", err
+	}
+	return input + "&Signature=" + sig, nil
+}
+
+// SignPrefix returns URLPrefix, Expires, KeyName and Signature query
+// parameters that allow access to any URL starting with urlPrefix until
+// expires. Add them to the end of such a URL's query string. urlPrefix must
+// not have a query string.
+func (k *Key) SignPrefix(urlPrefix string, expires time.Time) (string, error) {
+	if err := checkPrefix(urlPrefix); err != nil {
+		return "", err
+	}
+	input := k.prefixFields(urlPrefix, expires, "&")
+	sig, err := k.sign(input)
+	if err != nil {
+		return "", err
+	}
+	return input + "&Signature=" + sig, nil
+}
+
+// SignCookie returns the value of a cookie named CookieName that allows
+// access to any URL starting with urlPrefix until expires.
+func (k *Key) SignCookie(urlPrefix string, expires time.Time) (string, error) {
+	if err := checkPrefix(urlPrefix); err != nil {
+		return "", err
+	}
+	input := k.prefixFields(urlPrefix, expires, ":")
+	sig, err := k.sign(input)
+	if err != nil {
+		return "", err
+	}
+	return input + ":Signature=" + sig, nil
+}
+
+// prefixFields returns the signed fields of a prefix or cookie, separated
+// by sep.
+func (k *Key) prefixFields(urlPrefix string, expires time.Time, sep string) string {
+	return fmt.Sprintf("URLPrefix=%s%sExpires=%d%sKeyName=%s",
+		k.encoding().EncodeToString([]byte(urlPrefix)), sep, expires.Unix(), sep, k.name)
+}
+
+func checkPrefix(urlPrefix string) error {
+	u, err := url.Parse(urlPrefix)
+	if err != nil {
+		return fmt.Errorf("url.Parse: %w", err)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("URL prefix %q is not absolute", urlPrefix)
+	}
+	if strings.ContainsAny(urlPrefix, "?#") {
+		return fmt.Errorf("URL prefix must not include query params: %s", urlPrefix)
+	}
+	return nil
+}
+
+// ReadKeyFile reads a base64url-encoded key file, as created by the gcloud
+// CLI or cdnsign keygen, and decodes it. Padding is optional.
+func ReadKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return DecodeKey(string(b))
+}
+
+// DecodeKey decodes base64url-encoded key material. Padding and surrounding
+// whitespace are optional.
+func DecodeKey(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64url decode: %w", err)
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key")
+	}
+	return b, nil
+}
+
+// EncodeKey encodes key material for a key file. HMAC keys are padded as
+// Cloud CDN expects; Ed25519 keys aren't, as Media CDN expects.
+func EncodeKey(alg Algorithm, b []byte) string {
+	if alg == HMACSHA1 {
+		return base64.URLEncoding.EncodeToString(b)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,250 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test keys and expected values are those of the cdn/signedurls,
+// cdn/signedcookies and mediacdn samples, so this package stays compatible
+// with them.
+var (
+	hmacTestKey = []byte{0x9d, 0x9b, 0x51, 0xa2, 0x17, 0x4d, 0x17, 0xd9,
+		0xb7, 0x70, 0xa3, 0x36, 0xe0, 0x87, 0x0a, 0xe3} // base64url: nZtRohdNF9m3cKM24IcK4w==
+
+	ed25519TestKey = []byte{34, 31, 185, 24, 168, 225, 242, 115, 112, 155, 38,
+		157, 183, 65, 104, 243, 85, 182, 188, 26, 176, 101, 247, 177,
+		243, 93, 114, 156, 94, 191, 219, 75, 183, 211, 110, 78, 223,
+		133, 62, 172, 159, 217, 158, 126, 34, 6, 254, 108, 57, 194,
+		141, 93, 219, 91, 8, 162, 88, 62, 52, 75, 42, 103, 202, 238,
+	}
+)
+
+func testKeys(t *testing.T) (hmacKey, edKey *Key) {
+	t.Helper()
+	hmacKey, err := NewHMACKey("my-key", hmacTestKey)
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+	edKey, err = NewEd25519Key("my-key", ed25519TestKey)
+	if err != nil {
+		t.Fatalf("NewEd25519Key: %v", err)
+	}
+	return hmacKey, edKey
+}
+
+func TestSign(t *testing.T) {
+	hmacKey, edKey := testKeys(t)
+	tests := []struct {
+		name    string
+		key     *Key
+		sign    func(k *Key, s string, exp time.Time) (string, error)
+		in      string
+		expires int64
+		want    string
+	}{
+		{
+			name:    "HMAC URL",
+			key:     hmacKey,
+			sign:    (*Key).SignURL,
+			in:      "https://www.example.com/some/path?some=query&another=param",
+			expires: 1549751461,
+			want:    "https://www.example.com/some/path?some=query&another=param&Expires=1549751461&KeyName=my-key&Signature=sTqqGX5hUJmlRJ84koAIhWW_c3M=",
+		},
+		{
+			name:    "HMAC prefix",
+			key:     hmacKey,
+			sign:    (*Key).SignPrefix,
+			in:      "https://media.example.com/segments/",
+			expires: 1558131350,
+			want:    "URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS9zZWdtZW50cy8=&Expires=1558131350&KeyName=my-key&Signature=HWE5tBTZgnYVoZzVLG7BtRnOsgk=",
+		},
+		{
+			name:    "HMAC cookie",
+			key:     hmacKey,
+			sign:    (*Key).SignCookie,
+			in:      "https://video.example.com/manifests/123/",
+			expires: 1558131350,
+			want:    "URLPrefix=aHR0cHM6Ly92aWRlby5leGFtcGxlLmNvbS9tYW5pZmVzdHMvMTIzLw==:Expires=1558131350:KeyName=my-key:Signature=0ZSSGg0VdRH29siXG8wiPTV5LOE=",
+		},
+		{
+			name:    "Ed25519 URL",
+			key:     edKey,
+			sign:    (*Key).SignURL,
+			in:      "http://35.186.234.33/index.html",
+			expires: 1558131350,
+			want:    "http://35.186.234.33/index.html?Expires=1558131350&KeyName=my-key&Signature=bwCkNAIuVneG0cRPwwPDk1vGmMfqR_TbFfLguwdsfF8Pdlk8INOKICYVOTHY5jHlGgwSF2jkRkm8bWZGwu-SAw",
+		},
+		{
+			name:    "Ed25519 prefix",
+			key:     edKey,
+			sign:    (*Key).SignPrefix,
+			in:      "https://www.google.com/",
+			expires: 1549751401,
+			want:    "URLPrefix=aHR0cHM6Ly93d3cuZ29vZ2xlLmNvbS8&Expires=1549751401&KeyName=my-key&Signature=f82Yhq9HrFXuAKNKlKpt7qk3e1BKo2OCtIy6JF0HA2j_l1IUF69ZFBXposUSky_fgvVvTpxi9IOJCONTKiMNDw",
+		},
+		{
+			name:    "Ed25519 cookie",
+			key:     edKey,
+			sign:    (*Key).SignCookie,
+			in:      "https://www.example.com/some",
+			expires: 1549751461,
+			want:    "URLPrefix=aHR0cHM6Ly93d3cuZXhhbXBsZS5jb20vc29tZQ:Expires=1549751461:KeyName=my-key:Signature=MjRwgGa4vJJ5lkVt1xJSoi-LyMk5x-bf1AmUBr-2XiB6zP4LSqHsmQZoeZA4fVw6C7HCcNqQT1UzGPgGe7bpAQ",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.sign(tc.key, tc.in, time.Unix(tc.expires, 0))
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("signed value = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	hmacKey, edKey := testKeys(t)
+	exp := time.Now().Add(time.Hour)
+	if _, err := hmacKey.SignURL("/relative", exp); err == nil {
+		t.Error("SignURL of a relative URL succeeded")
+	}
+	if _, err := hmacKey.SignURL("https://example.com/?KeyName=x", exp); err == nil {
+		t.Error("SignURL of a URL with a KeyName parameter succeeded")
+	}
+	if _, err := edKey.SignPrefix("https://example.com/?a=b", exp); err == nil {
+		t.Error("SignPrefix of a prefix with a query succeeded")
+	}
+	if _, err := NewHMACKey("my-key", hmacTestKey[:15]); err == nil {
+		t.Error("NewHMACKey with a 15 byte key succeeded")
+	}
+	if _, err := NewHMACKey("my:key", hmacTestKey); err == nil {
+		t.Error("NewHMACKey with a colon in the name succeeded")
+	}
+	verifyOnly, err := NewEd25519VerifyKey("my-key", edKey.Public())
+	if err != nil {
+		t.Fatalf("NewEd25519VerifyKey: %v", err)
+	}
+	if verifyOnly.CanSign() {
+		t.Error("verify-only key CanSign() = true")
+	}
+	if _, err := verifyOnly.SignURL("https://example.com/", exp); err == nil {
+		t.Error("SignURL with a verify-only key succeeded")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hmacKey, edKey := testKeys(t)
+	edPublic, err := NewEd25519VerifyKey("media-key", edKey.Public())
+	if err != nil {
+		t.Fatalf("NewEd25519VerifyKey: %v", err)
+	}
+	edSigner, err := NewEd25519Key("media-key", ed25519TestKey)
+	if err != nil {
+		t.Fatalf("NewEd25519Key: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	v := NewVerifier(hmacKey, edPublic)
+	v.now = func() time.Time { return now }
+	future, past := now.Add(time.Hour), now.Add(-time.Second)
+
+	for _, k := range []*Key{hmacKey, edSigner} {
+		t.Run(string(k.Algorithm()), func(t *testing.T) {
+			u, err := k.SignURL("https://cdn.example.com/video/1.mp4?quality=hd", future)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+			prefix, err := k.SignPrefix("https://cdn.example.com/video/", future)
+			if err != nil {
+				t.Fatalf("SignPrefix: %v", err)
+			}
+			cookie, err := k.SignCookie("https://cdn.example.com/video/", future)
+			if err != nil {
+				t.Fatalf("SignCookie: %v", err)
+			}
+			expired, err := k.SignURL("https://cdn.example.com/video/1.mp4", past)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+
+			tests := []struct {
+				name   string
+				verify func() error
+				want   error
+			}{
+				{"URL", func() error { return v.VerifyURL(u) }, nil},
+				{"prefix", func() error { return v.VerifyPrefix("https://cdn.example.com/video/2.mp4?" + prefix) }, nil},
+				{"prefix after query", func() error { return v.VerifyPrefix("https://cdn.example.com/video/2.mp4?t=10&" + prefix) }, nil},
+				{"cookie", func() error { return v.VerifyCookie("https://cdn.example.com/video/3.mp4?t=1", cookie) }, nil},
+				{"unsigned", func() error { return v.VerifyURL("https://cdn.example.com/video/1.mp4") }, ErrNoSignature},
+				{"expired", func() error { return v.VerifyURL(expired) }, ErrExpired},
+				{"tampered path", func() error { return v.VerifyURL(strings.Replace(u, "1.mp4", "2.mp4", 1)) }, ErrInvalidSignature},
+				{"tampered expiry", func() error {
+					return v.VerifyURL(strings.Replace(expired, "Expires=", "Expires=9", 1))
+				}, ErrInvalidSignature},
+				{"truncated signature", func() error { return v.VerifyURL(u[:len(u)-4]) }, ErrInvalidSignature},
+				{"parameter after signature", func() error { return v.VerifyURL(u + "&extra=1") }, ErrMalformed},
+				{"unknown key", func() error { return v.VerifyURL(strings.Replace(u, "KeyName="+k.Name(), "KeyName=other", 1)) }, ErrUnknownKey},
+				{"prefix mismatch", func() error { return v.VerifyPrefix("https://cdn.example.com/audio/1.mp3?" + prefix) }, ErrPrefixMismatch},
+				{"cookie prefix mismatch", func() error { return v.VerifyCookie("https://cdn.example.com/", cookie) }, ErrPrefixMismatch},
+				{"malformed cookie", func() error { return v.VerifyCookie("https://cdn.example.com/video/", "URLPrefix=abc") }, ErrMalformed},
+			}
+			for _, tc := range tests {
+				if err := tc.verify(); !errors.Is(err, tc.want) {
+					t.Errorf("%s: got error %v, want %v", tc.name, err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, alg := range []Algorithm{HMACSHA1, Ed25519} {
+		k, err := GenerateKey("gen-key", alg)
+		if err != nil {
+			t.Fatalf("GenerateKey(%s): %v", alg, err)
+		}
+		path := filepath.Join(dir, string(alg))
+		if err := os.WriteFile(path, []byte(EncodeKey(alg, k.Secret())+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ReadKeyFile(path)
+		if err != nil {
+			t.Fatalf("ReadKeyFile: %v", err)
+		}
+		if !bytes.Equal(b, k.Secret()) {
+			t.Errorf("%s key read back as %x, want %x", alg, b, k.Secret())
+		}
+	}
+
+	// Cloud CDN key files are padded.
+	b, err := DecodeKey("nZtRohdNF9m3cKM24IcK4w==")
+	if err != nil {
+		t.Fatalf("DecodeKey: %v", err)
+	}
+	if !bytes.Equal(b, hmacTestKey) {
+		t.Errorf("DecodeKey = %x, want %x", b, hmacTestKey)
+	}
+}
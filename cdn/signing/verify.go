@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Verification errors. Errors returned by the Verifier wrap one of these.
+var (
+	ErrNoSignature      = errors.New("no signature")
+	ErrMalformed        = errors.New("malformed signature")
+	ErrUnknownKey       = errors.New("unknown key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signature expired")
+	ErrPrefixMismatch   = errors.New("URL doesn't match the signed prefix")
+)
+
+// A Verifier checks signatures made with any of a set of keys, so keys can
+// be rotated by adding the new key before signing with it.
+type Verifier struct {
+	keys map[string]*Key
+	now  func() time.Time
+}
+
+// NewVerifier returns a Verifier that accepts signatures made by keys.
+func NewVerifier(keys ...*Key) *Verifier {
+	v := &Verifier{keys: map[string]*Key{}, now: time.Now}
+	for _, k := range keys {
+		v.keys[k.name] = k
+	}
+	return v
+}
+
+var (
+	// signedURL matches the signed portion of a URL and its signature.
+	signedURL = regexp.MustCompile(`^(.*[?&]Expires=(\d+)&KeyName=([^&]+))&Signature=([A-Za-z0-9_=-]+)$`)
+	// signedPrefix matches prefix parameters at the end of a query string.
+	signedPrefix = regexp.MustCompile(`(?:^|&)(URLPrefix=([A-Za-z0-9_=-]+)&Expires=(\d+)&KeyName=([^&]+))&Signature=([A-Za-z0-9_=-]+)$`)
+	// signedCookie matches a signed cookie value.
+	signedCookie = regexp.MustCompile(`^(URLPrefix=([A-Za-z0-9_=-]+):Expires=(\d+):KeyName=([^:]+)):Signature=([A-Za-z0-9_=-]+)$`)
+)
+
+// VerifyURL checks a URL signed by Key.SignURL.
+func (v *Verifier) VerifyURL(rawURL string) error {
+	if !strings.Contains(rawURL, "Signature=") {
+		return ErrNoSignature
+	}
+	m := signedURL.FindStringSubmatch(rawURL)
+	if m == nil {
+		return fmt.Errorf("%w: URL must end with Expires, KeyName and Signature parameters", ErrMalformed)
+	}
+	return v.check(m[1], m[2], m[3], m[4])
+}
+
+// VerifyPrefix checks that rawURL carries prefix parameters made by
+// Key.SignPrefix and starts with the signed prefix.
+func (v *Verifier) VerifyPrefix(rawURL string) error {
+	base, query, _ := strings.Cut(rawURL, "?")
+	if !strings.Contains(query, "URLPrefix=") {
+		return ErrNoSignature
+	}
+	m := signedPrefix.FindStringSubmatch(query)
+	if m == nil {
+		return fmt.Errorf("%w: query must end with URLPrefix, Expires, KeyName and Signature parameters", ErrMalformed)
+	}
+	if err := v.check(m[1], m[3], m[4], m[5]); err != nil {
+		return err
+	}
+	return checkPrefixMatch(m[2], base)
+}
+
+// VerifyCookie checks that value was made by Key.SignCookie for a prefix of
+// rawURL.
+func (v *Verifier) VerifyCookie(rawURL, value string) error {
+	if value == "" {
+		return ErrNoSignature
+	}
+	m := signedCookie.FindStringSubmatch(value)
+	if m == nil {
+		return fmt.Errorf("%w: cookie must have URLPrefix, Expires, KeyName and Signature fields", ErrMalformed)
+	}
+	if err := v.check(m[1], m[3], m[4], m[5]); err != nil {
+		return err
+	}
+	base, _, _ := strings.Cut(rawURL, "?")
+	return checkPrefixMatch(m[2], base)
+}
+
+// VerifyRequest checks the signature of r, trying in turn a signed prefix
+// and a signed URL in the query string, then a signed cookie. rawURL is the
+// URL the client requested, including scheme and host; see RequestURL.
+func (v *Verifier) VerifyRequest(r *http.Request, rawURL string) error {
+	q := r.URL.RawQuery
+	switch {
+	case strings.Contains(q, "URLPrefix="):
+		return v.VerifyPrefix(rawURL)
+	case strings.Contains(q, "Signature="):
+		return v.VerifyURL(rawURL)
+	}
+	for _, name := range []string{CloudCDNCookie, MediaCDNCookie} {
+		if c, err := r.Cookie(name); err == nil {
+			return v.VerifyCookie(rawURL, c.Value)
+		}
+	}
+	return ErrNoSignature
+}
+
+// check verifies the signature of input, then its expiry, so a tampered
+// expiry is reported as an invalid signature.
+func (v *Verifier) check(input, expires, keyName, sig string) error {
+	k, ok := v.keys[keyName]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, keyName)
+	}
+	if !k.verify(input, sig) {
+		return ErrInvalidSignature
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: Expires=%s", ErrMalformed, expires)
+	}
+	if !v.now().Before(time.Unix(exp, 0)) {
+		return fmt.Errorf("%w at %s", ErrExpired, time.Unix(exp, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkPrefixMatch checks that base starts with the base64url-encoded
+// prefix.
+func checkPrefixMatch(encoded, base string) error {
+	prefix, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return fmt.Errorf("%w: URLPrefix: %v", ErrMalformed, err)
+	}
+	if !strings.HasPrefix(base, string(prefix)) {
+		return fmt.Errorf("%w %q", ErrPrefixMismatch, prefix)
+	}
+	return nil
+}
@@ -22,4 +22,5 @@ import (
 // TABS vs. SPACES App for Cloud Functions
 func init() {
 	functions.HTTP("Votes", cloudsql.Votes)
+	functions.HTTP("Healthz", cloudsql.Healthz)
 }
@@ -0,0 +1,139 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// The candidates that can be voted for.
+const (
+	tabs   = "TABS"
+	spaces = "SPACES"
+)
+
+// recentLimit is the number of recent votes shown.
+const recentLimit = 5
+
+// vote contains a single row from the votes table in the database. Each vote
+// includes a candidate ("TABS" or "SPACES") and a timestamp.
+type vote struct {
+	Candidate string
+	VoteTime  time.Time
+}
+
+// votingData is used to pass data to the HTML template.
+type votingData struct {
+	TabsCount   int
+	SpacesCount int
+	VoteMargin  string
+	RecentVotes []vote
+}
+
+// repository reads and writes votes in a database migrated with migrate.
+type repository struct {
+	db *sql.DB
+}
+
+// save records a vote for candidate.
+func (r *repository) save(ctx context.Context, candidate string) error {
+	// [START cloud_sql_mysql_databasesql_connection]
+	insertVote := "INSERT INTO votes(candidate, created_at) VALUES(?, NOW())"
+	_, err := r.db.ExecContext(ctx, insertVote, candidate)
+	// [END cloud_sql_mysql_databasesql_connection]
+	if err != nil {
+		return fmt.Errorf("DB.Exec: %w", err)
+	}
+	return nil
+}
+
+// totals returns the number of votes for each candidate.
+func (r *repository) totals(ctx context.Context) (tabsCount, spacesCount int, err error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT candidate, COUNT(id) FROM votes GROUP BY candidate")
+	if err != nil {
+		return 0, 0, fmt.Errorf("DB.Query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			candidate string
+			count     int
+		)
+		if err := rows.Scan(&candidate, &count); err != nil {
+			return 0, 0, fmt.Errorf("Rows.Scan: %w", err)
+		}
+		switch candidate {
+		case tabs:
+			tabsCount = count
+		case spaces:
+			spacesCount = count
+		}
+	}
+	return tabsCount, spacesCount, rows.Err()
+}
+
+// recent returns the last n votes cast, newest first.
+func (r *repository) recent(ctx context.Context, n int) ([]vote, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT candidate, created_at FROM votes ORDER BY created_at DESC, id DESC LIMIT ?", n)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var votes []vote
+	for rows.Next() {
+		var v vote
+		if err := rows.Scan(&v.Candidate, &v.VoteTime); err != nil {
+			return nil, fmt.Errorf("Rows.Scan: %w", err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// summary retrieves all voting data from the database.
+func (r *repository) summary(ctx context.Context) (votingData, error) {
+	tabsCount, spacesCount, err := r.totals(ctx)
+	if err != nil {
+		return votingData{}, fmt.Errorf("totals: %w", err)
+	}
+	recent, err := r.recent(ctx, recentLimit)
+	if err != nil {
+		return votingData{}, fmt.Errorf("recent: %w", err)
+	}
+	return votingData{
+		TabsCount:   tabsCount,
+		SpacesCount: spacesCount,
+		VoteMargin:  formatMargin(tabsCount, spacesCount),
+		RecentVotes: recent,
+	}, nil
+}
+
+// formatMargin calculates the difference between votes and returns a human
+// friendly margin (e.g., 2 votes)
+func formatMargin(a, b int) string {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	// remove pluralization when diff is just one
+	if diff == 1 {
+		return "1 vote"
+	}
+	return fmt.Sprintf("%d votes", diff)
+}
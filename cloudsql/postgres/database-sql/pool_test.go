@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	got, err := poolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("poolConfigFromEnv: %v", err)
+	}
+	if got != defaultPoolConfig {
+		t.Errorf("poolConfigFromEnv() with no variables = %+v, want %+v", got, defaultPoolConfig)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "20")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "5m")
+	got, err = poolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("poolConfigFromEnv: %v", err)
+	}
+	want := defaultPoolConfig
+	want.MaxOpenConns = 20
+	want.ConnMaxIdleTime = 5 * time.Minute
+	if got != want {
+		t.Errorf("poolConfigFromEnv() = %+v, want %+v", got, want)
+	}
+
+	for name, value := range map[string]string{
+		"DB_MAX_IDLE_CONNS":    "many",
+		"DB_MAX_OPEN_CONNS":    "-1",
+		"DB_CONN_MAX_LIFETIME": "30",
+		"DB_PING_TIMEOUT":      "0s",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if c, err := poolConfigFromEnv(); err == nil {
+				t.Errorf("poolConfigFromEnv() with %s=%q = %+v, want error", name, value, c)
+			}
+		})
+	}
+}
+
+func TestHealthzConnectionFailed(t *testing.T) {
+	for _, connErr := range []error{errors.New("connectTCPSocket: unable to connect"), nil} {
+		rr := httptest.NewRecorder()
+		healthz(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil), nil, connErr, time.Second)
+		var h health
+		if err := json.Unmarshal(rr.Body.Bytes(), &h); err != nil {
+			t.Fatalf("decoding %q: %v", rr.Body.String(), err)
+		}
+		if rr.Code != http.StatusServiceUnavailable || h.Status != "unavailable" || h.Error == "" {
+			t.Errorf("healthz without a pool (error: %v) = %d %+v, want 503 with the error", connErr, rr.Code, h)
+		}
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	db := newTestDB(t)
+	tests := []struct {
+		method     string
+		close      bool
+		wantCode   int
+		wantStatus string
+	}{
+		{method: http.MethodGet, wantCode: http.StatusOK, wantStatus: "ok"},
+		{method: http.MethodHead, wantCode: http.StatusOK},
+		{method: http.MethodPost, wantCode: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, close: true, wantCode: http.StatusServiceUnavailable, wantStatus: "unavailable"},
+	}
+	for _, tc := range tests {
+		if tc.close {
+			db.Close()
+		}
+		rr := httptest.NewRecorder()
+		healthz(rr, httptest.NewRequest(tc.method, "/healthz", nil), db, nil, time.Second)
+		if rr.Code != tc.wantCode {
+			t.Errorf("%s (closed: %v) status = %d, want %d", tc.method, tc.close, rr.Code, tc.wantCode)
+		}
+		if tc.wantStatus == "" {
+			if rr.Body.Len() != 0 {
+				t.Errorf("%s body = %q, want empty", tc.method, rr.Body.String())
+			}
+			continue
+		}
+		var h health
+		if err := json.Unmarshal(rr.Body.Bytes(), &h); err != nil {
+			t.Fatalf("decoding %q: %v", rr.Body.String(), err)
+		}
+		if h.Status != tc.wantStatus || (h.Error != "") != tc.close {
+			t.Errorf("%s (closed: %v) body = %+v, want status %q", tc.method, tc.close, h, tc.wantStatus)
+		}
+	}
+}
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	db := newTestDB(t)
+	db.SetMaxOpenConns(3)
+	ctx := context.Background()
+	// Hold one connection and leave another idle in the pool.
+	held, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("DB.Conn: %v", err)
+	}
+	defer held.Close()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatalf("DB.Ping: %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	reg, err := registerPoolMetrics(db, mp.Meter(meterName), attribute.String("db.system", "sqlite"))
+	if err != nil {
+		t.Fatalf("registerPoolMetrics: %v", err)
+	}
+
+	got := collect(t, reader)
+	want := map[string]float64{
+		"db.client.connection.count state=idle":           1,
+		"db.client.connection.count state=used":           1,
+		"db.client.connection.max":                        3,
+		"db.client.connection.wait_count":                 0,
+		"db.client.connection.wait_duration":              0,
+		"db.client.connection.closed reason=max_idle":     0,
+		"db.client.connection.closed reason=max_lifetime": 0,
+	}
+	for k, v := range want {
+		if g, ok := got[k]; !ok || g != v {
+			t.Errorf("%s = %v (reported: %v), want %v", k, g, ok, v)
+		}
+	}
+
+	if err := reg.Unregister(); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	if got := collect(t, reader); len(got) != 0 {
+		t.Errorf("metrics reported after Unregister: %v", got)
+	}
+}
+
+// collect reads the metrics from reader, keyed by name and the attributes
+// other than db.system.
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	got := map[string]float64{}
+	key := func(name string, attrs attribute.Set) string {
+		if system, _ := attrs.Value("db.system"); system.AsString() != "sqlite" {
+			t.Errorf("%s is missing the db.system attribute: %v", name, attrs.ToSlice())
+		}
+		for _, kv := range attrs.ToSlice() {
+			if kv.Key != "db.system" {
+				name += " " + string(kv.Key) + "=" + kv.Value.Emit()
+			}
+		}
+		return name
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch d := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, p := range d.DataPoints {
+					got[key(m.Name, p.Attributes)] = float64(p.Value)
+				}
+			case metricdata.Sum[float64]:
+				for _, p := range d.DataPoints {
+					got[key(m.Name, p.Attributes)] = p.Value
+				}
+			default:
+				t.Errorf("%s has unexpected data %T", m.Name, m.Data)
+			}
+		}
+	}
+	return got
+}
@@ -19,20 +19,26 @@
 package cloudsql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	indexTmpl = template.Must(template.New("index").Parse(indexHTML))
 	db        *sql.DB
+	dbErr     error
+	poolConf  poolConfig
 	once      sync.Once
 )
 
@@ -40,103 +46,35 @@ var (
 // Cloud Functions may wish to skip this lazy instantiation and connect as soon
 // as the function is loaded. This is primarily to help testing.
 func getDB() *sql.DB {
-	once.Do(func() {
-		db = mustConnect()
-	})
-	return db
-}
-
-// migrateDB creates the votes table if it does not already exist.
-func migrateDB(db *sql.DB) error {
-	// Drop the votes table if it already exists.
-	if _, err := db.Exec("DROP TABLE IF EXISTS votes;"); err != nil {
-		log.Fatalf("DB.Exec: unable to drop votes table: %v", err)
-	}
-	// Create the votes table.
-	createVotes := `CREATE TABLE votes (
-		id int IDENTITY(1,1) PRIMARY KEY,
-		created_at DATETIME NOT NULL,
-		candidate CHAR(6) NOT NULL
-	);`
-	_, err := db.Exec(createVotes)
-	return err
-}
-
-// recentVotes returns the last five votes cast.
-func recentVotes(db *sql.DB) ([]vote, error) {
-	rows, err := db.Query("SELECT TOP 5 RTRIM(candidate), created_at FROM votes ORDER BY created_at DESC")
+	db, err := connectOnce()
 	if err != nil {
-		return nil, fmt.Errorf("DB.Query: %w", err)
+		log.Fatal(err)
 	}
-	defer rows.Close()
-
-	var votes []vote
-	for rows.Next() {
-		var (
-			candidate string
-			voteTime  time.Time
-		)
-		err := rows.Scan(&candidate, &voteTime)
-		if err != nil {
-			return nil, fmt.Errorf("Rows.Scan: %w", err)
-		}
-		votes = append(votes, vote{Candidate: candidate, VoteTime: voteTime})
-	}
-	return votes, nil
-}
-
-// formatMargin calculates the difference between votes and returns a human
-// friendly margin (e.g., 2 votes)
-func formatMargin(a, b int) string {
-	diff := int(math.Abs(float64(a - b)))
-	margin := fmt.Sprintf("%d votes", diff)
-	// remove pluralization when diff is just one
-	if diff == 1 {
-		margin = "1 vote"
-	}
-	return margin
+	return db
 }
 
-// votingData is used to pass data to the HTML template.
-type votingData struct {
-	TabsCount   int
-	SpacesCount int
-	VoteMargin  string
-	RecentVotes []vote
+// connectOnce connects to the database on its first call, and returns the
+// same connection pool, or error, on every call after that.
+func connectOnce() (*sql.DB, error) {
+	once.Do(func() {
+		db, dbErr = connect()
+	})
+	return db, dbErr
 }
 
-// currentTotals retrieves all voting data from the database.
-func currentTotals(db *sql.DB) (votingData, error) {
-	var (
-		tabs   int
-		spaces int
-	)
-	err := db.QueryRow("SELECT count(id) FROM votes WHERE candidate='TABS'").Scan(&tabs)
-	if err != nil {
-		return votingData{}, fmt.Errorf("DB.QueryRow: %w", err)
-	}
-	err = db.QueryRow("SELECT count(id) FROM votes WHERE candidate='SPACES'").Scan(&spaces)
-	if err != nil {
-		return votingData{}, fmt.Errorf("DB.QueryRow: %w", err)
-	}
-
-	recent, err := recentVotes(db)
+// mustConnect is like connect, but exits if the connection fails.
+func mustConnect() *sql.DB {
+	db, err := connect()
 	if err != nil {
-		return votingData{}, fmt.Errorf("recentVotes: %w", err)
+		log.Fatal(err)
 	}
-
-	return votingData{
-		TabsCount:   tabs,
-		SpacesCount: spaces,
-		VoteMargin:  formatMargin(tabs, spaces),
-		RecentVotes: recent,
-	}, nil
+	return db
 }
 
-// mustConnect creates a connection to the database based on environment
+// connect creates a connection to the database based on environment
 // variables. Setting one of INSTANCE_HOST or INSTANCE_CONNECTION_NAME will
 // establish a connection using a TCP socket or a connector respectively.
-func mustConnect() *sql.DB {
+func connect() (*sql.DB, error) {
 	var (
 		db  *sql.DB
 		err error
@@ -146,7 +84,7 @@ func mustConnect() *sql.DB {
 	if os.Getenv("INSTANCE_HOST") != "" {
 		db, err = connectTCPSocket()
 		if err != nil {
-			log.Fatalf("connectTCPSocket: unable to connect: %s", err)
+			return nil, fmt.Errorf("connectTCPSocket: unable to connect: %w", err)
 		}
 	}
 
@@ -154,19 +92,35 @@ func mustConnect() *sql.DB {
 	if os.Getenv("INSTANCE_CONNECTION_NAME") != "" {
 		db, err = connectWithConnector()
 		if err != nil {
-			log.Fatalf("connectConnector: unable to connect: %s", err)
+			return nil, fmt.Errorf("connectConnector: unable to connect: %w", err)
 		}
 	}
 
 	if db == nil {
-		log.Fatal("Missing database connection type. Please define one of INSTANCE_HOST or INSTANCE_CONNECTION_NAME")
+		return nil, errors.New("missing database connection type: define one of INSTANCE_HOST or INSTANCE_CONNECTION_NAME")
 	}
 
-	if err := migrateDB(db); err != nil {
-		log.Fatalf("unable to create table: %s", err)
+	poolConf, err = poolConfigFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure connection pool: %w", err)
 	}
+	// Override the limits set by configureConnectionPool with any
+	// DB_MAX_IDLE_CONNS, DB_MAX_OPEN_CONNS, DB_CONN_MAX_LIFETIME and
+	// DB_CONN_MAX_IDLE_TIME environment variables.
+	poolConf.apply(db)
 
-	return db
+	// Report the pool's statistics as OpenTelemetry metrics. They are
+	// discarded unless the global MeterProvider exports them.
+	_, err = registerPoolMetrics(db, otel.Meter(meterName), attribute.String("db.system", "mssql"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register pool metrics: %w", err)
+	}
+
+	if err := migrate(context.Background(), db); err != nil {
+		return nil, fmt.Errorf("unable to migrate database: %w", err)
+	}
+
+	return db, nil
 }
 
 // configureConnectionPool sets database connection pool properties.
@@ -197,33 +151,39 @@ func configureConnectionPool(db *sql.DB) {
 // Votes handles HTTP requests to alternatively show the voting app or to save a
 // vote.
 func Votes(w http.ResponseWriter, r *http.Request) {
+	handleVotes(w, r, &repository{db: getDB()})
+}
+
+// handleVotes serves Votes using repo.
+func handleVotes(w http.ResponseWriter, r *http.Request, repo *repository) {
 	switch r.Method {
 	case http.MethodGet:
-		renderIndex(w, r, getDB())
+		renderIndex(w, r, repo)
 	case http.MethodPost:
-		saveVote(w, r, getDB())
+		saveVote(w, r, repo)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-// vote contains a single row from the votes table in the database. Each vote
-// includes a candidate ("TABS" or "SPACES") and a timestamp.
-type vote struct {
-	Candidate string
-	VoteTime  time.Time
+// Healthz handles health checks by pinging the database, giving up after
+// DB_PING_TIMEOUT (2 seconds by default). Unlike Votes, it reports a failure
+// to connect as unhealthy instead of exiting.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	db, err := connectOnce()
+	healthz(w, r, db, err, poolConf.PingTimeout)
 }
 
 // renderIndex renders the HTML application with the voting form, current
 // totals, and recent votes.
-func renderIndex(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	t, err := currentTotals(db)
+func renderIndex(w http.ResponseWriter, r *http.Request, repo *repository) {
+	s, err := repo.summary(r.Context())
 	if err != nil {
 		log.Printf("renderIndex: failed to read current totals: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	err = indexTmpl.Execute(w, t)
+	err = indexTmpl.Execute(w, s)
 	if err != nil {
 		log.Printf("renderIndex: failed to render template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -232,7 +192,7 @@ func renderIndex(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 }
 
 // saveVote saves a vote passed as http.Request form data.
-func saveVote(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func saveVote(w http.ResponseWriter, r *http.Request, repo *repository) {
 	if err := r.ParseForm(); err != nil {
 		log.Printf("saveVote: failed to parse form: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -245,20 +205,16 @@ func saveVote(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if team != "TABS" && team != "SPACES" {
+	if team != tabs && team != spaces {
 		log.Printf("saveVote: \"team\" property should be \"TABS\" or \"SPACES\", was %q", team)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	// [START cloud_sql_sqlserver_databasesql_connection]
-	insertVote := "INSERT INTO votes (candidate, created_at) VALUES (@TEAM, GETDATE())"
-	_, err := db.Exec(insertVote, sql.Named("TEAM", team))
-	// [END cloud_sql_sqlserver_databasesql_connection]
-
-	if err != nil {
+	if err := repo.save(r.Context(), team); err != nil {
 		log.Printf("saveVote: unable to save vote: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 	fmt.Fprintf(w, "Vote successfully cast for %s!", team)
 }
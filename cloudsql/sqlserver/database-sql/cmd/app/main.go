@@ -15,9 +15,11 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	cloudsql "github.com/GoogleCloudPlatform/golang-samples/cloudsql/sqlserver/database-sql"
 )
@@ -27,10 +29,20 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
+
+	// Export connection pool metrics to Cloud Monitoring when
+	// EXPORT_METRICS=true.
+	shutdown, err := cloudsql.StartMetricsExporter(time.Minute)
+	if err != nil {
+		log.Fatalf("cloudsql.StartMetricsExporter: %v", err)
+	}
+
 	log.Printf("Listening on port %s", port)
 
+	http.HandleFunc("/healthz", cloudsql.Healthz)
 	http.HandleFunc("/", cloudsql.Votes)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		shutdown(context.Background())
 		log.Fatal(err)
 	}
 }
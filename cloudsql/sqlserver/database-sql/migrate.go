@@ -0,0 +1,122 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// A migration is a versioned schema change. up applies it and down reverts
+// it. Applied versions are recorded in the schema_migrations table.
+type migration struct {
+	version int
+	up      []string
+	down    []string
+}
+
+// createVersions creates the table recording the applied migrations.
+const createVersions = `IF OBJECT_ID('schema_migrations', 'U') IS NULL
+		CREATE TABLE schema_migrations (version INT NOT NULL PRIMARY KEY)`
+
+// migrations is the schema of the votes database. The first migration
+// creates the votes table only if it doesn't exist, so databases created
+// before migrations were tracked are adopted rather than rejected.
+var migrations = []migration{
+	{
+		version: 1,
+		up: []string{`IF OBJECT_ID('votes', 'U') IS NULL
+			CREATE TABLE votes (
+				id int IDENTITY(1,1) PRIMARY KEY,
+				created_at DATETIME NOT NULL,
+				candidate CHAR(6) NOT NULL
+			)`},
+		down: []string{"DROP TABLE votes"},
+	},
+	{
+		version: 2,
+		up:      []string{"CREATE INDEX votes_created_at ON votes (created_at)"},
+		down:    []string{"DROP INDEX votes_created_at ON votes"},
+	},
+}
+
+// migrate applies the migrations that haven't been applied yet, in version
+// order. Each migration runs in its own transaction.
+func migrate(ctx context.Context, db *sql.DB) error {
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m.up, "INSERT INTO schema_migrations (version) VALUES (@VERSION)", m.version); err != nil {
+			return fmt.Errorf("migration %d up: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// rollback reverts applied migrations newer than version, newest first.
+// Rolling back to version 0 removes the schema entirely.
+func rollback(ctx context.Context, db *sql.DB, version int) error {
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= version || m.version > current {
+			continue
+		}
+		if err := apply(ctx, db, m.down, "DELETE FROM schema_migrations WHERE version = @VERSION", m.version); err != nil {
+			return fmt.Errorf("migration %d down: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the newest applied migration, or 0 if none are.
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	if _, err := db.ExecContext(ctx, createVersions); err != nil {
+		return 0, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// apply runs stmts and records the change with the bookkeeping statement in
+// a single transaction.
+func apply(ctx context.Context, db *sql.DB, stmts []string, record string, version int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, sql.Named("VERSION", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,254 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	gcpmetric "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/metric"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// poolConfig holds the connection pool settings.
+type poolConfig struct {
+	// MaxIdleConns is the maximum number of idle connections kept open.
+	MaxIdleConns int
+	// MaxOpenConns is the maximum number of open connections, idle or in use.
+	MaxOpenConns int
+	// ConnMaxLifetime is how long a connection may be reused. Zero means
+	// forever.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is how long a connection may sit idle. Zero means
+	// forever.
+	ConnMaxIdleTime time.Duration
+	// PingTimeout bounds the database ping made by healthz.
+	PingTimeout time.Duration
+}
+
+// defaultPoolConfig is the configuration used for settings not in the
+// environment.
+var defaultPoolConfig = poolConfig{
+	MaxIdleConns:    5,
+	MaxOpenConns:    7,
+	ConnMaxLifetime: 1800 * time.Second,
+	PingTimeout:     2 * time.Second,
+}
+
+// apply sets db's pool limits to c. defaultPoolConfig matches the limits set
+// by configureConnectionPool, so only settings from the environment change
+// them.
+func (c poolConfig) apply(db *sql.DB) {
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// poolConfigFromEnv returns defaultPoolConfig overridden by these environment
+// variables:
+//
+//	DB_MAX_IDLE_CONNS      e.g. 5
+//	DB_MAX_OPEN_CONNS      e.g. 7
+//	DB_CONN_MAX_LIFETIME   e.g. 30m
+//	DB_CONN_MAX_IDLE_TIME  e.g. 5m
+//	DB_PING_TIMEOUT        e.g. 2s
+func poolConfigFromEnv() (poolConfig, error) {
+	c := defaultPoolConfig
+	for _, v := range []struct {
+		name string
+		n    *int
+		d    *time.Duration
+	}{
+		{name: "DB_MAX_IDLE_CONNS", n: &c.MaxIdleConns},
+		{name: "DB_MAX_OPEN_CONNS", n: &c.MaxOpenConns},
+		{name: "DB_CONN_MAX_LIFETIME", d: &c.ConnMaxLifetime},
+		{name: "DB_CONN_MAX_IDLE_TIME", d: &c.ConnMaxIdleTime},
+		{name: "DB_PING_TIMEOUT", d: &c.PingTimeout},
+	} {
+		s := os.Getenv(v.name)
+		if s == "" {
+			continue
+		}
+		var err error
+		if v.n != nil {
+			*v.n, err = strconv.Atoi(s)
+			if err == nil && *v.n < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		} else {
+			*v.d, err = time.ParseDuration(s)
+			if err == nil && *v.d < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		}
+		if err != nil {
+			return poolConfig{}, fmt.Errorf("%s=%q: %w", v.name, s, err)
+		}
+	}
+	if c.PingTimeout == 0 {
+		return poolConfig{}, fmt.Errorf("DB_PING_TIMEOUT must be positive")
+	}
+	return c, nil
+}
+
+// health is the body of a health check response.
+type health struct {
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+	OpenConnections int    `json:"openConnections"`
+	InUse           int    `json:"inUse"`
+	Idle            int    `json:"idle"`
+}
+
+// healthz handles health checks by pinging db, giving up after timeout. It
+// responds 200 OK when the database is reachable and 503 Service Unavailable
+// when it isn't, with the pool's current state in a JSON body. connErr is the
+// error creating db, if any; the pool is then unavailable without a ping.
+func healthz(w http.ResponseWriter, r *http.Request, db *sql.DB, connErr error, timeout time.Duration) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	h := health{Status: "ok"}
+	code := http.StatusOK
+	err := connErr
+	if err == nil && db == nil {
+		err = errors.New("connection pool not initialized")
+	}
+	if err == nil {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if err = db.PingContext(ctx); err != nil {
+			err = fmt.Errorf("DB.Ping: %w", err)
+		}
+		s := db.Stats()
+		h.OpenConnections, h.InUse, h.Idle = s.OpenConnections, s.InUse, s.Idle
+	}
+	if err != nil {
+		log.Printf("healthz: %v", err)
+		h.Status = "unavailable"
+		h.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(w).Encode(h); err != nil {
+		log.Printf("healthz: writing response: %v", err)
+	}
+}
+
+// meterName is the instrumentation scope of the pool metrics.
+const meterName = "github.com/GoogleCloudPlatform/golang-samples/cloudsql/sqlserver/database-sql"
+
+// registerPoolMetrics reports db's sql.DBStats through meter each time metrics
+// are collected. Every observation carries attrs, typically the database
+// system and name. The metrics are:
+//
+//	db.client.connection.count          connections by state, idle or used;
+//	                                    their sum is the open connections
+//	db.client.connection.max            the maximum open connections allowed
+//	db.client.connection.wait_count     requests that waited for a connection
+//	db.client.connection.wait_duration  total time spent waiting, in seconds
+//	db.client.connection.closed         connections closed by the pool limits,
+//	                                    by reason
+//
+// Call Unregister on the returned registration to stop reporting.
+func registerPoolMetrics(db *sql.DB, meter metric.Meter, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	count, err := meter.Int64ObservableUpDownCounter("db.client.connection.count",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("The number of connections in the pool by state."))
+	if err != nil {
+		return nil, fmt.Errorf("db.client.connection.count: %w", err)
+	}
+	maxConns, err := meter.Int64ObservableUpDownCounter("db.client.connection.max",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("The maximum number of open connections allowed."))
+	if err != nil {
+		return nil, fmt.Errorf("db.client.connection.max: %w", err)
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.client.connection.wait_count",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("The number of requests that waited for a connection."))
+	if err != nil {
+		return nil, fmt.Errorf("db.client.connection.wait_count: %w", err)
+	}
+	waitDuration, err := meter.Float64ObservableCounter("db.client.connection.wait_duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("The total time requests waited for a connection."))
+	if err != nil {
+		return nil, fmt.Errorf("db.client.connection.wait_duration: %w", err)
+	}
+	closed, err := meter.Int64ObservableCounter("db.client.connection.closed",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("The number of connections closed by the pool limits."))
+	if err != nil {
+		return nil, fmt.Errorf("db.client.connection.closed: %w", err)
+	}
+
+	with := func(kv ...attribute.KeyValue) metric.ObserveOption {
+		return metric.WithAttributes(append(kv, attrs...)...)
+	}
+	common := with()
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := db.Stats()
+		o.ObserveInt64(count, int64(s.Idle), with(attribute.String("state", "idle")))
+		o.ObserveInt64(count, int64(s.InUse), with(attribute.String("state", "used")))
+		o.ObserveInt64(maxConns, int64(s.MaxOpenConnections), common)
+		o.ObserveInt64(waitCount, s.WaitCount, common)
+		o.ObserveFloat64(waitDuration, s.WaitDuration.Seconds(), common)
+		o.ObserveInt64(closed, s.MaxIdleClosed, with(attribute.String("reason", "max_idle")))
+		o.ObserveInt64(closed, s.MaxIdleTimeClosed, with(attribute.String("reason", "max_idle_time")))
+		o.ObserveInt64(closed, s.MaxLifetimeClosed, with(attribute.String("reason", "max_lifetime")))
+		return nil
+	}, count, maxConns, waitCount, waitDuration, closed)
+}
+
+// StartMetricsExporter exports metrics to Cloud Monitoring every interval when the
+// EXPORT_METRICS environment variable is true, by installing a global
+// MeterProvider. Otherwise the global provider is left alone and metrics are
+// discarded. Call the returned function to flush metrics before exiting.
+func StartMetricsExporter(interval time.Duration) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+	export, _ := strconv.ParseBool(os.Getenv("EXPORT_METRICS"))
+	if !export {
+		return noop, nil
+	}
+	exporter, err := gcpmetric.New()
+	if err != nil {
+		return noop, fmt.Errorf("gcpmetric.New: %w", err)
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(
+		sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))))
+	otel.SetMeterProvider(mp)
+	return mp.Shutdown, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestDB returns an empty in-memory SQLite database, for tests that don't
+// depend on the database's SQL dialect.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// connectTestDB connects to the test database over TCP and migrates it. It
+// skips the test unless GOLANG_SAMPLES_E2E_TEST is set.
+func connectTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if os.Getenv("GOLANG_SAMPLES_E2E_TEST") == "" {
+		t.Skip()
+	}
+	t.Cleanup(setupTestEnv(dbConfigFromEnv(t, useTCP)))
+	db := mustConnect()
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := connectTestDB(t)
+
+	latest := migrations[len(migrations)-1].version
+	if err := migrate(ctx, db); err != nil {
+		t.Fatalf("migrate on a migrated database: %v", err)
+	}
+	if v, err := schemaVersion(ctx, db); err != nil || v != latest {
+		t.Fatalf("schemaVersion = %d, %v; want %d", v, err, latest)
+	}
+
+	if err := rollback(ctx, db, 1); err != nil {
+		t.Fatalf("rollback(1): %v", err)
+	}
+	if v, _ := schemaVersion(ctx, db); v != 1 {
+		t.Errorf("schemaVersion after rollback(1) = %d, want 1", v)
+	}
+	if err := migrate(ctx, db); err != nil {
+		t.Fatalf("migrate after rollback: %v", err)
+	}
+	if v, _ := schemaVersion(ctx, db); v != latest {
+		t.Errorf("schemaVersion after re-migrating = %d, want %d", v, latest)
+	}
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 || len(m.up) == 0 || len(m.down) == 0 {
+			t.Errorf("migration %d = version %d with %d up and %d down statements; want version %d with both",
+				i, m.version, len(m.up), len(m.down), i+1)
+		}
+	}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := &repository{db: connectTestDB(t)}
+
+	before, err := repo.summary(ctx)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	for _, c := range []string{tabs, spaces, spaces} {
+		if err := repo.save(ctx, c); err != nil {
+			t.Fatalf("save(%s): %v", c, err)
+		}
+	}
+	s, err := repo.summary(ctx)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if s.TabsCount != before.TabsCount+1 || s.SpacesCount != before.SpacesCount+2 {
+		t.Errorf("summary = %d tabs, %d spaces; want %d, %d", s.TabsCount, s.SpacesCount, before.TabsCount+1, before.SpacesCount+2)
+	}
+	var got []string
+	for _, v := range s.RecentVotes[:3] {
+		if v.VoteTime.IsZero() {
+			t.Errorf("vote for %s has no time", v.Candidate)
+		}
+		got = append(got, v.Candidate)
+	}
+	if want := "SPACES SPACES TABS"; strings.Join(got, " ") != want {
+		t.Errorf("recent votes = %v, want newest first %q", got, want)
+	}
+}
+
+func TestFormatMargin(t *testing.T) {
+	for _, tc := range []struct {
+		a, b int
+		want string
+	}{
+		{0, 0, "0 votes"},
+		{1, 0, "1 vote"},
+		{0, 1, "1 vote"},
+		{2, 5, "3 votes"},
+	} {
+		if got := formatMargin(tc.a, tc.b); got != tc.want {
+			t.Errorf("formatMargin(%d, %d) = %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestHandleVotesBadRequest(t *testing.T) {
+	// None of these requests reach the database.
+	repo := &repository{}
+	for _, tc := range []struct {
+		method, body string
+		want         int
+	}{
+		{"POST", "", http.StatusBadRequest},
+		{"POST", "team=NEWLINES", http.StatusBadRequest},
+		{"DELETE", "", http.StatusMethodNotAllowed},
+	} {
+		req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handleVotes(rr, req, repo)
+		if rr.Code != tc.want {
+			t.Errorf("%s %q = %d, want %d", tc.method, tc.body, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestHandleVotesDatabaseError(t *testing.T) {
+	// Without migrating there is no votes table.
+	repo := &repository{db: newTestDB(t)}
+	for _, method := range []string{"GET", "POST"} {
+		req := httptest.NewRequest(method, "/", strings.NewReader("team=TABS"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handleVotes(rr, req, repo)
+		if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "successfully") {
+			t.Errorf("%s without schema = %d %q, want 500", method, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -17,16 +17,18 @@ package main
 // [START datastore_build_service]
 import (
 	"context"
-	"log"
+	"fmt"
 
 	"cloud.google.com/go/datastore"
 )
 
-func createClient(projectID string) (*datastore.Client, error) {
-	ctx := context.Background()
+// createClient creates the client shared by all commands. When the
+// DATASTORE_EMULATOR_HOST environment variable is set, the client connects
+// to the Datastore emulator instead of the service.
+func createClient(ctx context.Context, projectID string) (*datastore.Client, error) {
 	client, err := datastore.NewClient(ctx, projectID)
 	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
+		return nil, fmt.Errorf("datastore.NewClient: %w", err)
 	}
 	// Note: call the following from main() to ensure the client
 	// properly frees all resources.
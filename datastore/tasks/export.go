@@ -0,0 +1,105 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// maxBatchSize is the most entities a single PutMulti may write.
+const maxBatchSize = 500
+
+// taskRecord is a task as a line of an export file.
+type taskRecord struct {
+	ID int64 `json:"id,omitempty"`
+	*Task
+}
+
+// ExportTasks writes all tasks to w as JSON lines.
+func ExportTasks(ctx context.Context, client *datastore.Client, w io.Writer) (int, error) {
+	tasks, err := ListTasks(ctx, client, Filter{})
+	if err != nil {
+		return 0, err
+	}
+	return len(tasks), writeTasks(w, tasks)
+}
+
+// ImportTasks stores the tasks read from JSON lines in r. Tasks with an ID
+// replace the task with that ID, so importing an export restores it; tasks
+// without one are added.
+func ImportTasks(ctx context.Context, client *datastore.Client, r io.Reader) (int, error) {
+	tasks, err := readTasks(r)
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(tasks); start += maxBatchSize {
+		batch := tasks[start:min(start+maxBatchSize, len(tasks))]
+		keys := make([]*datastore.Key, len(batch))
+		for i, t := range batch {
+			if t.id != 0 {
+				keys[i] = datastore.IDKey("Task", t.id, nil)
+			} else {
+				keys[i] = datastore.IncompleteKey("Task", nil)
+			}
+		}
+		if _, err := client.PutMulti(ctx, keys, batch); err != nil {
+			return start, fmt.Errorf("PutMulti: %w", err)
+		}
+	}
+	return len(tasks), nil
+}
+
+// writeTasks writes one JSON object per task.
+func writeTasks(w io.Writer, tasks []*Task) error {
+	enc := json.NewEncoder(w)
+	for _, t := range tasks {
+		if err := enc.Encode(taskRecord{ID: t.id, Task: t}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTasks reads the tasks written by writeTasks, skipping blank lines.
+func readTasks(r io.Reader) ([]*Task, error) {
+	var tasks []*Task
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		rec := taskRecord{Task: &Task{}}
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Desc == "" {
+			return nil, fmt.Errorf("line %d: task has no description", line)
+		}
+		if rec.Created.IsZero() {
+			rec.Created = time.Now()
+		}
+		rec.id = rec.ID
+		tasks = append(tasks, rec.Task)
+	}
+	return tasks, s.Err()
+}
@@ -17,22 +17,47 @@ package main
 // [START datastore_retrieve_entities]
 import (
 	"context"
-	"log"
+	"errors"
+	"time"
 
 	"cloud.google.com/go/datastore"
 )
 
-// ListTasks returns all the tasks in ascending order of creation time.
-func ListTasks(projectID string) ([]*Task, error) {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
+// Filter selects the tasks ListTasks returns. Combining filters needs the
+// composite indexes in index.yaml.
+type Filter struct {
+	// Done, if set, selects only done or only undone tasks.
+	Done *bool
+	// Tag, if set, selects only tasks with the tag.
+	Tag string
+	// Overdue selects only undone tasks whose due date has passed.
+	Overdue bool
+}
+
+// ListTasks returns the tasks matching the filter, overdue tasks by due date
+// and others in ascending order of creation time.
+func ListTasks(ctx context.Context, client *datastore.Client, f Filter) ([]*Task, error) {
+	query := datastore.NewQuery("Task")
+	if f.Tag != "" {
+		// An equality filter on a list property matches any of its values.
+		query = query.FilterField("tags", "=", f.Tag)
+	}
+	if f.Overdue {
+		if f.Done != nil && *f.Done {
+			return nil, errors.New("done tasks can't be overdue")
+		}
+		// The inequality filter's property must be sorted on first.
+		query = query.FilterField("done", "=", false).
+			FilterField("due", "<", time.Now()).
+			Order("due")
+	} else {
+		if f.Done != nil {
+			query = query.FilterField("done", "=", *f.Done)
+		}
+		query = query.Order("created")
 	}
 
 	var tasks []*Task
-	// Create a query to fetch all Task entities, ordered by "created".
-	query := datastore.NewQuery("Task").Order("created")
 	keys, err := client.GetAll(ctx, query, &tasks)
 	if err != nil {
 		return nil, err
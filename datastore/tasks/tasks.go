@@ -14,10 +14,22 @@
 
 // A simple command-line task list manager to demonstrate using the
 // cloud.google.com/go/datastore package.
+//
+// Run it without arguments for an interactive prompt, or with a command to
+// run just that command, for example from a script:
+//
+//	tasks new -priority 2 -due 2026-11-01 -tags home,errands Buy milk
+//	tasks list -tag home -undone
+//	tasks export > tasks.jsonl
+//
+// Set DATASTORE_EMULATOR_HOST to use the Datastore emulator.
 package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -25,123 +37,252 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/datastore"
 )
 
+// dateLayout is the format of due dates on the command line.
+const dateLayout = "2006-01-02"
+
+// errUsage is returned for commands with missing or invalid arguments.
+var errUsage = errors.New("invalid usage")
+
 func main() {
 	projID := os.Getenv("DATASTORE_PROJECT_ID")
 	if projID == "" {
 		log.Fatal(`You need to set the environment variable "DATASTORE_PROJECT_ID"`)
 	}
-	client, err := createClient(projID)
+	ctx := context.Background()
+	client, err := createClient(ctx, projID)
 	if err != nil {
 		log.Fatalf("Could not create datastore client: %v", err)
 	}
 	defer client.Close()
+	c := &cli{client: client, in: os.Stdin, out: os.Stdout}
+
+	// Run a single command non-interactively.
+	if len(os.Args) > 1 {
+		if err := c.run(ctx, os.Args[1:]); err != nil {
+			client.Close()
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Print welcome message.
 	fmt.Println("Cloud Datastore Task List")
 	fmt.Println()
-	usage()
+	usage(os.Stdout)
 
-	// Read commands from stdin.
+	// Read commands from stdin. The prompt owns stdin, so import needs a file.
+	c.in = nil
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
 
 	for scanner.Scan() {
-		cmd, args, n := parseCmd(scanner.Text())
-		switch cmd {
-		case "new":
-			if args == "" {
-				log.Printf("Missing description in %q command", cmd)
-				usage()
-				break
+		if err := c.run(ctx, strings.Fields(scanner.Text())); err != nil {
+			log.Print(err)
+			if errors.Is(err, errUsage) {
+				usage(os.Stdout)
 			}
-			key, err := AddTask(projID, args)
+		}
+		fmt.Print("> ")
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed reading stdin: %v", err)
+	}
+}
+
+// cli runs task list commands with a shared client.
+type cli struct {
+	client *datastore.Client
+	// in is read by import when no file is given. It is nil at the
+	// interactive prompt, where stdin holds the commands.
+	in io.Reader
+	// out receives command output.
+	out io.Writer
+}
+
+// run runs the command in args[0] with the arguments that follow.
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	switch cmd {
+	case "new":
+		priority := fs.Int("priority", 0, "")
+		due := fs.String("due", "", "")
+		tags := fs.String("tags", "", "")
+		if err := fs.Parse(args); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		task := &Task{Desc: strings.Join(fs.Args(), " "), Priority: *priority, Tags: splitTags(*tags)}
+		if task.Desc == "" {
+			return fmt.Errorf("%w: missing description in %q command", errUsage, cmd)
+		}
+		if *due != "" {
+			d, err := time.ParseInLocation(dateLayout, *due, time.Local)
 			if err != nil {
-				log.Printf("Failed to create task: %v", err)
-				break
+				return fmt.Errorf("%w: due date %q isn't YYYY-MM-DD", errUsage, *due)
 			}
-			fmt.Printf("Created new task with ID %d\n", key.ID)
+			task.Due = d
+		}
+		key, err := AddTask(ctx, c.client, task)
+		if err != nil {
+			return fmt.Errorf("Failed to create task: %w", err)
+		}
+		fmt.Fprintf(c.out, "Created new task with ID %d\n", key.ID)
 
-		case "done":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := MarkDone(projID, n); err != nil {
-				log.Printf("Failed to mark task done: %v", err)
-				break
+	case "done", "delete":
+		id, err := parseID(cmd, args)
+		if err != nil {
+			return err
+		}
+		if cmd == "done" {
+			if err := MarkDone(ctx, c.client, id); err != nil {
+				return fmt.Errorf("Failed to mark task done: %w", err)
 			}
-			fmt.Printf("Task %d marked done\n", n)
+			fmt.Fprintf(c.out, "Task %d marked done\n", id)
+			break
+		}
+		if err := DeleteTask(ctx, c.client, id); err != nil {
+			return fmt.Errorf("Failed to delete task: %w", err)
+		}
+		fmt.Fprintf(c.out, "Task %d deleted\n", id)
 
-		case "list":
-			tasks, err := ListTasks(projID)
+	case "list":
+		done := fs.Bool("done", false, "")
+		undone := fs.Bool("undone", false, "")
+		var f Filter
+		fs.StringVar(&f.Tag, "tag", "", "")
+		fs.BoolVar(&f.Overdue, "overdue", false, "")
+		if err := fs.Parse(args); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		switch {
+		case *done && *undone:
+			return fmt.Errorf("%w: -done and -undone are exclusive", errUsage)
+		case *done:
+			f.Done = done
+		case *undone:
+			f.Done = new(bool)
+		}
+		tasks, err := ListTasks(ctx, c.client, f)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch task list: %w", err)
+		}
+		PrintTasks(c.out, tasks)
+
+	case "export":
+		w := c.out
+		if len(args) > 0 {
+			f, err := os.Create(args[0])
 			if err != nil {
-				log.Printf("Failed to fetch task list: %v", err)
-				break
+				return err
 			}
-			PrintTasks(os.Stdout, tasks)
+			defer f.Close()
+			w = f
+		}
+		n, err := ExportTasks(ctx, c.client, w)
+		if err != nil {
+			return fmt.Errorf("Failed to export tasks: %w", err)
+		}
+		if w != c.out {
+			fmt.Fprintf(c.out, "Exported %d tasks to %s\n", n, args[0])
+		}
 
-		case "delete":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := DeleteTask(projID, n); err != nil {
-				log.Printf("Failed to delete task: %v", err)
-				break
+	case "import":
+		r := c.in
+		if len(args) == 0 && r == nil {
+			return fmt.Errorf("%w: missing file in %q command", errUsage, cmd)
+		}
+		if len(args) > 0 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
 			}
-			fmt.Printf("Task %d deleted\n", n)
-
-		default:
-			log.Printf("Unknown command %q", cmd)
-			usage()
+			defer f.Close()
+			r = f
+		}
+		n, err := ImportTasks(ctx, c.client, r)
+		if err != nil {
+			return fmt.Errorf("Failed to import tasks after %d: %w", n, err)
 		}
+		fmt.Fprintf(c.out, "Imported %d tasks\n", n)
 
-		fmt.Print("> ")
+	case "help":
+		usage(c.out)
+
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Failed reading stdin: %v", err)
+// parseID parses the task ID argument of cmd.
+func parseID(cmd string, args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: missing numerical task ID in %q command", errUsage, cmd)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: invalid task ID %q in %q command", errUsage, args[0], cmd)
+	}
+	return id, nil
+}
+
+// splitTags splits a comma-separated list of tags, dropping empty ones.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
 	}
+	return tags
 }
 
 // PrintTasks prints the tasks to the given writer.
 func PrintTasks(w io.Writer, tasks []*Task) {
 	// Use a tab writer to help make results pretty.
 	tw := tabwriter.NewWriter(w, 8, 8, 1, ' ', 0) // Min cell size of 8.
-	fmt.Fprintf(tw, "ID\tDescription\tStatus\n")
+	fmt.Fprintf(tw, "ID\tDescription\tPriority\tDue\tTags\tStatus\n")
+	now := time.Now()
 	for _, t := range tasks {
-		if t.Done {
-			fmt.Fprintf(tw, "%d\t%s\tdone\n", t.id, t.Desc)
-		} else {
-			fmt.Fprintf(tw, "%d\t%s\tcreated %v\n", t.id, t.Desc, t.Created)
+		due := ""
+		if !t.Due.IsZero() {
+			due = t.Due.Format(dateLayout)
 		}
+		status := fmt.Sprintf("created %v", t.Created.Format(time.DateTime))
+		switch {
+		case t.Done:
+			status = "done"
+		case !t.Due.IsZero() && t.Due.Before(now):
+			status = "overdue"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", t.id, t.Desc, t.Priority, due, strings.Join(t.Tags, ","), status)
 	}
 	tw.Flush()
 }
 
-func usage() {
-	fmt.Print(`Usage:
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
 
-  new <description>  Adds a task with a description <description>
+  new [-priority <n>] [-due <YYYY-MM-DD>] [-tags <tag,...>] <description>
+                     Adds a task with a description <description>
   done <task-id>     Marks a task as done
-  list               Lists all tasks by creation time
+  list [-done | -undone] [-tag <tag>] [-overdue]
+                     Lists tasks by creation time, or overdue tasks by due date
   delete <task-id>   Deletes a task
+  export [<file>]    Writes all tasks as JSON lines to <file> or stdout
+  import [<file>]    Adds or replaces tasks from JSON lines in <file> or stdin;
+                     <file> is required at the interactive prompt
+  help               Shows this message
 `)
 }
-
-// parseCmd splits a line into a command and optional extra args.
-// n will be set if the extra args can be parsed as an int64.
-func parseCmd(line string) (cmd, args string, n int64) {
-	if f := strings.Fields(line); len(f) > 0 {
-		cmd = f[0]
-		args = strings.Join(f[1:], " ")
-	}
-	if i, err := strconv.ParseInt(args, 10, 64); err == nil {
-		n = i
-	}
-	return cmd, args, n
-}
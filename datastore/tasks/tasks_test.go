@@ -17,9 +17,10 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,19 +28,34 @@ import (
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 )
 
-var client *datastore.Client
+// newTestClient returns a client for the Datastore emulator if
+// DATASTORE_EMULATOR_HOST is set, and for the test project otherwise.
+func newTestClient(t *testing.T) *datastore.Client {
+	t.Helper()
+	projectID := "test-project"
+	if os.Getenv("DATASTORE_EMULATOR_HOST") == "" {
+		projectID = testutil.SystemTest(t).ProjectID
+	}
+	client, err := createClient(context.Background(), projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
 
-func TestMain(m *testing.M) {
-	ctx := context.Background()
-	if tc, ok := testutil.ContextMain(m); ok {
-		var err error
-		client, err = datastore.NewClient(ctx, tc.ProjectID)
-		if err != nil {
-			log.Fatalf("datastore.NewClient: %v", err)
-		}
-		defer client.Close()
+// requireEmulator skips tests of queries that need the composite indexes in
+// index.yaml, which the emulator doesn't require.
+func requireEmulator(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DATASTORE_EMULATOR_HOST") == "" {
+		t.Skip("DATASTORE_EMULATOR_HOST not set")
 	}
-	os.Exit(m.Run())
+}
+
+// makeTag returns a tag unique to the test, to filter its tasks by.
+func makeTag(t *testing.T) string {
+	return fmt.Sprintf("t-%s-%d", strings.ReplaceAll(t.Name(), "/", "-"), time.Now().UnixNano())
 }
 
 func makeDesc() string {
@@ -47,59 +63,232 @@ func makeDesc() string {
 }
 
 func TestAddMarkDelete(t *testing.T) {
-	tc := testutil.SystemTest(t)
-
-	desc := makeDesc()
+	ctx := context.Background()
+	client := newTestClient(t)
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(ctx, client, &Task{Desc: makeDesc()})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err := MarkDone(tc.ProjectID, k.ID); err != nil {
+	if err := MarkDone(ctx, client, k.ID); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := DeleteTask(tc.ProjectID, k.ID); err != nil {
+	if err := DeleteTask(ctx, client, k.ID); err != nil {
 		t.Fatal(err)
 	}
 }
 
 func TestList(t *testing.T) {
-	t.Skip("Flaky. Eventual consistency. Re-enable once the datastore emulator works with gRPC.")
-
-	tc := testutil.SystemTest(t)
+	requireEmulator(t)
+	ctx := context.Background()
+	client := newTestClient(t)
+	tag := makeTag(t)
 
 	desc := makeDesc()
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(ctx, client, &Task{Desc: desc, Tags: []string{tag}})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err := listAndGetTask(tc.ProjectID, desc)
+	foundTask, err := listAndGetTask(ctx, client, tag, desc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if got, want := foundTask.id, k.ID; got != want {
 		t.Errorf("k.ID: got %d, want %d", got, want)
 	}
 
-	if err := MarkDone(tc.ProjectID, foundTask.id); err != nil {
+	if err := MarkDone(ctx, client, foundTask.id); err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err = listAndGetTask(tc.ProjectID, desc)
+	foundTask, err = listAndGetTask(ctx, client, tag, desc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if !foundTask.Done {
 		t.Error("foundTask.Done: got false, want true")
 	}
 
-	if err := DeleteTask(tc.ProjectID, foundTask.id); err != nil {
+	if err := DeleteTask(ctx, client, foundTask.id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	requireEmulator(t)
+	ctx := context.Background()
+	client := newTestClient(t)
+	tag := makeTag(t)
+	now := time.Now()
+
+	tasks := map[string]*Task{
+		"overdue":  {Desc: "overdue", Due: now.Add(-time.Hour), Tags: []string{tag}},
+		"upcoming": {Desc: "upcoming", Due: now.Add(time.Hour), Tags: []string{tag, "home"}},
+		"no due":   {Desc: "no due", Tags: []string{tag, "home"}},
+		"done":     {Desc: "done", Due: now.Add(-2 * time.Hour), Done: true, Tags: []string{tag}},
+	}
+	for _, task := range tasks {
+		k, err := AddTask(ctx, client, task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { DeleteTask(ctx, client, k.ID) })
+	}
+
+	done, undone := true, false
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "tag", filter: Filter{Tag: tag}, want: []string{"overdue", "upcoming", "no due", "done"}},
+		{name: "done", filter: Filter{Tag: tag, Done: &done}, want: []string{"done"}},
+		{name: "undone", filter: Filter{Tag: tag, Done: &undone}, want: []string{"overdue", "upcoming", "no due"}},
+		{name: "overdue", filter: Filter{Tag: tag, Overdue: true}, want: []string{"overdue"}},
+	}
+	for _, tc := range tests {
+		got, err := ListTasks(ctx, client, tc.filter)
+		if err != nil {
+			t.Errorf("%s: ListTasks: %v", tc.name, err)
+			continue
+		}
+		var descs []string
+		for _, task := range got {
+			descs = append(descs, task.Desc)
+		}
+		// Tasks are listed by creation time, but may have been created in
+		// the same instant, so compare them as sets.
+		if !sameElements(descs, tc.want) {
+			t.Errorf("%s: ListTasks = %q, want %q", tc.name, descs, tc.want)
+		}
+	}
+
+	if _, err := ListTasks(ctx, client, Filter{Done: &done, Overdue: true}); err == nil {
+		t.Error("ListTasks of done overdue tasks succeeded, want error")
+	}
+}
+
+func TestCLI(t *testing.T) {
+	requireEmulator(t)
+	ctx := context.Background()
+	var out bytes.Buffer
+	c := &cli{client: newTestClient(t), out: &out}
+	tag := makeTag(t)
+
+	run := func(args ...string) string {
+		t.Helper()
+		out.Reset()
+		if err := c.run(ctx, args); err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+		return out.String()
+	}
+	var id int64
+	if _, err := fmt.Sscanf(run("new", "-priority", "2", "-due", "2020-01-02", "-tags", tag+",home", "Buy", "milk"), "Created new task with ID %d", &id); err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if got := run("list", "-tag", tag, "-overdue"); !strings.Contains(got, "Buy milk") || !strings.Contains(got, "2020-01-02") {
+		t.Errorf("list -overdue =\n%s\nwant the new task", got)
+	}
+	run("done", fmt.Sprint(id))
+	if got := run("list", "-tag", tag, "-undone"); strings.Contains(got, "Buy milk") {
+		t.Errorf("list -undone =\n%s\nwant no done task", got)
+	}
+
+	// Export the task, delete it and import it again.
+	exported := run("export")
+	var line string
+	for _, l := range strings.Split(exported, "\n") {
+		if strings.Contains(l, tag) {
+			line = l
+		}
+	}
+	if line == "" {
+		t.Fatalf("export =\n%s\nwant the task", exported)
+	}
+	run("delete", fmt.Sprint(id))
+	c.in = strings.NewReader(line + "\n")
+	if got := run("import"); got != "Imported 1 tasks\n" {
+		t.Errorf("import = %q", got)
+	}
+	got, err := ListTasks(ctx, c.client, Filter{Tag: tag})
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(got) != 1 || got[0].id != id || !got[0].Done || got[0].Priority != 2 {
+		t.Errorf("imported tasks = %+v, want task %d restored", got, id)
+	}
+	run("delete", fmt.Sprint(id))
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	c := &cli{out: &bytes.Buffer{}}
+	for _, args := range [][]string{
+		nil,
+		{"frobnicate"},
+		{"new"},
+		{"new", "-due", "tomorrow", "Buy milk"},
+		{"new", "-priority", "high", "Buy milk"},
+		{"done"},
+		{"done", "abc"},
+		{"delete", "1", "2"},
+		{"list", "-done", "-undone"},
+		{"import"},
+	} {
+		if err := c.run(context.Background(), args); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q) = %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestReadWriteTasks(t *testing.T) {
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []*Task{
+		{Desc: "Buy milk", Created: due.Add(-time.Hour), Priority: 1, Due: due, Tags: []string{"home"}, id: 42},
+		{Desc: "Call Bob", Created: due, Done: true},
+	}
+	var b bytes.Buffer
+	if err := writeTasks(&b, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), `"due"`+`:"0001`) {
+		t.Errorf("export includes zero due dates:\n%s", b.String())
+	}
+	got, err := readTasks(strings.NewReader(b.String() + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].id != 42 || !got[0].Due.Equal(due) || got[0].Tags[0] != "home" || got[1].id != 0 || !got[1].Done {
+		t.Errorf("readTasks(writeTasks(tasks)) = %+v %+v, want the tasks", got[0], got[1])
+	}
+
+	for _, in := range []string{`{"description": "x"`, `{"done": true}`} {
+		if _, err := readTasks(strings.NewReader(in)); err == nil {
+			t.Errorf("readTasks(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestPrintTasks(t *testing.T) {
+	var b bytes.Buffer
+	PrintTasks(&b, []*Task{
+		{Desc: "Buy milk", Due: time.Now().Add(-time.Hour), Tags: []string{"home", "errands"}, id: 1},
+		{Desc: "Call Bob", Done: true, id: 2},
+	})
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("PrintTasks printed %d lines, want 3:\n%s", len(lines), b.String())
+	}
+	if !strings.Contains(lines[1], "home,errands") || !strings.HasSuffix(lines[1], "overdue") {
+		t.Errorf("overdue task line = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "done") {
+		t.Errorf("done task line = %q", lines[2])
+	}
 }
 
 func TestCreateClientWithDatabase(t *testing.T) {
@@ -114,9 +303,8 @@ func TestCreateClientWithDatabase(t *testing.T) {
 	}
 }
 
-func listAndGetTask(projectID string, desc string) (*Task, error) {
-
-	tasks, err := ListTasks(projectID)
+func listAndGetTask(ctx context.Context, client *datastore.Client, tag, desc string) (*Task, error) {
+	tasks, err := ListTasks(ctx, client, Filter{Tag: tag})
 	if err != nil {
 		return nil, err
 	}
@@ -133,3 +321,22 @@ func listAndGetTask(projectID string, desc string) (*Task, error) {
 
 	return foundTask, nil
 }
+
+func sameElements(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -17,24 +17,17 @@ package main
 // [START datastore_update_entity]
 import (
 	"context"
-	"log"
 
 	"cloud.google.com/go/datastore"
 )
 
 // MarkDone marks the task done with the given ID.
-func MarkDone(projectID string, taskID int64) error {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
-	}
-	defer client.Close()
+func MarkDone(ctx context.Context, client *datastore.Client, taskID int64) error {
 	// Create a key using the given integer ID.
 	key := datastore.IDKey("Task", taskID, nil)
 
 	// In a transaction load each task, set done to true and store.
-	_, err = client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+	_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
 		var task Task
 		if err := tx.Get(key, &task); err != nil {
 			return err
@@ -0,0 +1,50 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+)
+
+// AuthenticatedWebhookRequest is HandleWebhookRequest for agents whose
+// fulfillment is configured with basic authentication. It accepts requests
+// with the username and password in the WEBHOOK_USERNAME and
+// WEBHOOK_PASSWORD environment variables and refuses all requests if they
+// aren't set.
+//
+// Deploy it with --entry-point=AuthenticatedWebhookRequest and set the same
+// credentials in the agent's Fulfillment settings.
+func AuthenticatedWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	user, pass := os.Getenv("WEBHOOK_USERNAME"), os.Getenv("WEBHOOK_PASSWORD")
+	if user == "" || pass == "" {
+		log.Print("WEBHOOK_USERNAME and WEBHOOK_PASSWORD must be set")
+		http.Error(w, "Webhook authentication isn't configured", http.StatusInternalServerError)
+		return
+	}
+	u, p, ok := r.BasicAuth()
+	// Compare both fields in constant time so a mismatch doesn't reveal which
+	// one was wrong, or how much of it.
+	userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(p), []byte(pass)) == 1
+	if !ok || !userOK || !passOK {
+		w.Header().Set("WWW-Authenticate", `Basic realm="webhook"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	HandleWebhookRequest(w, r)
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticatedWebhookRequest(t *testing.T) {
+	const body = `{"queryResult": {"intent": {"displayName": "Default Welcome Intent"}}}`
+	tests := []struct {
+		name       string
+		user, pass string
+		want       int
+	}{
+		{name: "valid", user: "dialogflow", pass: "s3cret", want: http.StatusOK},
+		{name: "wrong password", user: "dialogflow", pass: "guess", want: http.StatusUnauthorized},
+		{name: "no credentials", want: http.StatusUnauthorized},
+	}
+	t.Setenv("WEBHOOK_USERNAME", "dialogflow")
+	t.Setenv("WEBHOOK_PASSWORD", "s3cret")
+	for _, tc := range tests {
+		req := httptest.NewRequest("POST", "/handler", strings.NewReader(body))
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.pass)
+		}
+		rr := httptest.NewRecorder()
+		AuthenticatedWebhookRequest(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, rr.Code, tc.want)
+		}
+		if tc.want == http.StatusOK && !strings.Contains(rr.Body.String(), "Welcome from Dialogflow Go Webhook") {
+			t.Errorf("%s: body %q, want the welcome response", tc.name, rr.Body.String())
+		}
+	}
+
+	t.Setenv("WEBHOOK_PASSWORD", "")
+	req := httptest.NewRequest("POST", "/handler", strings.NewReader(body))
+	req.SetBasicAuth("dialogflow", "")
+	rr := httptest.NewRecorder()
+	AuthenticatedWebhookRequest(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("unconfigured: status %d, want 500", rr.Code)
+	}
+}
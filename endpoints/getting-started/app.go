@@ -27,21 +27,17 @@ import (
 )
 
 func main() {
-	r := mux.NewRouter()
-
-	r.Path("/echo").Methods("POST").
-		HandlerFunc(echoHandler)
-
-	r.Path("/auth/info/googlejwt").Methods("GET").
-		HandlerFunc(authInfoHandler)
-	r.Path("/auth/info/googleidtoken").Methods("GET").
-		HandlerFunc(authInfoHandler)
-	r.Path("/auth/info/firebase").Methods("GET", "OPTIONS").
-		Handler(corsHandler(authInfoHandler))
-	r.Path("/auth/info/auth0").Methods("GET").
-		HandlerFunc(authInfoHandler)
+	// Validate JWTs in the backend when it isn't deployed behind the
+	// Endpoints proxy.
+	auth, err := authFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auth != nil {
+		log.Printf("Validating JWTs for %d security schemes", len(auth.providers))
+	}
 
-	http.Handle("/", r)
+	http.Handle("/", newRouter(auth))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -55,6 +51,26 @@ func main() {
 	}
 }
 
+// newRouter returns a router serving the paths of openapi.yaml. If auth is
+// nil, the authentication info is read from the Endpoints proxy's header.
+func newRouter(auth *authenticator) *mux.Router {
+	r := mux.NewRouter()
+
+	r.Path("/echo").Methods("POST").
+		HandlerFunc(echoHandler)
+
+	r.Path("/auth/info/googlejwt").Methods("GET").
+		HandlerFunc(auth.require(schemeGoogleJWT, authInfoHandler))
+	r.Path("/auth/info/googleidtoken").Methods("GET").
+		HandlerFunc(auth.require(schemeGoogleIDToken, authInfoHandler))
+	r.Path("/auth/info/firebase").Methods("GET", "OPTIONS").
+		Handler(corsHandler(auth.require(schemeFirebase, authInfoHandler)))
+	r.Path("/auth/info/auth0").Methods("GET").
+		HandlerFunc(auth.require(schemeAuth0, authInfoHandler))
+
+	return r
+}
+
 // echoHandler reads a JSON object from the body, and writes it back out.
 func echoHandler(w http.ResponseWriter, r *http.Request) {
 	var msg interface{}
@@ -72,6 +88,7 @@ func echoHandler(w http.ResponseWriter, r *http.Request) {
 		errorf(w, http.StatusInternalServerError, "Could not marshal JSON: %v", err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(b)
 }
 
@@ -93,6 +110,7 @@ func (h corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func authInfoHandler(w http.ResponseWriter, r *http.Request) {
 	encodedInfo := r.Header.Get("X-Endpoint-API-UserInfo")
 	if encodedInfo == "" {
+		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"id": "anonymous"}`))
 		return
 	}
@@ -102,6 +120,7 @@ func authInfoHandler(w http.ResponseWriter, r *http.Request) {
 		errorf(w, http.StatusInternalServerError, "Could not decode auth info: %v", err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(b)
 }
 
@@ -119,9 +138,11 @@ func errorf(w http.ResponseWriter, code int, format string, a ...interface{}) {
 
 	b, err := json.Marshal(out)
 	if err != nil {
-		http.Error(w, `{"code": 500, "message": "Could not format JSON for original message."}`, 500)
-		return
+		b, code = []byte(`{"code": 500, "message": "Could not format JSON for original message."}`), 500
 	}
 
-	http.Error(w, string(b), code)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(b)
 }
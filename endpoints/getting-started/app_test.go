@@ -0,0 +1,399 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the part of a Swagger 2.0 document checked by the tests.
+type openAPISpec struct {
+	Produces            []string                         `yaml:"produces"`
+	Paths               map[string]map[string]*operation `yaml:"paths"`
+	Definitions         map[string]*schema               `yaml:"definitions"`
+	SecurityDefinitions map[string]*securityDefinition   `yaml:"securityDefinitions"`
+}
+
+type operation struct {
+	OperationID string                `yaml:"operationId"`
+	Produces    []string              `yaml:"produces"`
+	Parameters  []*parameter          `yaml:"parameters"`
+	Responses   map[string]*response  `yaml:"responses"`
+	Security    []map[string][]string `yaml:"security"`
+}
+
+type parameter struct {
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *schema `yaml:"schema"`
+}
+
+type response struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Properties map[string]*schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *schema            `yaml:"items"`
+}
+
+type securityDefinition struct {
+	Type      string `yaml:"type"`
+	Issuer    string `yaml:"x-google-issuer"`
+	JWKSURI   string `yaml:"x-google-jwks_uri"`
+	Audiences string `yaml:"x-google-audiences"`
+}
+
+func loadSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	b, err := os.ReadFile("openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec openAPISpec
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		t.Fatalf("could not parse openapi.yaml: %v", err)
+	}
+	if len(spec.Paths) == 0 {
+		t.Fatal("openapi.yaml has no paths")
+	}
+	return &spec
+}
+
+// resolve returns the definition s refers to, or s.
+func (spec *openAPISpec) resolve(s *schema) (*schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	def, ok := spec.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	if !ok {
+		return nil, fmt.Errorf("unknown definition %q", s.Ref)
+	}
+	return def, nil
+}
+
+// validate checks that the decoded JSON value v conforms to s.
+func (spec *openAPISpec) validate(s *schema, v interface{}) error {
+	s, err := spec.resolve(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.Type == "object" || (s.Type == "" && s.Properties != nil):
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("got %T, want an object", v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("missing required property %q", name)
+			}
+		}
+		for name, prop := range s.Properties {
+			if pv, ok := obj[name]; ok {
+				if err := spec.validate(prop, pv); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+	case s.Type == "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("got %T, want an array", v)
+		}
+		for i, item := range arr {
+			if err := spec.validate(s.Items, item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case s.Type == "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("got %T, want a string", v)
+		}
+	case s.Type == "integer", s.Type == "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("got %T, want a number", v)
+		}
+	case s.Type == "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("got %T, want a boolean", v)
+		}
+	}
+	return nil
+}
+
+// sample returns a value conforming to s.
+func (spec *openAPISpec) sample(t *testing.T, s *schema) interface{} {
+	t.Helper()
+	s, err := spec.resolve(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch s.Type {
+	case "array":
+		return []interface{}{spec.sample(t, s.Items)}
+	case "string":
+		return "sample"
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	}
+	obj := make(map[string]interface{})
+	for name, prop := range s.Properties {
+		obj[name] = spec.sample(t, prop)
+	}
+	return obj
+}
+
+// jwtScheme returns the JWT security scheme of op, if it has one.
+func (spec *openAPISpec) jwtScheme(op *operation) (string, bool) {
+	for _, requirement := range op.Security {
+		for name := range requirement {
+			if def, ok := spec.SecurityDefinitions[name]; ok && def.Type == "oauth2" {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// TestOpenAPIRoutes checks that the router serves exactly the operations of
+// openapi.yaml.
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	served := make(map[string]bool)
+	err := newRouter(nil).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			// CORS preflight requests aren't API operations.
+			if method == http.MethodOptions {
+				continue
+			}
+			served[method+" "+path] = true
+			if spec.Paths[path][strings.ToLower(method)] == nil {
+				t.Errorf("%s %s is not in openapi.yaml", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			if !served[strings.ToUpper(method)+" "+path] {
+				t.Errorf("%s %s of openapi.yaml is not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestOpenAPIConformance sends a request conforming to openapi.yaml to each
+// of its operations, and checks that the response conforms to it too, both
+// behind the Endpoints proxy and when the server validates JWTs itself.
+func TestOpenAPIConformance(t *testing.T) {
+	spec := loadSpec(t)
+
+	keys := newTestKeys(t)
+	auth := &authenticator{providers: make(map[string]provider)}
+	for name, def := range spec.SecurityDefinitions {
+		if def.Type == "oauth2" {
+			auth.providers[name] = newJWKSProvider(testIssuer+"/"+name, []string{testAudience}, keys.srv.URL)
+		}
+	}
+
+	for _, mode := range []struct {
+		name string
+		auth *authenticator
+	}{
+		{name: "proxy"},
+		{name: "jwt", auth: auth},
+	} {
+		router := newRouter(mode.auth)
+		for path, ops := range spec.Paths {
+			for method, op := range ops {
+				t.Run(fmt.Sprintf("%s/%s %s", mode.name, strings.ToUpper(method), path), func(t *testing.T) {
+					var body interface{}
+					for _, param := range op.Parameters {
+						if param.In == "body" && param.Required {
+							body = spec.sample(t, param.Schema)
+						}
+					}
+					var b []byte
+					if body != nil {
+						var err error
+						if b, err = json.Marshal(body); err != nil {
+							t.Fatal(err)
+						}
+					}
+					req := httptest.NewRequest(strings.ToUpper(method), path, bytes.NewReader(b))
+					if body != nil {
+						req.Header.Set("Content-Type", "application/json")
+					}
+
+					scheme, authenticated := spec.jwtScheme(op)
+					if authenticated {
+						if mode.auth == nil {
+							info := base64.StdEncoding.EncodeToString([]byte(`{"id": "user-1", "email": "user@example.com"}`))
+							req.Header.Set(userInfoHeader, info)
+						} else {
+							token := keys.sign(t, validClaims(map[string]interface{}{"iss": testIssuer + "/" + scheme}))
+							req.Header.Set("Authorization", "Bearer "+token)
+						}
+					}
+
+					rec := httptest.NewRecorder()
+					router.ServeHTTP(rec, req)
+
+					resp, ok := op.Responses[fmt.Sprint(rec.Code)]
+					if !ok {
+						t.Fatalf("got undocumented status %d: %s", rec.Code, rec.Body)
+					}
+					produces := op.Produces
+					if produces == nil {
+						produces = spec.Produces
+					}
+					mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+					if err != nil || !slices.Contains(produces, mediaType) {
+						t.Errorf("got Content-Type %q, want one of %q", rec.Header().Get("Content-Type"), produces)
+					}
+					var got interface{}
+					if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+						t.Fatalf("response %s is not JSON: %v", rec.Body, err)
+					}
+					if resp.Schema != nil {
+						if err := spec.validate(resp.Schema, got); err != nil {
+							t.Errorf("response %s does not conform to openapi.yaml: %v", rec.Body, err)
+						}
+					}
+
+					if body != nil {
+						if want := string(b); strings.TrimSpace(rec.Body.String()) != want {
+							t.Errorf("got %s, want %s echoed", rec.Body, want)
+						}
+					}
+					if authenticated {
+						var info struct {
+							ID    string `json:"id"`
+							Email string `json:"email"`
+						}
+						json.Unmarshal(rec.Body.Bytes(), &info)
+						if info.ID != "user-1" || info.Email != "user@example.com" {
+							t.Errorf("got auth info %s, want user-1's", rec.Body)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestOpenAPIUnauthenticated checks that, when the server validates JWTs
+// itself, the operations with a JWT security requirement reject requests
+// without a token with a JSON error.
+func TestOpenAPIUnauthenticated(t *testing.T) {
+	spec := loadSpec(t)
+	keys := newTestKeys(t)
+	auth := &authenticator{providers: make(map[string]provider)}
+	for name := range spec.SecurityDefinitions {
+		auth.providers[name] = newJWKSProvider(testIssuer, []string{testAudience}, keys.srv.URL)
+	}
+	router := newRouter(auth)
+
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			if _, ok := spec.jwtScheme(op); !ok {
+				continue
+			}
+			req := httptest.NewRequest(strings.ToUpper(method), path, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: got status %d, want %d", strings.ToUpper(method), path, rec.Code, http.StatusUnauthorized)
+				continue
+			}
+			var out struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: got %s, want a JSON error", strings.ToUpper(method), path, rec.Body)
+			}
+		}
+	}
+}
+
+// TestSecurityDefinitions checks that the issuers and keys the server uses
+// by default match openapi.yaml.
+func TestSecurityDefinitions(t *testing.T) {
+	spec := loadSpec(t)
+
+	for _, scheme := range []string{schemeGoogleJWT, schemeGoogleIDToken, schemeFirebase, schemeAuth0} {
+		if def := spec.SecurityDefinitions[scheme]; def == nil || def.Type != "oauth2" {
+			t.Errorf("openapi.yaml has no oauth2 security definition %s", scheme)
+		}
+	}
+	if def := spec.SecurityDefinitions[schemeGoogleJWT]; def != nil && !strings.HasPrefix(def.JWKSURI, serviceAccountKeysURL) {
+		t.Errorf("google_jwt keys %q, want a service account's under %q", def.JWKSURI, serviceAccountKeysURL)
+	}
+	if def := spec.SecurityDefinitions[schemeGoogleIDToken]; def != nil {
+		if !slices.Contains(googleIDTokenIssuers, def.Issuer) {
+			t.Errorf("google_id_token issuer %q, want one of %q", def.Issuer, googleIDTokenIssuers)
+		}
+	}
+	if def := spec.SecurityDefinitions[schemeFirebase]; def != nil {
+		if project := strings.TrimPrefix(def.Issuer, firebaseIssuerPrefix); project != def.Audiences {
+			t.Errorf("firebase issuer %q and audience %q, want %q and the project ID", def.Issuer, def.Audiences, firebaseIssuerPrefix+"PROJECT")
+		}
+		// The server reads the same keys as a JWK set rather than as X.509
+		// certificates.
+		if got := strings.Replace(def.JWKSURI, "/metadata/x509/", "/jwk/", 1); got != firebaseKeysURL {
+			t.Errorf("firebase keys %q, want the certificates of %q", def.JWKSURI, firebaseKeysURL)
+		}
+	}
+	if def := spec.SecurityDefinitions[schemeAuth0]; def != nil {
+		domain := strings.TrimSuffix(strings.TrimPrefix(def.Issuer, "https://"), "/")
+		if want := "https://" + domain + "/.well-known/jwks.json"; def.JWKSURI != want {
+			t.Errorf("auth0_jwk keys %q, want %q", def.JWKSURI, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"google.golang.org/api/idtoken"
+)
+
+// The security schemes of openapi.yaml which authenticate with JWTs.
+const (
+	schemeGoogleJWT     = "google_jwt"
+	schemeGoogleIDToken = "google_id_token"
+	schemeFirebase      = "firebase"
+	schemeAuth0         = "auth0_jwk"
+)
+
+// The issuers and signing keys of Google ID tokens, Firebase ID tokens and
+// service account JWTs.
+const (
+	firebaseIssuerPrefix  = "https://securetoken.google.com/"
+	firebaseKeysURL       = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+	serviceAccountKeysURL = "https://www.googleapis.com/service_accounts/v1/jwk/"
+)
+
+var googleIDTokenIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// userInfoHeader is the header in which the Endpoints proxy passes the
+// claims of a validated JWT to the backend.
+const userInfoHeader = "X-Endpoint-API-UserInfo"
+
+// authenticator validates JWTs in the backend, for when it isn't deployed
+// behind the Endpoints proxy (ESP). A nil authenticator trusts the proxy.
+type authenticator struct {
+	// providers maps the security schemes of openapi.yaml to the provider
+	// validating their JWTs.
+	providers map[string]provider
+}
+
+// authFromEnv returns an authenticator configured by the environment, or nil
+// if VALIDATE_JWT isn't set to true.
+func authFromEnv() (*authenticator, error) {
+	if v := os.Getenv("VALIDATE_JWT"); v == "" {
+		return nil, nil
+	} else if ok, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid VALIDATE_JWT: %w", err)
+	} else if !ok {
+		return nil, nil
+	}
+
+	a := &authenticator{providers: make(map[string]provider)}
+	if issuer := os.Getenv("GOOGLE_JWT_ISSUER"); issuer != "" {
+		audiences := splitList(os.Getenv("GOOGLE_JWT_AUDIENCE"))
+		if len(audiences) == 0 {
+			return nil, errors.New("GOOGLE_JWT_AUDIENCE is required with GOOGLE_JWT_ISSUER")
+		}
+		keysURL := os.Getenv("GOOGLE_JWT_JWKS_URI")
+		if keysURL == "" {
+			keysURL = serviceAccountKeysURL + issuer
+		}
+		a.providers[schemeGoogleJWT] = newJWKSProvider(issuer, audiences, keysURL)
+	}
+	if audiences := splitList(os.Getenv("GOOGLE_ID_TOKEN_AUDIENCE")); len(audiences) > 0 {
+		a.providers[schemeGoogleIDToken] = &idTokenProvider{audiences: audiences}
+	}
+	if project := os.Getenv("FIREBASE_PROJECT"); project != "" {
+		a.providers[schemeFirebase] = newJWKSProvider(firebaseIssuerPrefix+project, []string{project}, firebaseKeysURL)
+	}
+	if domain := os.Getenv("AUTH0_DOMAIN"); domain != "" {
+		audiences := splitList(os.Getenv("AUTH0_AUDIENCE"))
+		if len(audiences) == 0 {
+			return nil, errors.New("AUTH0_AUDIENCE is required with AUTH0_DOMAIN")
+		}
+		a.providers[schemeAuth0] = newJWKSProvider("https://"+domain+"/", audiences, "https://"+domain+"/.well-known/jwks.json")
+	}
+	if len(a.providers) == 0 {
+		return nil, errors.New("VALIDATE_JWT is set, but no issuer is configured")
+	}
+	return a, nil
+}
+
+// splitList splits a comma-separated list, such as the audiences of an
+// issuer.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// require wraps h to only serve requests with a valid JWT for the security
+// scheme. Like the Endpoints proxy, it passes the JWT's claims to h in the
+// X-Endpoint-API-UserInfo header. If a is nil, h is returned unchanged.
+func (a *authenticator) require(scheme string, h http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Without the proxy, clients could set the header themselves.
+		r.Header.Del(userInfoHeader)
+
+		p, ok := a.providers[scheme]
+		if !ok {
+			errorf(w, http.StatusUnauthorized, "No issuer is configured for %s", scheme)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			errorf(w, http.StatusUnauthorized, "JWT validation failed: Missing or invalid credentials")
+			return
+		}
+		c, err := p.verify(r.Context(), token)
+		if err != nil {
+			errorf(w, http.StatusUnauthorized, "JWT validation failed: %v", err)
+			return
+		}
+
+		b, err := json.Marshal(userInfo{
+			ID:        c.Subject,
+			Email:     c.Email,
+			Issuer:    c.Issuer,
+			Audiences: c.Audience,
+		})
+		if err != nil {
+			errorf(w, http.StatusInternalServerError, "Could not encode auth info: %v", err)
+			return
+		}
+		r.Header.Set(userInfoHeader, base64.StdEncoding.EncodeToString(b))
+		h(w, r)
+	}
+}
+
+// userInfo is the content of the X-Endpoint-API-UserInfo header.
+type userInfo struct {
+	ID        string   `json:"id"`
+	Email     string   `json:"email,omitempty"`
+	Issuer    string   `json:"issuer"`
+	Audiences []string `json:"audiences"`
+}
+
+// provider validates the JWTs of a security scheme.
+type provider interface {
+	// verify checks the signature and claims of token, and returns its
+	// claims.
+	verify(ctx context.Context, token string) (*claims, error)
+}
+
+// claims are the claims of a validated JWT passed to the handlers.
+type claims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Email    string
+}
+
+// jwksProvider validates the JWTs of an issuer signing them with the keys of
+// a JWK set, such as a service account, Firebase or Auth0.
+type jwksProvider struct {
+	issuer    string
+	audiences []string
+	keysURL   string
+	verifier  *oidc.IDTokenVerifier
+}
+
+func newJWKSProvider(issuer string, audiences []string, keysURL string) *jwksProvider {
+	// The key set is fetched when first needed, and cached.
+	keys := oidc.NewRemoteKeySet(context.Background(), keysURL)
+	return &jwksProvider{
+		issuer:    issuer,
+		audiences: audiences,
+		keysURL:   keysURL,
+		// verify checks the audience, since there may be several.
+		verifier: oidc.NewVerifier(issuer, keys, &oidc.Config{SkipClientIDCheck: true}),
+	}
+}
+
+func (p *jwksProvider) verify(ctx context.Context, token string) (*claims, error) {
+	t, err := p.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(t.Audience, func(aud string) bool { return slices.Contains(p.audiences, aud) }) {
+		return nil, fmt.Errorf("audiences %q not allowed", t.Audience)
+	}
+	var c struct {
+		Email string `json:"email"`
+	}
+	if err := t.Claims(&c); err != nil {
+		return nil, err
+	}
+	return &claims{Issuer: t.Issuer, Subject: t.Subject, Audience: t.Audience, Email: c.Email}, nil
+}
+
+// validateIDToken validates a Google ID token, checking its audience if
+// one is given. Tests replace it.
+var validateIDToken = idtoken.Validate
+
+// idTokenProvider validates Google ID tokens.
+type idTokenProvider struct {
+	audiences []string
+}
+
+func (p *idTokenProvider) verify(ctx context.Context, token string) (*claims, error) {
+	payload, err := validateIDToken(ctx, token, "")
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(googleIDTokenIssuers, payload.Issuer) {
+		return nil, fmt.Errorf("unknown issuer %q", payload.Issuer)
+	}
+	if !slices.Contains(p.audiences, payload.Audience) {
+		return nil, fmt.Errorf("audience %q not allowed", payload.Audience)
+	}
+	email, _ := payload.Claims["email"].(string)
+	return &claims{Issuer: payload.Issuer, Subject: payload.Subject, Audience: []string{payload.Audience}, Email: email}, nil
+}
@@ -0,0 +1,473 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/idtoken"
+)
+
+// testKeys is an issuer's key, served as a JWK set.
+type testKeys struct {
+	key *rsa.PrivateKey
+	kid string
+	srv *httptest.Server
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &testKeys{key: key, kid: "test-key"}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": k.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(k.srv.Close)
+	return k
+}
+
+// sign returns a JWT with the claims, signed with k's key.
+func (k *testKeys) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	return signWith(t, k.key, map[string]string{"alg": "RS256", "typ": "JWT", "kid": k.kid}, claims)
+}
+
+func signWith(t *testing.T, key *rsa.PrivateKey, header map[string]string, claims map[string]interface{}) string {
+	t.Helper()
+	var segs []string
+	for _, v := range []interface{}{header, claims} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segs = append(segs, base64.RawURLEncoding.EncodeToString(b))
+	}
+	digest := sha256.Sum256([]byte(strings.Join(segs, ".")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(append(segs, base64.RawURLEncoding.EncodeToString(sig)), ".")
+}
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "test-audience"
+)
+
+// validClaims returns the claims of a valid token for testIssuer, with the
+// changes applied.
+func validClaims(changes map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	p := newJWKSProvider(testIssuer, []string{testAudience, "other-audience"}, keys.srv.URL)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			token: keys.sign(t, validClaims(nil)),
+		},
+		{
+			name:  "audience array",
+			token: keys.sign(t, validClaims(map[string]interface{}{"aud": []string{"unknown", "other-audience"}})),
+		},
+		{
+			name:    "expired",
+			token:   keys.sign(t, validClaims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})),
+			wantErr: true,
+		},
+		{
+			name:    "no expiry",
+			token:   keys.sign(t, validClaims(map[string]interface{}{"exp": nil})),
+			wantErr: true,
+		},
+		{
+			name:    "not yet valid",
+			token:   keys.sign(t, validClaims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+			wantErr: true,
+		},
+		{
+			name:    "unknown issuer",
+			token:   keys.sign(t, validClaims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			wantErr: true,
+		},
+		{
+			name:    "unknown audience",
+			token:   keys.sign(t, validClaims(map[string]interface{}{"aud": "unknown"})),
+			wantErr: true,
+		},
+		{
+			name:    "other key",
+			token:   signWith(t, otherKey, map[string]string{"alg": "RS256", "kid": keys.kid}, validClaims(nil)),
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			token:   strings.Join(strings.Split(signWith(t, keys.key, map[string]string{"alg": "none", "kid": keys.kid}, validClaims(nil)), ".")[:2], ".") + ".",
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-jwt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := p.verify(context.Background(), tc.token)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("verify got no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if c.Issuer != testIssuer || c.Subject != "user-1" || c.Email != "user@example.com" {
+				t.Errorf("verify = %+v, want the claims of the token", c)
+			}
+		})
+	}
+}
+
+func TestIDTokenProvider(t *testing.T) {
+	old := validateIDToken
+	t.Cleanup(func() { validateIDToken = old })
+	validateIDToken = func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+		if audience != "" {
+			t.Errorf("validateIDToken got audience %q, want it checked by verify", audience)
+		}
+		// The fake tokens are their payloads, which idtoken also decodes
+		// into Claims.
+		var p idtoken.Payload
+		if err := json.Unmarshal([]byte(token), &p); err != nil {
+			return nil, errors.New("idtoken: invalid token")
+		}
+		if err := json.Unmarshal([]byte(token), &p.Claims); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+	p := &idTokenProvider{audiences: []string{"client-1", "client-2"}}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			token: `{"iss": "https://accounts.google.com", "aud": "client-2", "sub": "user-1", "email": "user@example.com"}`,
+		},
+		{
+			name:  "issuer without scheme",
+			token: `{"iss": "accounts.google.com", "aud": "client-1", "sub": "user-1", "email": "user@example.com"}`,
+		},
+		{
+			name:    "unknown issuer",
+			token:   `{"iss": "https://evil.example.com", "aud": "client-1", "sub": "user-1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown audience",
+			token:   `{"iss": "https://accounts.google.com", "aud": "client-3", "sub": "user-1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			token:   "not-a-jwt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := p.verify(context.Background(), tc.token)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("verify got no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if c.Subject != "user-1" || c.Email != "user@example.com" {
+				t.Errorf("verify = %+v, want the claims of the token", c)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	keys := newTestKeys(t)
+	auth := &authenticator{providers: map[string]provider{
+		schemeGoogleJWT: newJWKSProvider(testIssuer, []string{testAudience}, keys.srv.URL),
+	}}
+	spoofed := base64.StdEncoding.EncodeToString([]byte(`{"id": "admin"}`))
+
+	tests := []struct {
+		name     string
+		auth     *authenticator
+		scheme   string
+		header   map[string]string
+		wantCode int
+		wantID   string
+	}{
+		{
+			name:     "valid token",
+			auth:     auth,
+			scheme:   schemeGoogleJWT,
+			header:   map[string]string{"Authorization": "Bearer " + keys.sign(t, validClaims(nil))},
+			wantCode: http.StatusOK,
+			wantID:   "user-1",
+		},
+		{
+			name:   "spoofed header",
+			auth:   auth,
+			scheme: schemeGoogleJWT,
+			header: map[string]string{
+				"Authorization": "Bearer " + keys.sign(t, validClaims(nil)),
+				userInfoHeader:  spoofed,
+			},
+			wantCode: http.StatusOK,
+			wantID:   "user-1",
+		},
+		{
+			name:     "spoofed header without token",
+			auth:     auth,
+			scheme:   schemeGoogleJWT,
+			header:   map[string]string{userInfoHeader: spoofed},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid token",
+			auth:     auth,
+			scheme:   schemeGoogleJWT,
+			header:   map[string]string{"Authorization": "Bearer " + keys.sign(t, validClaims(map[string]interface{}{"aud": "unknown"}))},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "not a bearer token",
+			auth:     auth,
+			scheme:   schemeGoogleJWT,
+			header:   map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "unconfigured scheme",
+			auth:     auth,
+			scheme:   schemeFirebase,
+			header:   map[string]string{"Authorization": "Bearer " + keys.sign(t, validClaims(nil))},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "behind the proxy",
+			scheme:   schemeGoogleJWT,
+			header:   map[string]string{userInfoHeader: spoofed},
+			wantCode: http.StatusOK,
+			wantID:   "admin",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/auth/info/googlejwt", nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			tc.auth.require(tc.scheme, authInfoHandler)(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tc.wantCode, rec.Body)
+			}
+			if tc.wantCode != http.StatusOK {
+				var out struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.Code != tc.wantCode || out.Message == "" {
+					t.Errorf("got error body %s, want a JSON error with code %d", rec.Body, tc.wantCode)
+				}
+				return
+			}
+			var info userInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+				t.Fatalf("could not decode %s: %v", rec.Body, err)
+			}
+			if info.ID != tc.wantID {
+				t.Errorf("got id %q, want %q", info.ID, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestAuthFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantSchemes []string
+		wantErr     bool
+	}{
+		{
+			name: "behind the proxy",
+		},
+		{
+			name: "disabled",
+			env:  map[string]string{"VALIDATE_JWT": "false", "FIREBASE_PROJECT": "my-project"},
+		},
+		{
+			name: "all issuers",
+			env: map[string]string{
+				"VALIDATE_JWT":             "true",
+				"GOOGLE_JWT_ISSUER":        "sa@my-project.iam.gserviceaccount.com",
+				"GOOGLE_JWT_AUDIENCE":      "echo.endpoints.sample.google.com",
+				"GOOGLE_ID_TOKEN_AUDIENCE": "client-1, client-2",
+				"FIREBASE_PROJECT":         "my-project",
+				"AUTH0_DOMAIN":             "my-account.auth0.com",
+				"AUTH0_AUDIENCE":           "client-3",
+			},
+			wantSchemes: []string{schemeGoogleJWT, schemeGoogleIDToken, schemeFirebase, schemeAuth0},
+		},
+		{
+			name:    "no issuer",
+			env:     map[string]string{"VALIDATE_JWT": "true"},
+			wantErr: true,
+		},
+		{
+			name:    "no audience",
+			env:     map[string]string{"VALIDATE_JWT": "true", "GOOGLE_JWT_ISSUER": "sa@my-project.iam.gserviceaccount.com"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid VALIDATE_JWT",
+			env:     map[string]string{"VALIDATE_JWT": "maybe"},
+			wantErr: true,
+		},
+	}
+	vars := []string{"VALIDATE_JWT", "GOOGLE_JWT_ISSUER", "GOOGLE_JWT_AUDIENCE", "GOOGLE_JWT_JWKS_URI",
+		"GOOGLE_ID_TOKEN_AUDIENCE", "FIREBASE_PROJECT", "AUTH0_DOMAIN", "AUTH0_AUDIENCE"}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, v := range vars {
+				t.Setenv(v, tc.env[v])
+			}
+			auth, err := authFromEnv()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("authFromEnv got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authFromEnv: %v", err)
+			}
+			if tc.wantSchemes == nil {
+				if auth != nil {
+					t.Fatalf("authFromEnv = %+v, want nil", auth)
+				}
+				return
+			}
+			if len(auth.providers) != len(tc.wantSchemes) {
+				t.Errorf("got %d schemes, want %v", len(auth.providers), tc.wantSchemes)
+			}
+			for _, scheme := range tc.wantSchemes {
+				if auth.providers[scheme] == nil {
+					t.Errorf("scheme %s not configured", scheme)
+				}
+			}
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv("VALIDATE_JWT", "1")
+		t.Setenv("GOOGLE_JWT_ISSUER", "sa@my-project.iam.gserviceaccount.com")
+		t.Setenv("GOOGLE_JWT_AUDIENCE", "echo.endpoints.sample.google.com")
+		t.Setenv("GOOGLE_ID_TOKEN_AUDIENCE", "client-1, client-2")
+		t.Setenv("FIREBASE_PROJECT", "my-project")
+		auth, err := authFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := auth.providers[schemeGoogleJWT].(*jwksProvider).keysURL, serviceAccountKeysURL+"sa@my-project.iam.gserviceaccount.com"; got != want {
+			t.Errorf("google_jwt keys URL = %q, want %q", got, want)
+		}
+		if got := auth.providers[schemeGoogleIDToken].(*idTokenProvider).audiences; len(got) != 2 || got[1] != "client-2" {
+			t.Errorf("google_id_token audiences = %q, want [client-1 client-2]", got)
+		}
+		firebase := auth.providers[schemeFirebase].(*jwksProvider)
+		if firebase.issuer != "https://securetoken.google.com/my-project" || firebase.audiences[0] != "my-project" {
+			t.Errorf("firebase issuer %q and audiences %q, want the project's", firebase.issuer, firebase.audiences)
+		}
+	})
+}
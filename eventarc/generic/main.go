@@ -18,6 +18,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,6 +26,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+
+	"google.golang.org/api/idtoken"
 )
 
 // GenericHandler receives and echos a HTTP request's headers and body.
@@ -72,7 +75,7 @@ func GenericHandler(w http.ResponseWriter, r *http.Request) {
 // [START eventarc_generic_server]
 
 func main() {
-	http.HandleFunc("/", GenericHandler)
+	http.Handle("/", newHandler())
 	// Determine port for HTTP service.
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -86,4 +89,56 @@ func main() {
 	}
 }
 
+// validateToken validates Eventarc's OIDC tokens. Tests replace it.
+var validateToken = idtoken.Validate
+
+// newHandler returns GenericHandler. When AUTH_AUDIENCE is set, it only
+// accepts requests carrying an Eventarc OIDC token for that audience and, if
+// AUTH_SERVICE_ACCOUNT is set, issued to that service account.
+//
+// Without AUTH_AUDIENCE, requests aren't authenticated, so deploy the
+// service with --no-allow-unauthenticated to let Cloud Run check them
+// instead.
+func newHandler() http.Handler {
+	h := http.HandlerFunc(GenericHandler)
+	aud := os.Getenv("AUTH_AUDIENCE")
+	if aud == "" {
+		return h
+	}
+	log.Printf("Accepting OIDC tokens for audience %s", aud)
+	email := os.Getenv("AUTH_SERVICE_ACCOUNT")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifyToken(r.Context(), r.Header.Get("Authorization"), aud, email); err != nil {
+			log.Printf("rejected %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// verifyToken validates the bearer token in an Authorization header for
+// audience and, if email isn't empty, checks it was issued to that verified
+// service account.
+func verifyToken(ctx context.Context, authorization, audience, email string) error {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return fmt.Errorf("no bearer token")
+	}
+	p, err := validateToken(ctx, token, audience)
+	if err != nil {
+		return err
+	}
+	if email == "" {
+		return nil
+	}
+	if got, _ := p.Claims["email"].(string); got != email {
+		return fmt.Errorf("token issued to %q, want %q", got, email)
+	}
+	if verified, _ := p.Claims["email_verified"].(bool); !verified {
+		return fmt.Errorf("email %q not verified", email)
+	}
+	return nil
+}
+
 // [END eventarc_generic_server]
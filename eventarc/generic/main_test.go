@@ -15,6 +15,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -23,6 +25,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"google.golang.org/api/idtoken"
 )
 
 func TestGenericHandler(t *testing.T) {
@@ -70,3 +74,53 @@ func TestGenericHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestNewHandlerOIDC(t *testing.T) {
+	const aud = "https://generic.example.run.app"
+	t.Setenv("AUTH_AUDIENCE", aud)
+	t.Setenv("AUTH_SERVICE_ACCOUNT", "eventarc@example.iam.gserviceaccount.com")
+	old := validateToken
+	t.Cleanup(func() { validateToken = old })
+	validateToken = func(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+		if audience != aud {
+			t.Errorf("validated audience %q, want %q", audience, aud)
+		}
+		switch token {
+		case "eventarc":
+			return &idtoken.Payload{Claims: map[string]interface{}{
+				"email": "eventarc@example.iam.gserviceaccount.com", "email_verified": true,
+			}}, nil
+		case "other":
+			return &idtoken.Payload{Claims: map[string]interface{}{
+				"email": "other@example.iam.gserviceaccount.com", "email_verified": true,
+			}}, nil
+		}
+		return nil, errors.New("invalid token")
+	}
+
+	h := newHandler()
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "valid", authorization: "Bearer eventarc", want: http.StatusOK},
+		{name: "other service account", authorization: "Bearer other", want: http.StatusUnauthorized},
+		{name: "invalid", authorization: "Bearer forged", want: http.StatusUnauthorized},
+		{name: "no token", want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"message": "some string"}`))
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, rr.Code, test.want)
+		}
+		if rr.Code == http.StatusOK && !strings.Contains(rr.Body.String(), `some string`) {
+			t.Errorf("%s: response %q doesn't echo the body", test.name, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/kgsearch/v1"
+)
+
+const (
+	// nextResultAction is the action ID of the "Next result" button.
+	nextResultAction = "kg_next_result"
+	// listLimit is the number of results /kg-list shows.
+	listLimit = 5
+	// maxResultIndex caps how far "Next result" pages.
+	maxResultIndex = 20
+)
+
+// KGCommands serves the /kg and /kg-list slash commands and the buttons of
+// their messages. Use its URL for both commands and as the Slack app's
+// interactivity request URL.
+func KGCommands(w http.ResponseWriter, r *http.Request) {
+	setup(r.Context())
+	newRouter(slackSecret).ServeHTTP(w, r)
+}
+
+// newRouter returns a router for the sample's commands.
+func newRouter(secret string) *Router {
+	rt := NewRouter(secret)
+	rt.HandleCommand("/kg", "Look up the top Knowledge Graph result, e.g. /kg Golden Gate Bridge", searchCommand)
+	rt.HandleCommand("/kg-list", "List the top Knowledge Graph results, e.g. /kg-list Paris", listCommand)
+	rt.HandleAction(nextResultAction, nextResult)
+	return rt
+}
+
+// resultRef identifies one result of a query. It's the value of the "Next
+// result" button.
+type resultRef struct {
+	Query string `json:"q"`
+	Index int    `json:"i"`
+}
+
+// searchCommand responds with the top result and a button to page through
+// the others.
+func searchCommand(ctx context.Context, cmd SlashCommand) (*Response, error) {
+	if cmd.Text == "" {
+		return ephemeral(fmt.Sprintf("Usage: %s <query>", cmd.Command)), nil
+	}
+	return resultMessage(ctx, resultRef{Query: cmd.Text})
+}
+
+// listCommand responds with the top results in a single message.
+func listCommand(ctx context.Context, cmd SlashCommand) (*Response, error) {
+	if cmd.Text == "" {
+		return ephemeral(fmt.Sprintf("Usage: %s <query>", cmd.Command)), nil
+	}
+	res, err := searchEntities(ctx, cmd.Text, listLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.ItemListElement) == 0 {
+		msg, err := formatSlackMessage(cmd.Text, res)
+		if err != nil {
+			return nil, err
+		}
+		return responseFor(msg), nil
+	}
+	msg := &Response{ResponseType: "in_channel", Text: fmt.Sprintf("Query: %s", cmd.Text)}
+	for _, item := range res.ItemListElement {
+		m, err := formatSlackMessage(cmd.Text, &kgsearch.SearchResponse{ItemListElement: []interface{}{item}})
+		if err != nil {
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, m.Attachments...)
+	}
+	return msg, nil
+}
+
+// nextResult replaces a result message with the next result of its query.
+func nextResult(ctx context.Context, _ *InteractionPayload, a BlockAction) (*Response, error) {
+	var ref resultRef
+	if err := json.Unmarshal([]byte(a.Value), &ref); err != nil {
+		return nil, fmt.Errorf("decoding button value %q: %w", a.Value, err)
+	}
+	msg, err := resultMessage(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	msg.ReplaceOriginal = true
+	return msg, nil
+}
+
+// resultMessage formats result ref.Index of ref.Query, with a button for
+// the next one while there are more.
+func resultMessage(ctx context.Context, ref resultRef) (*Response, error) {
+	if ref.Index < 0 || ref.Index > maxResultIndex {
+		return nil, fmt.Errorf("result index %d out of range", ref.Index)
+	}
+	// Ask for one more result than needed to know whether there's a next.
+	res, err := searchEntities(ctx, ref.Query, int64(ref.Index+2))
+	if err != nil {
+		return nil, err
+	}
+	items := res.ItemListElement
+	if ref.Index < len(items) {
+		res = &kgsearch.SearchResponse{ItemListElement: items[ref.Index : ref.Index+1]}
+	} else {
+		res = &kgsearch.SearchResponse{}
+	}
+	m, err := formatSlackMessage(ref.Query, res)
+	if err != nil {
+		return nil, err
+	}
+	msg := responseFor(m)
+	if ref.Index+1 < len(items) && ref.Index < maxResultIndex {
+		next, _ := json.Marshal(resultRef{Query: ref.Query, Index: ref.Index + 1})
+		// Slack shows blocks in place of the message text, so repeat it.
+		msg.Blocks = []block{
+			section(msg.Text),
+			actions(button("Next result", nextResultAction, string(next))),
+		}
+	}
+	return msg, nil
+}
+
+// searchEntities queries the Knowledge Graph for up to limit entities.
+func searchEntities(ctx context.Context, query string, limit int64) (*kgsearch.SearchResponse, error) {
+	res, err := entitiesService.Search().Query(query).Limit(limit).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Search(%q): %w", query, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+// InteractionPayload is the payload Slack sends when a user interacts with
+// a message, such as by clicking a button. Only the fields the router uses
+// are decoded.
+// See https://api.slack.com/reference/interaction-payloads/block-actions.
+type InteractionPayload struct {
+	Type        string        `json:"type"`
+	TriggerID   string        `json:"trigger_id"`
+	ResponseURL string        `json:"response_url"`
+	User        slackUser     `json:"user"`
+	Channel     slackChannel  `json:"channel"`
+	Actions     []BlockAction `json:"actions"`
+}
+
+type slackUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type slackChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// BlockAction is an interaction with one element of a message.
+type BlockAction struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
+
+// block is a Block Kit layout block.
+// See https://api.slack.com/reference/block-kit/blocks.
+type block struct {
+	Type     string     `json:"type"`
+	BlockID  string     `json:"block_id,omitempty"`
+	Text     *textField `json:"text,omitempty"`
+	Elements []element  `json:"elements,omitempty"`
+}
+
+// element is an interactive Block Kit element.
+type element struct {
+	Type     string     `json:"type"`
+	Text     *textField `json:"text,omitempty"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+}
+
+type textField struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// button returns a button that sends actionID and value when clicked.
+func button(label, actionID, value string) element {
+	return element{
+		Type:     "button",
+		Text:     &textField{Type: "plain_text", Text: label},
+		ActionID: actionID,
+		Value:    value,
+	}
+}
+
+// section returns a block of markdown text.
+func section(text string) block {
+	return block{Type: "section", Text: &textField{Type: "mrkdwn", Text: text}}
+}
+
+// actions returns a block holding the elements.
+func actions(elements ...element) block {
+	return block{Type: "actions", Elements: elements}
+}
@@ -0,0 +1,312 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySize limits the size of requests from Slack.
+const maxBodySize = 1 << 20
+
+// SlashCommand is the payload Slack sends when a user runs a slash command.
+// See https://api.slack.com/interactivity/slash-commands.
+type SlashCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	ChannelID   string
+	TeamID      string
+	ResponseURL string
+	TriggerID   string
+}
+
+// Response is a message the Router sends to Slack. It's a Message that can
+// also hold Block Kit blocks and, in response to an interaction, replace the
+// message the interaction came from.
+type Response struct {
+	ResponseType    string       `json:"response_type"`
+	Text            string       `json:"text"`
+	Attachments     []attachment `json:"attachments,omitempty"`
+	Blocks          []block      `json:"blocks,omitempty"`
+	ReplaceOriginal bool         `json:"replace_original,omitempty"`
+}
+
+// responseFor returns a Response with the content of m.
+func responseFor(m *Message) *Response {
+	return &Response{ResponseType: m.ResponseType, Text: m.Text, Attachments: m.Attachments}
+}
+
+// A CommandHandler responds to a slash command.
+type CommandHandler func(ctx context.Context, cmd SlashCommand) (*Response, error)
+
+// An ActionHandler responds to a click on an interactive message element.
+// The message it returns is posted to the payload's response_url.
+type ActionHandler func(ctx context.Context, p *InteractionPayload, a BlockAction) (*Response, error)
+
+type command struct {
+	handler     CommandHandler
+	description string
+}
+
+// Router dispatches signed Slack requests: slash commands by command name
+// and interactive payloads by action ID.
+//
+// Slack waits 3 seconds for a reply. A command handler that takes longer than
+// AckTimeout is acknowledged with a short message and its result is posted to
+// the command's response_url once ready. Interactive payloads are always
+// acknowledged at once and answered through response_url.
+type Router struct {
+	// AckTimeout is how long a command handler may run before the command is
+	// acknowledged and its result delayed.
+	AckTimeout time.Duration
+	// DelayedTimeout bounds handlers whose result is posted to response_url.
+	DelayedTimeout time.Duration
+	// Client posts delayed responses.
+	Client *http.Client
+
+	secret   string
+	commands map[string]command
+	actions  map[string]ActionHandler
+}
+
+// NewRouter returns a Router that accepts requests signed with secret.
+func NewRouter(secret string) *Router {
+	return &Router{
+		AckTimeout:     2500 * time.Millisecond,
+		DelayedTimeout: time.Minute,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		secret:         secret,
+		commands:       map[string]command{},
+		actions:        map[string]ActionHandler{},
+	}
+}
+
+// HandleCommand registers h for the slash command name, such as "/kg".
+func (rt *Router) HandleCommand(name, description string, h CommandHandler) {
+	rt.commands[name] = command{handler: h, description: description}
+}
+
+// HandleAction registers h for interactive elements with actionID.
+func (rt *Router) HandleAction(actionID string, h ActionHandler) {
+	rt.actions[actionID] = h
+}
+
+// ServeHTTP verifies the request signature and dispatches it.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST requests are accepted", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "Couldn't read request body", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	ok, err := verifyWebHook(r, rt.secret)
+	if err != nil || !ok {
+		log.Printf("verifyWebHook: rejected request: %v", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Couldn't parse form", http.StatusBadRequest)
+		return
+	}
+
+	// Handlers may outlive the request once it has been acknowledged.
+	ctx := context.WithoutCancel(r.Context())
+	if payload := form.Get("payload"); payload != "" {
+		rt.serveInteraction(ctx, w, payload)
+		return
+	}
+	rt.serveCommand(ctx, w, SlashCommand{
+		Command:     form.Get("command"),
+		Text:        strings.TrimSpace(form.Get("text")),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		TeamID:      form.Get("team_id"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+	})
+}
+
+type handlerResult struct {
+	msg *Response
+	err error
+}
+
+func (rt *Router) serveCommand(ctx context.Context, w http.ResponseWriter, cmd SlashCommand) {
+	c, ok := rt.commands[cmd.Command]
+	if !ok {
+		writeMessage(w, ephemeral(fmt.Sprintf("Unknown command %q. Available commands:\n%s", cmd.Command, rt.help())))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rt.DelayedTimeout)
+	defer cancel()
+	done := make(chan handlerResult, 1)
+	go func() {
+		msg, err := c.handler(ctx, cmd)
+		done <- handlerResult{msg, err}
+	}()
+
+	select {
+	case res := <-done:
+		writeMessage(w, messageOrError(cmd.Command, res))
+		return
+	case <-time.After(rt.AckTimeout):
+	}
+
+	// Acknowledge now and send the result to response_url when it's ready.
+	if cmd.ResponseURL == "" {
+		log.Printf("%s: no response_url to send the delayed result to", cmd.Command)
+		writeMessage(w, ephemeral("Sorry, that took too long."))
+		return
+	}
+	ack(w, ephemeral(fmt.Sprintf("Working on %s %s...", cmd.Command, cmd.Text)))
+	res := <-done
+	if err := rt.postResponse(ctx, cmd.ResponseURL, messageOrError(cmd.Command, res)); err != nil {
+		log.Printf("%s: %v", cmd.Command, err)
+	}
+}
+
+func (rt *Router) serveInteraction(ctx context.Context, w http.ResponseWriter, payload string) {
+	var p InteractionPayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		http.Error(w, "Couldn't parse payload", http.StatusBadRequest)
+		return
+	}
+	if p.Type != "block_actions" {
+		log.Printf("ignoring %q interaction", p.Type)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	ack(w, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, rt.DelayedTimeout)
+	defer cancel()
+	for _, a := range p.Actions {
+		h, ok := rt.actions[a.ActionID]
+		if !ok {
+			log.Printf("no handler for action %q", a.ActionID)
+			continue
+		}
+		msg, err := h(ctx, &p, a)
+		if err := rt.postResponse(ctx, p.ResponseURL, messageOrError(a.ActionID, handlerResult{msg, err})); err != nil {
+			log.Printf("%s: %v", a.ActionID, err)
+		}
+	}
+}
+
+// help lists the registered commands.
+func (rt *Router) help() string {
+	names := make([]string, 0, len(rt.commands))
+	for name := range rt.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "• %s: %s\n", name, rt.commands[name].description)
+	}
+	return b.String()
+}
+
+// postResponse sends msg to a response_url.
+func (rt *Router) postResponse(ctx context.Context, responseURL string, msg *Response) error {
+	if responseURL == "" {
+		return fmt.Errorf("no response_url")
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rt.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to response_url: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("posting to response_url: %s", resp.Status)
+	}
+	return nil
+}
+
+// messageOrError returns the handler's message, or an ephemeral error
+// message if it failed.
+func messageOrError(name string, res handlerResult) *Response {
+	if res.err != nil {
+		log.Printf("%s: %v", name, res.err)
+		return ephemeral("Sorry, something went wrong. Please try again.")
+	}
+	if res.msg == nil {
+		return ephemeral("Done.")
+	}
+	return res.msg
+}
+
+// ephemeral returns a message only the user who ran the command sees.
+func ephemeral(text string) *Response {
+	return &Response{ResponseType: "ephemeral", Text: text}
+}
+
+func writeMessage(w http.ResponseWriter, msg *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		log.Printf("json.Encode: %v", err)
+	}
+}
+
+// ack sends a complete response, with msg as the body if it isn't nil, and
+// flushes it so Slack sees the acknowledgement while the handler keeps
+// running to post a delayed response.
+func ack(w http.ResponseWriter, msg *Response) {
+	var b []byte
+	if msg != nil {
+		var err error
+		if b, err = json.Marshal(msg); err != nil {
+			log.Printf("json.Marshal: %v", err)
+			b = nil
+		}
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
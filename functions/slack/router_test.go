@@ -0,0 +1,318 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/kgsearch/v1"
+	"google.golang.org/api/option"
+)
+
+const testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// fakeKG serves the Knowledge Graph results in testdata/kgsearch.json,
+// limited as requested. The query "nothing" has no results.
+type fakeKG struct {
+	delay   time.Duration
+	queries chan string
+}
+
+func (f *fakeKG) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/entities:search" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	select {
+	case f.queries <- q.Get("query"):
+	default:
+	}
+	time.Sleep(f.delay)
+
+	b, err := os.ReadFile("testdata/kgsearch.json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := res["itemListElement"].([]interface{})
+	if q.Get("query") == "nothing" {
+		items = nil
+	}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n < len(items) {
+		items = items[:n]
+	}
+	res["itemListElement"] = items
+	json.NewEncoder(w).Encode(res)
+}
+
+// useFakeKG points entitiesService at a fake Knowledge Graph API.
+func useFakeKG(t *testing.T, delay time.Duration) *fakeKG {
+	t.Helper()
+	f := &fakeKG{delay: delay, queries: make(chan string, 10)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	svc, err := kgsearch.NewService(context.Background(),
+		option.WithEndpoint(srv.URL+"/"), option.WithAPIKey("test-key"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("kgsearch.NewService: %v", err)
+	}
+	old := entitiesService
+	entitiesService = kgsearch.NewEntitiesService(svc)
+	t.Cleanup(func() { entitiesService = old })
+	return f
+}
+
+// responseURL records messages posted to a response_url.
+func responseURL(t *testing.T) (string, <-chan Response) {
+	t.Helper()
+	msgs := make(chan Response, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m Response
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("response_url got invalid message: %v", err)
+		}
+		msgs <- m
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, msgs
+}
+
+// signedRequest returns a request with body signed as Slack would.
+func signedRequest(t *testing.T, secret, body string) *http.Request {
+	t.Helper()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := getSignature([]byte(fmt.Sprintf("%s:%s:%s", version, ts, body)), []byte(secret))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(slackRequestTimestampHeader, ts)
+	req.Header.Set(slackSignatureHeader, version+"="+hex.EncodeToString(sig))
+	return req
+}
+
+// fixture reads a testdata file.
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// commandBody returns the slash command fixture for command and text.
+func commandBody(t *testing.T, command, text, respURL string) string {
+	t.Helper()
+	form, err := url.ParseQuery(fixture(t, "slash_command.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	form.Set("command", command)
+	form.Set("text", text)
+	form.Set("response_url", respURL)
+	return form.Encode()
+}
+
+func decodeMessage(t *testing.T, rr *httptest.ResponseRecorder) Response {
+	t.Helper()
+	var m Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &m); err != nil {
+		t.Fatalf("decoding response %q: %v", rr.Body.String(), err)
+	}
+	return m
+}
+
+func TestKGSearchFake(t *testing.T) {
+	useFakeKG(t, 0)
+	t.Setenv("SLACK_SECRET", testSecret)
+
+	rr := httptest.NewRecorder()
+	KGSearch(rr, signedRequest(t, testSecret, fixture(t, "slash_command.txt")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+	}
+	m := decodeMessage(t, rr)
+	if m.ResponseType != "in_channel" || len(m.Attachments) != 1 || len(m.Blocks) != 0 {
+		t.Fatalf("message = %+v, want one in-channel attachment", m)
+	}
+	if got, want := m.Attachments[0].Title, "Golden Gate Bridge: Suspension bridge in San Francisco, California"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+}
+
+func TestKGCommandsFake(t *testing.T) {
+	useFakeKG(t, 0)
+	t.Setenv("SLACK_SECRET", testSecret)
+
+	rr := httptest.NewRecorder()
+	KGCommands(rr, signedRequest(t, testSecret, fixture(t, "slash_command.txt")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+	}
+	m := decodeMessage(t, rr)
+	if m.ResponseType != "in_channel" || len(m.Attachments) != 1 {
+		t.Fatalf("message = %+v, want one in-channel attachment", m)
+	}
+	if got, want := m.Attachments[0].Title, "Golden Gate Bridge: Suspension bridge in San Francisco, California"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if len(m.Blocks) != 2 || m.Blocks[1].Elements[0].ActionID != nextResultAction {
+		t.Errorf("blocks = %+v, want a Next result button", m.Blocks)
+	}
+}
+
+func TestRouterCommands(t *testing.T) {
+	useFakeKG(t, 0)
+	rt := newRouter(testSecret)
+
+	tests := []struct {
+		command, text   string
+		wantType        string
+		wantAttachments int
+		wantText        string
+		wantNext        bool
+	}{
+		{command: "/kg", text: "Golden Gate", wantType: "in_channel", wantAttachments: 1, wantNext: true},
+		{command: "/kg", text: "nothing", wantType: "in_channel", wantAttachments: 1, wantText: "Query: nothing"},
+		{command: "/kg", text: "", wantType: "ephemeral", wantText: "Usage: /kg <query>"},
+		{command: "/kg-list", text: "Golden Gate", wantType: "in_channel", wantAttachments: 3},
+		{command: "/weather", text: "today", wantType: "ephemeral", wantText: "Unknown command"},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, signedRequest(t, testSecret, commandBody(t, tc.command, tc.text, "")))
+		m := decodeMessage(t, rr)
+		if m.ResponseType != tc.wantType || len(m.Attachments) != tc.wantAttachments || !strings.Contains(m.Text, tc.wantText) {
+			t.Errorf("%s %q = %+v, want %s message with %d attachments and text %q",
+				tc.command, tc.text, m, tc.wantType, tc.wantAttachments, tc.wantText)
+		}
+		if hasNext := len(m.Blocks) > 0; hasNext != tc.wantNext {
+			t.Errorf("%s %q has Next result button: %v, want %v", tc.command, tc.text, hasNext, tc.wantNext)
+		}
+	}
+	if got := rt.help(); !strings.Contains(got, "/kg-list") {
+		t.Errorf("help() = %q, want it to list /kg-list", got)
+	}
+}
+
+func TestRouterRejectsUnsigned(t *testing.T) {
+	useFakeKG(t, 0)
+	rt := newRouter(testSecret)
+	body := commandBody(t, "/kg", "Golden Gate", "")
+
+	for name, req := range map[string]*http.Request{
+		"wrong secret": signedRequest(t, "another-secret", body),
+		"unsigned":     httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)),
+	} {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rr.Code)
+	}
+}
+
+func TestRouterDelayedResponse(t *testing.T) {
+	kg := useFakeKG(t, 200*time.Millisecond)
+	respURL, posted := responseURL(t)
+	rt := newRouter(testSecret)
+	rt.AckTimeout = 20 * time.Millisecond
+
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, signedRequest(t, testSecret, commandBody(t, "/kg", "Golden Gate", respURL)))
+	ack := decodeMessage(t, rr)
+	if ack.ResponseType != "ephemeral" || !strings.HasPrefix(ack.Text, "Working on /kg") {
+		t.Errorf("acknowledgement = %+v, want an ephemeral Working on message", ack)
+	}
+	if q := <-kg.queries; q != "Golden Gate" {
+		t.Errorf("searched for %q, want Golden Gate", q)
+	}
+	select {
+	case m := <-posted:
+		if len(m.Attachments) != 1 || m.Attachments[0].Title == "" {
+			t.Errorf("delayed response = %+v, want a result", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delayed response posted to response_url")
+	}
+}
+
+func TestRouterInteraction(t *testing.T) {
+	useFakeKG(t, 0)
+	respURL, posted := responseURL(t)
+	rt := newRouter(testSecret)
+
+	payload := strings.ReplaceAll(fixture(t, "block_actions.json"), "RESPONSE_URL", respURL)
+	body := url.Values{"payload": {payload}}.Encode()
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, signedRequest(t, testSecret, body))
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("acknowledgement = %d %q, want an empty 200", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case m := <-posted:
+		if !m.ReplaceOriginal {
+			t.Errorf("Next result message doesn't replace the original: %+v", m)
+		}
+		if len(m.Attachments) != 1 || m.Attachments[0].Title != "Golden Gate: Strait in California" {
+			t.Errorf("Next result = %+v, want the second result", m.Attachments)
+		}
+		if len(m.Blocks) != 2 {
+			t.Fatalf("Next result blocks = %+v, want a button for the third result", m.Blocks)
+		}
+		var ref resultRef
+		if err := json.Unmarshal([]byte(m.Blocks[1].Elements[0].Value), &ref); err != nil || ref.Index != 2 {
+			t.Errorf("Next result button value = %q, want index 2", m.Blocks[1].Elements[0].Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response posted to response_url")
+	}
+}
+
+func TestPostResponseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "expired_url", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	rt := NewRouter(testSecret)
+	if err := rt.postResponse(context.Background(), srv.URL, ephemeral("hi")); err == nil {
+		t.Error("postResponse to a failing response_url succeeded")
+	}
+}
@@ -20,6 +20,7 @@ package slack
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -95,7 +96,7 @@ func KGSearch(w http.ResponseWriter, r *http.Request) {
 	if len(r.Form["text"]) == 0 {
 		log.Fatalf("empty text in form")
 	}
-	kgSearchResponse, err := makeSearchRequest(r.Form["text"][0])
+	kgSearchResponse, err := makeSearchRequest(r.Context(), r.Form["text"][0])
 	if err != nil {
 		log.Fatalf("makeSearchRequest: %v", err)
 	}
@@ -108,8 +109,8 @@ func KGSearch(w http.ResponseWriter, r *http.Request) {
 // [END functions_slack_search]
 
 // [START functions_slack_request]
-func makeSearchRequest(query string) (*Message, error) {
-	res, err := entitiesService.Search().Query(query).Limit(1).Do()
+func makeSearchRequest(ctx context.Context, query string) (*Message, error) {
+	res, err := entitiesService.Search().Query(query).Limit(1).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("do: %w", err)
 	}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http/httptest"
 	"net/url"
 	"os"
@@ -31,33 +30,43 @@ import (
 	"google.golang.org/api/option"
 )
 
-var slackURL string
+var (
+	slackURL   string
+	liveKGKey  string
+	liveSecret string
+)
 
-// TestMain sets up the config rather than using the config file
-// which contains placeholder values.
+// TestMain reads the config for the tests that call the Knowledge Graph API
+// rather than using the config file which contains placeholder values.
 func TestMain(m *testing.M) {
-	ctx := context.Background()
 	slackURL = os.Getenv("GOLANG_SAMPLES_SLACK_URL")
-	kgKey = os.Getenv("GOLANG_SAMPLES_KG_KEY")
-	if kgKey == "" {
-		log.Print("GOLANG_SAMPLES_KG_KEY is unset. Skipping.")
-		return
-	}
-	slackSecret = os.Getenv("GOLANG_SAMPLES_SLACK_SECRET")
-	if slackSecret == "" {
-		log.Print("GOLANG_SAMPLES_SLACK_SECRET is unset. Skipping.")
-		return
+	liveKGKey = os.Getenv("GOLANG_SAMPLES_KG_KEY")
+	liveSecret = os.Getenv("GOLANG_SAMPLES_SLACK_SECRET")
+	os.Exit(m.Run())
+}
+
+// setupLive points the function at the Knowledge Graph API, skipping the
+// test if the API key and Slack secret aren't set.
+func setupLive(t *testing.T) {
+	t.Helper()
+	if liveKGKey == "" || liveSecret == "" {
+		t.Skip("GOLANG_SAMPLES_KG_KEY and GOLANG_SAMPLES_SLACK_SECRET must be set")
 	}
-	kgService, err := kgsearch.NewService(ctx, option.WithAPIKey(kgKey))
+	kgService, err := kgsearch.NewService(context.Background(), option.WithAPIKey(liveKGKey))
 	if err != nil {
-		log.Fatalf("kgsearch.NewClient: %v", err)
+		t.Fatalf("kgsearch.NewClient: %v", err)
 	}
+	old := entitiesService
 	entitiesService = kgsearch.NewEntitiesService(kgService)
-
-	os.Exit(m.Run())
+	t.Cleanup(func() { entitiesService = old })
+	kgKey = liveKGKey
+	slackSecret = liveSecret
+	t.Setenv("KG_API_KEY", liveKGKey)
+	t.Setenv("SLACK_SECRET", liveSecret)
 }
 
 func TestFormatSlackMessage(t *testing.T) {
+	setupLive(t)
 	tests := []struct {
 		query string
 		want  string
@@ -88,9 +97,10 @@ func TestFormatSlackMessage(t *testing.T) {
 }
 
 func TestMakeSearchRequest(t *testing.T) {
+	setupLive(t)
 	query := "Google"
 	want := "Google"
-	msg, err := makeSearchRequest(query)
+	msg, err := makeSearchRequest(context.Background(), query)
 	if err != nil {
 		t.Errorf("makeSearchRequest: %v", err)
 	}
@@ -111,9 +121,11 @@ func TestMakeSearchRequest(t *testing.T) {
 }
 
 func TestKGSearch(t *testing.T) {
+	setupLive(t)
 	w := httptest.NewRecorder()
 	form := url.Values{
-		"text": []string{"Google"},
+		"command": []string{"/kg"},
+		"text":    []string{"Google"},
 	}
 
 	ts := strconv.FormatInt(time.Now().Unix(), 10)
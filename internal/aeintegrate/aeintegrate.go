@@ -22,6 +22,12 @@
 //
 // You may specify the location of gcloud via the GCLOUD_BIN environment variable.
 //
+// Both App Engine standard and flexible services are supported; the
+// environment is read from the `env` setting in app.yaml. Deploy waits until
+// the Admin API reports the new version as serving. Set App.Traffic to route
+// part of the service's traffic to the new version, and App.DryRun to only
+// validate and render the configuration that would be deployed.
+//
 // Sample usage with `go test`:
 //
 //	package myapp
@@ -51,7 +57,9 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"golang.org/x/oauth2/google"
@@ -82,10 +90,33 @@ type App struct {
 	Service string
 
 	// Additional runtime environment variable overrides for the app.
+	// Each key must already be present in the env_variables section of the
+	// configuration file.
 	Env map[string]string
 
+	// Traffic is the fraction of the service's traffic, between 0 and 1, to
+	// route to the deployed version once it is serving. By default the
+	// version receives no traffic. The previous traffic split is restored by
+	// Cleanup, so another version must already be serving.
+	Traffic float64
+
+	// ReadyTimeout is how long Deploy waits for the deployed version to be
+	// serving. Defaults to 10 minutes.
+	ReadyTimeout time.Duration
+
+	// DryRun makes Deploy validate the configuration and render the
+	// temporary configuration file without deploying anything.
+	// The rendered file is logged and returned by RenderedAppYaml.
+	DryRun bool
+
 	deployed bool // Whether the app has been deployed.
 
+	flex bool // Whether the app targets the App Engine flexible environment.
+
+	rendered []byte // The configuration rendered by a dry run.
+
+	prevSplit *appengine.TrafficSplit // The traffic split to restore during clean up.
+
 	adminService *appengine.APIService // Used during clean up to delete the deployed version.
 
 	// A temporary configuration file that includes modifications (e.g. environment variables)
@@ -112,14 +143,26 @@ func (p *App) URL(path string) (string, error) {
 	if !p.deployed {
 		return "", errors.New("URL called before Deploy")
 	}
-	return fmt.Sprintf("https://%s-dot-%s-dot-%s.appspot-preview.com%s", p.version(), p.Service, p.ProjectID, path), nil
+	domain := "appspot.com"
+	if p.flex {
+		domain = "appspot-preview.com"
+	}
+	return fmt.Sprintf("https://%s-dot-%s-dot-%s.%s%s", p.version(), p.Service, p.ProjectID, domain, path), nil
 }
 
-// version returns the version that the app will be deployed to.
+// RenderedAppYaml returns the configuration file rendered by a dry run.
+func (p *App) RenderedAppYaml() []byte {
+	return p.rendered
+}
+
+// validate checks that the App is fully specified.
 func (p *App) validate() error {
 	if p.ProjectID == "" {
 		return errors.New("Project ID missing")
 	}
+	if p.Traffic < 0 || p.Traffic > 1 {
+		return fmt.Errorf("Traffic must be between 0 and 1, got %v", p.Traffic)
+	}
 	return nil
 }
 
@@ -135,8 +178,11 @@ func (p *App) Deploy() error {
 	if err := p.validate(); err != nil {
 		return err
 	}
-	if err := p.readService(); err != nil {
-		return fmt.Errorf("could not read service: %w", err)
+	if err := p.readConfig(); err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+	if p.DryRun {
+		return p.dryRun()
 	}
 	if err := p.initAdminService(); err != nil {
 		return fmt.Errorf("could not setup admin service: %w", err)
@@ -159,11 +205,174 @@ func (p *App) Deploy() error {
 		p.Cleanup()
 		return err
 	}
+
+	log.Printf("(%s) Waiting for version %s to serve...", p.Name, p.version())
+	if err := p.waitServing(); err != nil {
+		p.Cleanup()
+		return err
+	}
+	if p.Traffic > 0 {
+		log.Printf("(%s) Routing %v of traffic to version %s...", p.Name, p.Traffic, p.version())
+		if err := p.routeTraffic(); err != nil {
+			p.Cleanup()
+			return fmt.Errorf("could not route traffic: %w", err)
+		}
+	}
 	p.deployed = true
 	log.Printf("(%s) Deploy successful.", p.Name)
 	return nil
 }
 
+// dryRun renders the configuration file that Deploy would use, without
+// writing it to disk or deploying.
+func (p *App) dryRun() error {
+	b, err := os.ReadFile(filepath.Join(p.Dir, p.appYaml()))
+	if err != nil {
+		return err
+	}
+	if len(p.Env) > 0 {
+		if b, err = renderAppYaml(b, p.Env); err != nil {
+			return err
+		}
+	}
+	p.rendered = b
+
+	env := "standard"
+	if p.flex {
+		env = "flexible"
+	}
+	log.Printf("(%s) Dry run: would deploy version %s of service %s to %s (App Engine %s) with config:\n%s",
+		p.Name, p.version(), p.Service, p.ProjectID, env, b)
+	return nil
+}
+
+// readyTimeout returns how long to wait for the deployed version to serve.
+func (p *App) readyTimeout() time.Duration {
+	if p.ReadyTimeout > 0 {
+		return p.ReadyTimeout
+	}
+	return 10 * time.Minute
+}
+
+// waitServing polls the Admin API until the deployed version is serving.
+func (p *App) waitServing() error {
+	deadline := time.Now().Add(p.readyTimeout())
+	for {
+		v, err := p.adminService.Apps.Services.Versions.Get(p.ProjectID, p.Service, p.version()).Do()
+		if err == nil {
+			if v.ServingStatus == "SERVING" {
+				return nil
+			}
+			err = fmt.Errorf("serving status is %q", v.ServingStatus)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("version %v/%v not serving after %v: %w", p.Service, p.version(), p.readyTimeout(), err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// routeTraffic moves p.Traffic of the service's traffic to the deployed
+// version, remembering the previous split so Cleanup can restore it.
+func (p *App) routeTraffic() error {
+	svc, err := p.adminService.Apps.Services.Get(p.ProjectID, p.Service).Do()
+	if err != nil {
+		return err
+	}
+	var prev map[string]float64
+	if svc.Split != nil {
+		prev = svc.Split.Allocations
+	}
+	allocations, err := trafficSplit(prev, p.version(), p.Traffic)
+	if err != nil {
+		return err
+	}
+	// Remember the split to restore before patching, so Cleanup restores it
+	// even if the patch took effect but waiting for it failed. Any share a
+	// previous deployment of this version held goes to the other versions,
+	// since the version is deleted right after.
+	restore, err := trafficSplit(prev, p.version(), 0)
+	if err != nil {
+		return err
+	}
+	delete(restore, p.version())
+	p.prevSplit = &appengine.TrafficSplit{ShardBy: svc.Split.ShardBy, Allocations: restore}
+	return p.patchSplit(&appengine.TrafficSplit{ShardBy: "RANDOM", Allocations: allocations})
+}
+
+// trafficSplit returns allocations that give version the fraction traffic
+// and scale the existing allocations in prev down proportionally.
+// Allocations are rounded to two decimal places and always sum to 1.
+//
+// It fails if no other version is serving: Cleanup couldn't move the
+// traffic back before deleting version, and App Engine refuses to delete a
+// version that receives all of a service's traffic.
+func trafficSplit(prev map[string]float64, version string, traffic float64) (map[string]float64, error) {
+	round := func(f float64) float64 {
+		return float64(int64(f*100+0.5)) / 100
+	}
+
+	var others []string
+	var total float64
+	for v, a := range prev {
+		if v != version && a > 0 {
+			others = append(others, v)
+			total += a
+		}
+	}
+	if len(others) == 0 {
+		return nil, fmt.Errorf("no version other than %s is serving, so its traffic couldn't be restored before deleting it", version)
+	}
+
+	split := map[string]float64{version: round(traffic)}
+	if split[version] >= 1 {
+		return map[string]float64{version: 1}, nil
+	}
+
+	// Put any rounding error on the largest remaining allocation.
+	sort.Slice(others, func(i, j int) bool {
+		if prev[others[i]] != prev[others[j]] {
+			return prev[others[i]] > prev[others[j]]
+		}
+		return others[i] < others[j]
+	})
+	remaining := 100 - int64(split[version]*100+0.5)
+	var assigned int64
+	for _, v := range others[1:] {
+		a := int64(prev[v]/total*float64(remaining) + 0.5)
+		if a == 0 {
+			continue
+		}
+		split[v] = float64(a) / 100
+		assigned += a
+	}
+	split[others[0]] = float64(remaining-assigned) / 100
+	return split, nil
+}
+
+// patchSplit updates the service's traffic split and waits for the change
+// to take effect.
+func (p *App) patchSplit(split *appengine.TrafficSplit) error {
+	op, err := p.adminService.Apps.Services.Patch(p.ProjectID, p.Service, &appengine.Service{Split: split}).UpdateMask("split").Do()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(p.readyTimeout())
+	for !op.Done {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("operation %s not done after %v", op.Name, p.readyTimeout())
+		}
+		time.Sleep(2 * time.Second)
+		if op, err = p.adminService.Apps.Operations.Get(p.ProjectID, path.Base(op.Name)).Do(); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("operation %s failed: %s", op.Name, op.Error.Message)
+	}
+	return nil
+}
+
 // appYaml returns the path of the config file.
 func (p *App) appYaml() string {
 	if p.AppYaml != "" {
@@ -183,7 +392,8 @@ func (p *App) envAppYaml() (string, error) {
 	tmp := "aeintegrate." + base
 
 	if len(p.Env) == 0 {
-		err := os.Symlink(filepath.Join(p.Dir, base), filepath.Join(p.Dir, tmp))
+		// The link target is relative to the link, which lives next to base.
+		err := os.Symlink(base, filepath.Join(p.Dir, tmp))
 		if err != nil {
 			return "", err
 		}
@@ -195,48 +405,64 @@ func (p *App) envAppYaml() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if b, err = renderAppYaml(b, p.Env); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(p.Dir, tmp), b, 0755); err != nil {
+		return "", err
+	}
+
+	p.tempAppYaml = tmp
+	return p.tempAppYaml, nil
+}
+
+// renderAppYaml returns the configuration file b with the values in the
+// env_variables section replaced by those in env. Every key in env must
+// already be present in env_variables.
+func renderAppYaml(b []byte, env map[string]string) ([]byte, error) {
 	var c yaml.MapSlice
 	if err := yaml.Unmarshal(b, &c); err != nil {
-		return "", err
+		return nil, err
 	}
 
+	var yamlVals yaml.MapSlice
+	found := false
 	for _, e := range c {
 		k, ok := e.Key.(string)
 		if !ok || k != "env_variables" {
 			continue
 		}
-
-		yamlVals, ok := e.Value.(yaml.MapSlice)
-		if !ok {
-			return "", fmt.Errorf("expected MapSlice for env_variables")
-		}
-
-	ENTRY:
-		for mapKey, newVal := range p.Env {
-			for i, kv := range yamlVals {
-				yamlKey, ok := kv.Key.(string)
-				if !ok {
-					return "", fmt.Errorf("expected string for env_variables/%#v", kv.Key)
-				}
-				if yamlKey == mapKey {
-					yamlVals[i].Value = newVal
-					break ENTRY
-				}
-			}
-			return "", fmt.Errorf("could not find key %s in env_variables", mapKey)
+		if yamlVals, ok = e.Value.(yaml.MapSlice); !ok {
+			return nil, fmt.Errorf("expected MapSlice for env_variables")
 		}
+		found = true
+	}
+	if !found {
+		return nil, errors.New("could not find env_variables")
 	}
 
-	b, err = yaml.Marshal(c)
-	if err != nil {
-		return "", err
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
 	}
-	if err := os.WriteFile(filepath.Join(p.Dir, tmp), b, 0755); err != nil {
-		return "", err
+	sort.Strings(keys)
+
+ENTRY:
+	for _, mapKey := range keys {
+		for i, kv := range yamlVals {
+			yamlKey, ok := kv.Key.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string for env_variables/%#v", kv.Key)
+			}
+			if yamlKey == mapKey {
+				yamlVals[i].Value = env[mapKey]
+				continue ENTRY
+			}
+		}
+		return nil, fmt.Errorf("could not find key %s in env_variables", mapKey)
 	}
 
-	p.tempAppYaml = tmp
-	return p.tempAppYaml, nil
+	return yaml.Marshal(c)
 }
 
 func (p *App) deployCmd() (*exec.Cmd, error) {
@@ -263,29 +489,32 @@ func (p *App) deployCmd() (*exec.Cmd, error) {
 	return cmd, nil
 }
 
-// readService reads the service out of the app.yaml file.
-func (p *App) readService() error {
-	if p.Service != "" {
-		return nil
-	}
-
+// readConfig reads the service and environment out of the app.yaml file.
+func (p *App) readConfig() error {
 	b, err := os.ReadFile(filepath.Join(p.Dir, p.appYaml()))
 	if err != nil {
 		return err
 	}
 
-	var s struct {
+	var c struct {
+		Runtime string `yaml:"runtime"`
 		Service string `yaml:"service"`
+		Env     string `yaml:"env"`
 	}
-	if err := yaml.Unmarshal(b, &s); err != nil {
+	if err := yaml.Unmarshal(b, &c); err != nil {
 		return err
 	}
-
-	if s.Service == "" {
-		s.Service = "default"
+	if c.Runtime == "" {
+		return fmt.Errorf("%s: runtime missing", p.appYaml())
 	}
 
-	p.Service = s.Service
+	if p.Service == "" {
+		p.Service = c.Service
+	}
+	if p.Service == "" {
+		p.Service = "default"
+	}
+	p.flex = c.Env == "flex" || c.Env == "flexible"
 	return nil
 }
 
@@ -309,6 +538,10 @@ func (p *App) initAdminService() error {
 
 // Cleanup deletes the created version from App Engine.
 func (p *App) Cleanup() error {
+	if p.DryRun {
+		return nil
+	}
+
 	// NOTE: don't check whether p.deployed is set.
 	// We may want to attempt to clean up if deployment failed.
 	// However, we require adminService to be set up, which happens during Deploy().
@@ -329,6 +562,15 @@ func (p *App) Cleanup() error {
 
 	log.Printf("(%s) Cleaning up.", p.Name)
 
+	// A version receiving traffic can't be deleted.
+	if p.prevSplit != nil {
+		if err := p.patchSplit(p.prevSplit); err != nil {
+			log.Printf("(%s) Could not restore traffic split: %v", p.Name, err)
+		} else {
+			p.prevSplit = nil
+		}
+	}
+
 	var err error
 	for try := 0; try < 10; try++ {
 		_, err = p.adminService.Apps.Services.Versions.Delete(p.ProjectID, p.Service, p.version()).Do()
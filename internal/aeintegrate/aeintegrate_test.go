@@ -0,0 +1,255 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aeintegrate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testAppYaml = `runtime: go125
+service: api
+env_variables:
+  BUCKET: my-bucket
+  GREETING: hello
+  MODE: prod
+handlers:
+- url: /.*
+  script: auto
+`
+
+func TestRenderAppYaml(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "single override",
+			in:   testAppYaml,
+			env:  map[string]string{"GREETING": "hi"},
+			want: `runtime: go125
+service: api
+env_variables:
+  BUCKET: my-bucket
+  GREETING: hi
+  MODE: prod
+handlers:
+- url: /.*
+  script: auto
+`,
+		},
+		{
+			name: "multiple overrides",
+			in:   testAppYaml,
+			env:  map[string]string{"BUCKET": "test-bucket", "MODE": "test"},
+			want: `runtime: go125
+service: api
+env_variables:
+  BUCKET: test-bucket
+  GREETING: hello
+  MODE: test
+handlers:
+- url: /.*
+  script: auto
+`,
+		},
+		{
+			name:    "unknown key",
+			in:      testAppYaml,
+			env:     map[string]string{"MISSING": "x"},
+			wantErr: "could not find key MISSING",
+		},
+		{
+			name:    "no env_variables",
+			in:      "runtime: go125\n",
+			env:     map[string]string{"MODE": "test"},
+			wantErr: "could not find env_variables",
+		},
+		{
+			name:    "env_variables not a map",
+			in:      "runtime: go125\nenv_variables: oops\n",
+			env:     map[string]string{"MODE": "test"},
+			wantErr: "expected MapSlice",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := renderAppYaml([]byte(tc.in), tc.env)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("renderAppYaml got err %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderAppYaml: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("renderAppYaml got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrafficSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    map[string]float64
+		traffic float64
+		want    map[string]float64
+	}{
+		{
+			name:    "single version",
+			prev:    map[string]float64{"v1": 1},
+			traffic: 0.25,
+			want:    map[string]float64{"v1": 0.75, "test": 0.25},
+		},
+		{
+			name:    "proportional",
+			prev:    map[string]float64{"v1": 0.5, "v2": 0.5},
+			traffic: 0.1,
+			want:    map[string]float64{"v1": 0.45, "v2": 0.45, "test": 0.1},
+		},
+		{
+			name:    "rounded to sum to one",
+			prev:    map[string]float64{"v1": 0.34, "v2": 0.33, "v3": 0.33},
+			traffic: 0.01,
+			want:    map[string]float64{"v1": 0.33, "v2": 0.33, "v3": 0.33, "test": 0.01},
+		},
+		{
+			name:    "full migration",
+			prev:    map[string]float64{"v1": 0.5, "v2": 0.5},
+			traffic: 1,
+			want:    map[string]float64{"test": 1},
+		},
+		{
+			name:    "redeploy alongside another version",
+			prev:    map[string]float64{"test": 0.5, "v1": 0.5},
+			traffic: 0.2,
+			want:    map[string]float64{"v1": 0.8, "test": 0.2},
+		},
+		{
+			name:    "restore without the redeployed version",
+			prev:    map[string]float64{"test": 0.5, "v1": 0.25, "v2": 0.25},
+			traffic: 0,
+			want:    map[string]float64{"v1": 0.5, "v2": 0.5, "test": 0},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := trafficSplit(tc.prev, "test", tc.traffic)
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("trafficSplit(%v, %v) = %v, %v; want %v", tc.prev, tc.traffic, got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrafficSplitNothingToRestore(t *testing.T) {
+	for name, prev := range map[string]map[string]float64{
+		"no previous split":               nil,
+		"redeploy of the serving version": {"test": 1},
+		"other versions without traffic":  {"test": 1, "v1": 0},
+	} {
+		if got, err := trafficSplit(prev, "test", 0.5); err == nil {
+			t.Errorf("%s: trafficSplit(%v) = %v, want an error", name, prev, got)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(testAppYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{
+		Name:      "dryrun",
+		Dir:       dir,
+		ProjectID: "my-project",
+		Env:       map[string]string{"MODE": "test"},
+		DryRun:    true,
+	}
+	if err := app.Deploy(); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if err := app.Cleanup(); err != nil {
+		t.Errorf("Cleanup: %v", err)
+	}
+
+	if app.Deployed() {
+		t.Errorf("Deployed() = true after a dry run")
+	}
+	if app.Service != "api" {
+		t.Errorf("Service = %q, want %q", app.Service, "api")
+	}
+	if got := string(app.RenderedAppYaml()); !strings.Contains(got, "MODE: test") {
+		t.Errorf("RenderedAppYaml() does not contain override:\n%s", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("dry run left files behind: %v", entries)
+	}
+}
+
+func TestDryRunInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.yaml"), []byte("service: api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		app  App
+	}{
+		{"no project", App{Name: "a", Dir: dir, DryRun: true}},
+		{"no runtime", App{Name: "a", Dir: dir, ProjectID: "p", DryRun: true}},
+		{"bad traffic", App{Name: "a", Dir: dir, ProjectID: "p", Traffic: 1.5, DryRun: true}},
+	}
+	for _, tc := range tests {
+		if err := tc.app.Deploy(); err == nil {
+			t.Errorf("%s: Deploy succeeded, want error", tc.name)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	for _, flex := range []bool{false, true} {
+		app := &App{Name: "a", ProjectID: "p", Service: "s", deployed: true, flex: flex}
+		got, err := app.URL("/path")
+		if err != nil {
+			t.Fatalf("URL: %v", err)
+		}
+		want := "https://" + app.version() + "-dot-s-dot-p.appspot.com/path"
+		if flex {
+			want = "https://" + app.version() + "-dot-s-dot-p.appspot-preview.com/path"
+		}
+		if got != want {
+			t.Errorf("URL(flex=%v) = %q, want %q", flex, got, want)
+		}
+	}
+}
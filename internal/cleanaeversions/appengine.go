@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	appengine "google.golang.org/api/appengine/v1"
+)
+
+// appEngineSource lists and deletes App Engine versions.
+type appEngineSource struct {
+	gae     *appengine.APIService
+	project string
+	service string // If empty, all services.
+}
+
+func (s *appEngineSource) list(ctx context.Context) ([]resource, error) {
+	var services []*appengine.Service
+	if s.service != "" {
+		svc, err := s.gae.Apps.Services.Get(s.project, s.service).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("could not get App Engine service %q: %w", s.service, err)
+		}
+		services = append(services, svc)
+	} else {
+		if err := s.gae.Apps.Services.List(s.project).Pages(ctx, func(lsr *appengine.ListServicesResponse) error {
+			services = append(services, lsr.Services...)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("could not list App Engine services: %w", err)
+		}
+	}
+
+	var resources []resource
+	for _, svc := range services {
+		var allocations map[string]float64
+		if svc.Split != nil {
+			allocations = svc.Split.Allocations
+		}
+		if err := s.gae.Apps.Services.Versions.List(s.project, svc.Id).Pages(ctx, func(lvr *appengine.ListVersionsResponse) error {
+			for _, v := range lvr.Versions {
+				created, err := time.Parse(time.RFC3339, v.CreateTime)
+				if err != nil {
+					return fmt.Errorf("version %s/%s: bad create time: %w", svc.Id, v.Id, err)
+				}
+				resources = append(resources, resource{
+					kind:    "appengine",
+					group:   svc.Id,
+					id:      v.Id,
+					name:    v.Name,
+					created: created,
+					serving: allocations[v.Id] > 0,
+				})
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("could not list versions for %q: %w", svc.Id, err)
+		}
+	}
+	return resources, nil
+}
+
+func (s *appEngineSource) delete(ctx context.Context, r resource, wait bool) error {
+	op, err := s.gae.Apps.Services.Versions.Delete(s.project, r.group, r.id).Context(ctx).Do()
+	if err != nil || !wait {
+		return err
+	}
+	id := path.Base(op.Name)
+	return waitDone(ctx, func() (bool, error) {
+		op, err := s.gae.Apps.Operations.Get(s.project, id).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		if op.Done && op.Error != nil {
+			return true, fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
+		}
+		return op.Done, nil
+	})
+}
@@ -12,24 +12,45 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Command cleaneversions deletes App Engine versions for a given project, service and/or version ID filter.
+// Command cleanaeversions deletes old App Engine versions, Cloud Run
+// revisions and Cloud Functions left behind by CI, according to a policy.
+//
+// Resources receiving traffic are never deleted. Within each group (App Engine
+// service, Cloud Run service, or Cloud Functions region) the -keep newest
+// resources are kept, as are resources younger than -min-age. Only resources
+// whose ID matches -filter and does not match -exclude are deleted.
+//
+// The plan is printed as a table before anything is deleted.
 //
 //	Usage of cleanaeversions:
 //	  -async
 //	      Don't wait for successful deletion.
+//	  -exclude regexp
+//	      Exclude regexp for IDs. Matching resources are never deleted.
 //	  -filter regexp
-//	      Filter regexp for version IDs. If empty, attempts to clean all versions.
-//	  -n  Dry run.
+//	      Filter regexp for IDs. If empty, attempts to clean all resources.
+//	  -keep N
+//	      Keep the N newest resources in each group.
+//	  -kinds list
+//	      Comma-separated list of resource kinds to clean: appengine, run, functions. (default "appengine")
+//	  -min-age duration
+//	      Only delete resources older than duration.
+//	  -n  Dry run: print the plan without deleting anything.
+//	  -parallel N
+//	      Delete at most N resources at a time. (default 10)
 //	  -project Project ID
 //	      Project ID to clean.
+//	  -region region
+//	      Cloud Run and Cloud Functions region to clean. (default "-", all regions)
 //	  -service Service/module ID
-//	      Service/module ID to clean. If omitted, cleans all services.
+//	      App Engine or Cloud Run service to clean. If omitted, cleans all services.
 package main
 
 import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
@@ -37,27 +58,33 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
-	"golang.org/x/oauth2/google"
-
 	appengine "google.golang.org/api/appengine/v1"
+	cloudfunctions "google.golang.org/api/cloudfunctions/v2"
+	run "google.golang.org/api/run/v2"
 )
 
 var (
-	proj    = flag.String("project", "", "`Project ID` to clean.")
-	service = flag.String("service", "", "`Service/module ID` to clean. If omitted, cleans all services.")
-	filter  = flag.String("filter", "", "Filter `regexp` for version IDs. If empty, attempts to clean all versions.")
-	async   = flag.Bool("async", false, "Don't wait for successful deletion.")
-	dryRun  = flag.Bool("n", false, "Dry run.")
+	proj     = flag.String("project", "", "`Project ID` to clean.")
+	service  = flag.String("service", "", "App Engine or Cloud Run `Service/module ID` to clean. If omitted, cleans all services.")
+	filter   = flag.String("filter", "", "Filter `regexp` for IDs. If empty, attempts to clean all resources.")
+	exclude  = flag.String("exclude", "", "Exclude `regexp` for IDs. Matching resources are never deleted.")
+	keep     = flag.Int("keep", 0, "Keep the `N` newest resources in each group.")
+	minAge   = flag.Duration("min-age", 0, "Only delete resources older than `duration`.")
+	kinds    = flag.String("kinds", "appengine", "Comma-separated `list` of resource kinds to clean: appengine, run, functions.")
+	region   = flag.String("region", "-", "Cloud Run and Cloud Functions `region` to clean.")
+	parallel = flag.Int("parallel", 10, "Delete at most `N` resources at a time.")
+	async    = flag.Bool("async", false, "Don't wait for successful deletion.")
+	dryRun   = flag.Bool("n", false, "Dry run: print the plan without deleting anything.")
 )
 
-var gae *appengine.APIService
-
-type pendingDelete struct {
-	service string
-	version string
-	op      *appengine.Operation
+// A source lists and deletes one kind of resource.
+type source interface {
+	list(ctx context.Context) ([]resource, error)
+	// delete deletes r. If wait is set, it waits for the deletion to complete.
+	delete(ctx context.Context, r resource, wait bool) error
 }
 
 func main() {
@@ -67,115 +94,149 @@ func main() {
 		flag.Usage()
 		os.Exit(2)
 	}
+	if *parallel < 1 {
+		fmt.Fprintln(os.Stderr, "-parallel must be at least 1")
+		os.Exit(2)
+	}
 
-	filterRE, err := regexp.Compile(*filter)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Filter is not a valid regexp: %v", err)
+	p := policy{keep: *keep, minAge: *minAge}
+	var err error
+	if p.filter, err = regexp.Compile(*filter); err != nil {
+		fmt.Fprintf(os.Stderr, "Filter is not a valid regexp: %v\n", err)
 		os.Exit(2)
 	}
-	_ = filterRE
+	if *exclude != "" {
+		if p.exclude, err = regexp.Compile(*exclude); err != nil {
+			fmt.Fprintf(os.Stderr, "Exclude is not a valid regexp: %v\n", err)
+			os.Exit(2)
+		}
+	}
 
 	ctx := context.Background()
-	hc, err := google.DefaultClient(ctx, appengine.CloudPlatformScope)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not create DefaultClient: %v", err)
-		os.Exit(1)
-	}
-	gae, err = appengine.New(hc)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not create App Engine service: %v", err)
-		os.Exit(1)
+	sources := map[string]source{}
+	for _, kind := range strings.Split(*kinds, ",") {
+		s, err := newSource(ctx, strings.TrimSpace(kind))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		sources[strings.TrimSpace(kind)] = s
 	}
 
-	var services []string
-	if *service != "" {
-		services = append(services, *service)
-	} else {
-		if err := gae.Apps.Services.List(*proj).Pages(ctx, func(lsr *appengine.ListServicesResponse) error {
-			for _, s := range lsr.Services {
-				services = append(services, s.Id)
-			}
-			return nil
-		}); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not list App Engine services: %v", err)
+	var resources []resource
+	for kind, s := range sources {
+		rs, err := s.list(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not list %s resources: %v\n", kind, err)
 			os.Exit(1)
 		}
+		resources = append(resources, rs...)
 	}
 
-	var pending []pendingDelete
-
-	for _, service := range services {
-		if err := gae.Apps.Services.Versions.List(*proj, service).Pages(ctx, func(lvr *appengine.ListVersionsResponse) error {
-			for _, v := range lvr.Versions {
-				if !filterRE.MatchString(v.Id) {
-					continue
-				}
+	decisions := p.plan(resources, time.Now())
+	printPlan(os.Stdout, decisions)
+	if *dryRun {
+		return
+	}
 
-				log.Printf("Deleting %s/%s", service, v.Id)
-				if *dryRun {
-					continue
-				}
+	if !*async {
+		log.Printf("Deleting and waiting for operations to complete.")
+	}
+	deleted, failed := deleteAll(ctx, sources, decisions, *parallel, !*async)
+	log.Printf("Deleted %d, failed %d.", deleted, failed)
+	if failed != 0 {
+		log.Printf("FAILED (%d)", failed)
+		os.Exit(1)
+	}
+}
 
-				op, err := gae.Apps.Services.Versions.Delete(*proj, service, v.Id).Do()
-				if err != nil {
-					log.Printf("Could not delete version %s/%s: %v\n", service, v.Id, err)
-				} else {
-					pending = append(pending, pendingDelete{service: service, version: v.Id, op: op})
-				}
-			}
-			return nil
-		}); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not list versions for %q: %v\n", service, err)
-			os.Exit(1)
+// newSource creates the source for the given kind of resource.
+func newSource(ctx context.Context, kind string) (source, error) {
+	switch kind {
+	case "appengine":
+		gae, err := appengine.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create App Engine service: %w", err)
+		}
+		return &appEngineSource{gae: gae, project: *proj, service: *service}, nil
+	case "run":
+		rs, err := run.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create Cloud Run service: %w", err)
+		}
+		return &cloudRunSource{run: rs, project: *proj, region: *region, service: *service}, nil
+	case "functions":
+		// Functions are deleted outright, so require an explicit filter.
+		if *filter == "" {
+			return nil, fmt.Errorf("-filter is required to clean functions")
 		}
+		cf, err := cloudfunctions.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not create Cloud Functions service: %w", err)
+		}
+		return &functionsSource{cf: cf, project: *proj, region: *region}, nil
 	}
+	return nil, fmt.Errorf("unknown kind %q", kind)
+}
 
-	if *async {
-		log.Printf("Not waiting for operations to complete. Exiting.")
-		os.Exit(0)
+// printPlan writes decisions to w as a table, followed by a summary.
+func printPlan(w io.Writer, decisions []decision) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tGROUP\tID\tCREATED\tACTION\tREASON")
+	var n int
+	for _, d := range decisions {
+		action, reason := "keep", d.reason
+		if d.delete {
+			action, reason = "DELETE", "-"
+			n++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.kind, d.group, d.id, d.created.Format(time.RFC3339), action, reason)
 	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d to delete, %d to keep.\n", n, len(decisions)-n)
+}
 
-	log.Printf("Waiting for operations to complete.")
-
-	var failed int64
+// deleteAll deletes the resources planned for deletion, at most parallel at
+// a time, and returns the number deleted and failed.
+func deleteAll(ctx context.Context, sources map[string]source, decisions []decision, parallel int, wait bool) (deleted, failed int64) {
+	sem := make(chan struct{}, parallel)
 	var wg sync.WaitGroup
-	wg.Add(len(pending))
-	for _, pd := range pending {
-		pd := pd
+	for _, d := range decisions {
+		if !d.delete {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
 		go func() {
-			if err := waitForCompletion(pd); err != nil {
-				log.Printf("FAILED %v/%v/%v: %v", *proj, pd.service, pd.version, err)
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := sources[d.kind].delete(ctx, d.resource, wait); err != nil {
+				log.Printf("FAILED %v %v/%v: %v", d.kind, d.group, d.id, err)
 				atomic.AddInt64(&failed, 1)
-			} else {
-				log.Printf("Deleted %v/%v/%v", *proj, pd.service, pd.version)
+				return
 			}
-			wg.Done()
+			log.Printf("Deleted %v %v/%v", d.kind, d.group, d.id)
+			atomic.AddInt64(&deleted, 1)
 		}()
 	}
 	wg.Wait()
-
-	if failed != 0 {
-		log.Printf("FAILED (%d)", failed)
-		os.Exit(1)
-	}
+	return deleted, failed
 }
 
-func waitForCompletion(pd pendingDelete) error {
-	parts := strings.Split(pd.op.Name, "/")
-	id := parts[len(parts)-1]
+// waitDone calls poll until it reports that an operation is done or fails.
+func waitDone(ctx context.Context, poll func() (done bool, err error)) error {
 	for {
-		op, err := gae.Apps.Operations.Get(*proj, id).Do()
-		if err != nil {
+		done, err := poll()
+		if err != nil || done {
 			return err
 		}
-		if !op.Done {
-			// 5 to 10 second sleep.
-			time.Sleep(time.Duration(5+rand.Float64()*5) * time.Second)
-			continue
-		}
-		if op.Error == nil {
-			return nil
+		// 5 to 10 second sleep.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(5+rand.Float64()*5) * time.Second):
 		}
-		return fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
 	}
 }
@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	run "google.golang.org/api/run/v2"
+)
+
+// cloudRunSource lists and deletes Cloud Run revisions.
+type cloudRunSource struct {
+	run     *run.Service
+	project string
+	region  string // "-" for all regions.
+	service string // If empty, all services.
+}
+
+func (s *cloudRunSource) list(ctx context.Context) ([]resource, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", s.project, s.region)
+	var services []*run.GoogleCloudRunV2Service
+	if err := s.run.Projects.Locations.Services.List(parent).Pages(ctx, func(lsr *run.GoogleCloudRunV2ListServicesResponse) error {
+		for _, svc := range lsr.Services {
+			if s.service == "" || path.Base(svc.Name) == s.service {
+				services = append(services, svc)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not list Cloud Run services: %w", err)
+	}
+
+	var resources []resource
+	for _, svc := range services {
+		serving := servingRevisions(svc)
+		group := location(svc.Name) + "/" + path.Base(svc.Name)
+		if err := s.run.Projects.Locations.Services.Revisions.List(svc.Name).Pages(ctx, func(lrr *run.GoogleCloudRunV2ListRevisionsResponse) error {
+			for _, rev := range lrr.Revisions {
+				created, err := time.Parse(time.RFC3339, rev.CreateTime)
+				if err != nil {
+					return fmt.Errorf("revision %s: bad create time: %w", rev.Name, err)
+				}
+				id := path.Base(rev.Name)
+				resources = append(resources, resource{
+					kind:    "run",
+					group:   group,
+					id:      id,
+					name:    rev.Name,
+					created: created,
+					serving: serving[id],
+				})
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("could not list revisions for %q: %w", svc.Name, err)
+		}
+	}
+	return resources, nil
+}
+
+// servingRevisions returns the revisions of svc that receive traffic,
+// including tagged revisions that can be reached through their tag URL.
+func servingRevisions(svc *run.GoogleCloudRunV2Service) map[string]bool {
+	serving := map[string]bool{}
+	for _, ts := range svc.TrafficStatuses {
+		if ts.Percent == 0 && ts.Tag == "" {
+			continue
+		}
+		rev := ts.Revision
+		if ts.Type == "TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST" {
+			rev = path.Base(svc.LatestReadyRevision)
+		}
+		serving[rev] = true
+	}
+	return serving
+}
+
+func (s *cloudRunSource) delete(ctx context.Context, r resource, wait bool) error {
+	op, err := s.run.Projects.Locations.Services.Revisions.Delete(r.name).Context(ctx).Do()
+	if err != nil || !wait {
+		return err
+	}
+	return waitDone(ctx, func() (bool, error) {
+		op, err := s.run.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		if op.Done && op.Error != nil {
+			return true, fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
+		}
+		return op.Done, nil
+	})
+}
+
+// location returns the location segment of a resource name of the form
+// projects/P/locations/L/...
+func location(name string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[3]
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	cloudfunctions "google.golang.org/api/cloudfunctions/v2"
+)
+
+// functionsSource lists and deletes Cloud Functions.
+// Functions are grouped by region.
+type functionsSource struct {
+	cf      *cloudfunctions.Service
+	project string
+	region  string // "-" for all regions.
+}
+
+func (s *functionsSource) list(ctx context.Context) ([]resource, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", s.project, s.region)
+	var resources []resource
+	if err := s.cf.Projects.Locations.Functions.List(parent).Pages(ctx, func(lfr *cloudfunctions.ListFunctionsResponse) error {
+		for _, fn := range lfr.Functions {
+			created, err := time.Parse(time.RFC3339, fn.CreateTime)
+			if err != nil {
+				return fmt.Errorf("function %s: bad create time: %w", fn.Name, err)
+			}
+			resources = append(resources, resource{
+				kind:    "functions",
+				group:   location(fn.Name),
+				id:      path.Base(fn.Name),
+				name:    fn.Name,
+				created: created,
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("could not list Cloud Functions: %w", err)
+	}
+	return resources, nil
+}
+
+func (s *functionsSource) delete(ctx context.Context, r resource, wait bool) error {
+	op, err := s.cf.Projects.Locations.Functions.Delete(r.name).Context(ctx).Do()
+	if err != nil || !wait {
+		return err
+	}
+	return waitDone(ctx, func() (bool, error) {
+		op, err := s.cf.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		if op.Done && op.Error != nil {
+			return true, fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
+		}
+		return op.Done, nil
+	})
+}
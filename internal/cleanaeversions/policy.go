@@ -0,0 +1,98 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// resource is a deletable resource, such as an App Engine version.
+type resource struct {
+	kind    string    // The kind of resource, e.g. "appengine".
+	group   string    // The group the resource belongs to, e.g. its service.
+	id      string    // The resource ID shown in the plan, e.g. the version ID.
+	name    string    // The full resource name used for deletion.
+	created time.Time // When the resource was created.
+	serving bool      // Whether the resource is receiving traffic.
+}
+
+// policy decides which resources to delete.
+type policy struct {
+	// keep is the number of newest resources to keep in each group.
+	keep int
+	// minAge is the minimum age of a deleted resource.
+	minAge time.Duration
+	// filter, if set, must match the ID of a deleted resource.
+	filter *regexp.Regexp
+	// exclude, if set, must not match the ID of a deleted resource.
+	exclude *regexp.Regexp
+}
+
+// decision is the action planned for a resource.
+type decision struct {
+	resource
+	delete bool
+	reason string
+}
+
+// plan applies the policy to resources, returning a decision for each one.
+// Decisions are sorted by kind and group, newest first within each group.
+// Resources receiving traffic are never deleted.
+func (p policy) plan(resources []resource, now time.Time) []decision {
+	sorted := make([]resource, len(resources))
+	copy(sorted, resources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if !a.created.Equal(b.created) {
+			return a.created.After(b.created)
+		}
+		return a.id < b.id
+	})
+
+	decisions := make([]decision, 0, len(sorted))
+	newer := 0 // Number of newer resources seen in the current group.
+	for i, r := range sorted {
+		if i > 0 && (r.kind != sorted[i-1].kind || r.group != sorted[i-1].group) {
+			newer = 0
+		}
+		d := decision{resource: r}
+		switch {
+		case p.filter != nil && !p.filter.MatchString(r.id):
+			d.reason = "does not match filter"
+		case p.exclude != nil && p.exclude.MatchString(r.id):
+			d.reason = "matches exclude"
+		case r.serving:
+			d.reason = "receiving traffic"
+		case newer < p.keep:
+			d.reason = fmt.Sprintf("one of %d newest", p.keep)
+		case now.Sub(r.created) < p.minAge:
+			d.reason = fmt.Sprintf("younger than %v", p.minAge)
+		default:
+			d.delete = true
+		}
+		newer++
+		decisions = append(decisions, d)
+	}
+	return decisions
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+
+func daysAgo(d int) time.Time {
+	return now.Add(-time.Duration(d) * 24 * time.Hour)
+}
+
+func testResources() []resource {
+	return []resource{
+		{kind: "appengine", group: "default", id: "v1", created: daysAgo(5)},
+		{kind: "appengine", group: "default", id: "v2", created: daysAgo(4), serving: true},
+		{kind: "appengine", group: "default", id: "test-a", created: daysAgo(3)},
+		{kind: "appengine", group: "default", id: "test-b", created: daysAgo(2)},
+		{kind: "appengine", group: "default", id: "test-c", created: daysAgo(1)},
+		{kind: "appengine", group: "api", id: "test-d", created: daysAgo(3)},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy policy
+		want   map[string]string // ID to reason; "" means deleted.
+	}{
+		{
+			name:   "delete all but serving",
+			policy: policy{},
+			want: map[string]string{
+				"v1": "", "v2": "receiving traffic",
+				"test-a": "", "test-b": "", "test-c": "", "test-d": "",
+			},
+		},
+		{
+			name:   "keep newest per group",
+			policy: policy{keep: 2},
+			want: map[string]string{
+				"v1": "", "v2": "receiving traffic",
+				"test-a": "", "test-b": "one of 2 newest", "test-c": "one of 2 newest",
+				"test-d": "one of 2 newest",
+			},
+		},
+		{
+			name:   "min age",
+			policy: policy{minAge: 36 * time.Hour},
+			want: map[string]string{
+				"v1": "", "v2": "receiving traffic",
+				"test-a": "", "test-b": "", "test-c": "younger than 36h0m0s", "test-d": "",
+			},
+		},
+		{
+			name:   "filter and exclude",
+			policy: policy{filter: regexp.MustCompile("^test-"), exclude: regexp.MustCompile("-b$")},
+			want: map[string]string{
+				"v1": "does not match filter", "v2": "does not match filter",
+				"test-a": "", "test-b": "matches exclude", "test-c": "", "test-d": "",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decisions := tc.policy.plan(testResources(), now)
+			if len(decisions) != len(tc.want) {
+				t.Fatalf("got %d decisions, want %d", len(decisions), len(tc.want))
+			}
+			for _, d := range decisions {
+				want := tc.want[d.id]
+				if d.delete != (want == "") || d.reason != want {
+					t.Errorf("%s: got delete=%v reason=%q, want reason %q", d.id, d.delete, d.reason, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanOrder(t *testing.T) {
+	var got []string
+	for _, d := range (policy{}).plan(testResources(), now) {
+		got = append(got, d.group+"/"+d.id)
+	}
+	want := "api/test-d default/test-c default/test-b default/test-a default/v2 default/v1"
+	if strings.Join(got, " ") != want {
+		t.Errorf("plan order = %v, want %v", got, want)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	var buf bytes.Buffer
+	printPlan(&buf, policy{keep: 1}.plan(testResources()[:2], now))
+	want := `KIND       GROUP    ID  CREATED               ACTION  REASON
+appengine  default  v2  2026-01-06T00:00:00Z  keep    receiving traffic
+appengine  default  v1  2026-01-05T00:00:00Z  DELETE  -
+
+1 to delete, 1 to keep.
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printPlan got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// fakeSource records deletions and tracks how many run concurrently.
+type fakeSource struct {
+	mu      sync.Mutex
+	active  int
+	peak    int
+	deleted []string
+}
+
+func (s *fakeSource) list(context.Context) ([]resource, error) { return nil, nil }
+
+func (s *fakeSource) delete(_ context.Context, r resource, _ bool) error {
+	s.mu.Lock()
+	s.active++
+	if s.active > s.peak {
+		s.peak = s.active
+	}
+	s.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if r.id == "test-b" {
+		return errors.New("boom")
+	}
+	s.deleted = append(s.deleted, r.id)
+	return nil
+}
+
+func TestDeleteAll(t *testing.T) {
+	src := &fakeSource{}
+	decisions := (policy{}).plan(testResources(), now)
+	deleted, failed := deleteAll(context.Background(), map[string]source{"appengine": src}, decisions, 2, true)
+	if deleted != 4 || failed != 1 {
+		t.Errorf("deleteAll = %d deleted, %d failed, want 4, 1", deleted, failed)
+	}
+	if src.peak > 2 {
+		t.Errorf("%d concurrent deletes, want at most 2", src.peak)
+	}
+	for _, id := range src.deleted {
+		if id == "v2" {
+			t.Errorf("deleted serving version v2")
+		}
+	}
+}
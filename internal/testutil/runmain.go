@@ -17,10 +17,16 @@ package testutil
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -101,3 +107,178 @@ func (r *Runner) Run(env map[string]string, timeout time.Duration, args ...strin
 	}
 	return bufOut.Bytes(), bufErr.Bytes(), nil
 }
+
+// shutdownTimeout is how long Server.Stop waits after SIGTERM before the
+// process is killed.
+const shutdownTimeout = 10 * time.Second
+
+// Server is a built binary running as a long-lived HTTP server.
+// It is started by Runner.Start.
+type Server struct {
+	// URL is the base URL of the server, e.g. "http://127.0.0.1:8080".
+	URL string
+	// Port is the port passed to the binary in the PORT environment variable.
+	Port int
+
+	t    *testing.T
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the process has exited
+	err  error         // result of cmd.Wait, valid once done is closed
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// Start runs the built binary as a server. It picks a free local port, passes
+// it to the binary in the PORT environment variable and waits until a GET
+// request for readyPath gets a non-5xx response or timeout has been reached.
+//
+// The binary's stdout and stderr are streamed to the test log. The server is
+// sent SIGTERM when the test finishes, unless Server.Stop was called first.
+func (r *Runner) Start(env map[string]string, readyPath string, timeout time.Duration, args ...string) (*Server, error) {
+	if !r.Built() {
+		return nil, fmt.Errorf("tried to start when binary not built")
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("could not find a free port: %w", err)
+	}
+
+	environ := os.Environ()
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	environ = append(environ, "PORT="+strconv.Itoa(port))
+
+	cmd := exec.Command(r.bin, args...)
+	cmd.Env = environ
+	stdout := &logWriter{t: r.t, prefix: "stdout: "}
+	stderr := &logWriter{t: r.t, prefix: "stderr: "}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not execute binary: %w", err)
+	}
+
+	s := &Server{
+		URL:  "http://127.0.0.1:" + strconv.Itoa(port),
+		Port: port,
+		t:    r.t,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	go func() {
+		s.err = cmd.Wait()
+		stdout.flush()
+		stderr.flush()
+		close(s.done)
+	}()
+	r.t.Cleanup(func() {
+		if err := s.Stop(); err != nil {
+			r.t.Logf("server exited: %v", err)
+		} else {
+			r.t.Logf("server exited: exit status 0")
+		}
+	})
+
+	if err := s.waitReady(readyPath, timeout); err != nil {
+		s.Stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+// waitReady polls readyPath until the server responds without a 5xx status.
+func (s *Server) waitReady(readyPath string, timeout time.Duration) error {
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := client.Get(s.URL + readyPath)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server not ready after %v: %w", timeout, err)
+		}
+		select {
+		case <-s.done:
+			return fmt.Errorf("server exited before becoming ready: %v", s.err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop sends SIGTERM to the server and waits for it to exit. The process is
+// killed if it is still running after 10 seconds. Stop returns the exit
+// status of the process; a server that exits with status 0 returns nil.
+// Calling Stop more than once returns the same result.
+func (s *Server) Stop() error {
+	s.stopOnce.Do(func() {
+		select {
+		case <-s.done:
+		default:
+			if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				s.t.Logf("could not send SIGTERM: %v", err)
+			}
+			select {
+			case <-s.done:
+			case <-time.After(shutdownTimeout):
+				s.t.Logf("server did not exit %v after SIGTERM, killing it", shutdownTimeout)
+				s.cmd.Process.Kill()
+				<-s.done
+			}
+		}
+		s.stopErr = s.err
+	})
+	return s.stopErr
+}
+
+// freePort asks the kernel for a local port that is not in use.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// logWriter writes each complete line it receives to the test log.
+type logWriter struct {
+	t      *testing.T
+	prefix string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.t.Log(w.prefix + string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush writes any trailing output that did not end in a newline.
+func (w *logWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.t.Log(w.prefix + string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunnerStart(t *testing.T) {
+	t.Chdir("testdata/server")
+	m := BuildMain(t)
+	defer m.Cleanup()
+	if !m.Built() {
+		t.Fatal("failed to build server")
+	}
+
+	s, err := m.Start(nil, "/", 30*time.Second)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get(s.URL + "/")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if got, want := string(body), "Hello from the test server!\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Errorf("Stop: %v, want clean exit", err)
+	}
+	if _, err := http.Get(s.URL + "/"); err == nil {
+		t.Errorf("server still serving after Stop")
+	}
+}
+
+func TestRunnerStartExitEarly(t *testing.T) {
+	t.Chdir("testdata/server")
+	m := BuildMain(t)
+	defer m.Cleanup()
+	if !m.Built() {
+		t.Fatal("failed to build server")
+	}
+
+	if _, err := m.Start(map[string]string{"EXIT_EARLY": "1"}, "/", 30*time.Second); err == nil {
+		t.Errorf("Start succeeded, want error for a server that exits early")
+	}
+}
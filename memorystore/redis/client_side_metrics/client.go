@@ -0,0 +1,225 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// [START memorystore_redis_client_side_metrics]
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the metrics a Client records.
+const (
+	ClientBlockingLatency      = "redis_client_blocking_latency"
+	RTT                        = "redis_client_rtt"
+	ApplicationBlockingLatency = "redis_application_blocking_latency"
+	RetryCount                 = "redis_retry_count"
+	ConnectivityErrorCount     = "redis_connectivity_error_count"
+)
+
+// instrumentationName names the tracer and meter of Clients.
+const instrumentationName = "github.com/GoogleCloudPlatform/golang-samples/memorystore/redis/client_side_metrics"
+
+// RetryPolicy decides how a Client retries failed attempts. Its zero
+// value retries twice, with exponential backoff from 200ms.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including
+	// the first one. It defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It defaults
+	// to 200ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. It defaults to 2s.
+	MaxBackoff time.Duration
+	// Multiplier multiplies the delay after each retry. It defaults to 2.
+	Multiplier float64
+	// Retryable reports whether to retry after an error of the class. It
+	// defaults to DefaultRetryable.
+	//
+	// A retried attempt sends all the commands of the call again, so only
+	// retry calls whose commands are safe to repeat.
+	Retryable func(ErrorClass) bool
+}
+
+// DefaultRetryable retries timeouts, connection errors and an exhausted
+// pool. It doesn't retry MOVED and ASK redirections, which a single pool
+// can't follow, or other error replies, which would fail again.
+func DefaultRetryable(c ErrorClass) bool {
+	switch c {
+	case ClassTimeout, ClassConnRefused, ClassConnection, ClassPoolExhausted:
+		return true
+	}
+	return false
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) retryable(c ErrorClass) bool {
+	if p.Retryable == nil {
+		return DefaultRetryable(c)
+	}
+	return p.Retryable(c)
+}
+
+// backoff returns the delay before retry n, from 1.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d, limit, mult := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if d <= 0 {
+		d = 200 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 2 * time.Second
+	}
+	if mult < 1 {
+		mult = 2
+	}
+	for i := 1; i < n && d < limit; i++ {
+		d = time.Duration(float64(d) * mult)
+	}
+	return min(d, limit)
+}
+
+// Options configure a Client.
+type Options struct {
+	// TracerProvider and MeterProvider default to the global providers.
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+
+	Retry RetryPolicy
+}
+
+// Client makes Redis calls with the connections of a pool, retrying
+// failed attempts and recording traces and metrics.
+//
+// Every call is traced, and records these metrics, with an operation
+// attribute naming the call:
+//
+//   - redis_client_blocking_latency: the time spent waiting for a
+//     connection from the pool, in milliseconds.
+//   - redis_client_rtt: the time from sending the commands of an attempt to
+//     receiving their replies, in milliseconds.
+//   - redis_application_blocking_latency: the time the application spent
+//     processing replies, in milliseconds, recorded by Client.Process.
+//   - redis_retry_count: the number of retried attempts, with an
+//     error.type attribute classifying the error that caused the retry.
+//   - redis_connectivity_error_count: the number of attempts that failed
+//     because Redis couldn't be reached, with an error.type attribute.
+//
+// Metrics are recorded with the call's context, which holds its span, so
+// a meter provider with exemplars enabled links the metric points to the
+// trace of the call that recorded them.
+type Client struct {
+	pool  *redis.Pool
+	retry RetryPolicy
+
+	tracer            trace.Tracer
+	clientBlocking    metric.Float64Histogram
+	rtt               metric.Float64Histogram
+	appBlocking       metric.Float64Histogram
+	retries           metric.Int64Counter
+	connectivityError metric.Int64Counter
+}
+
+// NewClient returns a Client making calls with the connections of pool.
+func NewClient(pool *redis.Pool, opts Options) (*Client, error) {
+	tp, mp := opts.TracerProvider, opts.MeterProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	c := &Client{pool: pool, retry: opts.Retry, tracer: tp.Tracer(instrumentationName)}
+
+	var err error
+	for _, h := range []struct {
+		name string
+		dst  *metric.Float64Histogram
+		desc string
+	}{
+		{ClientBlockingLatency, &c.clientBlocking, "Time spent waiting for a connection from the pool."},
+		{RTT, &c.rtt, "Round-trip time of Redis commands."},
+		{ApplicationBlockingLatency, &c.appBlocking, "Time spent processing Redis replies."},
+	} {
+		if *h.dst, err = meter.Float64Histogram(h.name, metric.WithUnit("ms"), metric.WithDescription(h.desc)); err != nil {
+			return nil, fmt.Errorf("%s histogram: %w", h.name, err)
+		}
+	}
+	if c.retries, err = meter.Int64Counter(RetryCount, metric.WithDescription("Retried Redis attempts.")); err != nil {
+		return nil, fmt.Errorf("%s counter: %w", RetryCount, err)
+	}
+	if c.connectivityError, err = meter.Int64Counter(ConnectivityErrorCount, metric.WithDescription("Redis attempts that couldn't reach Redis.")); err != nil {
+		return nil, fmt.Errorf("%s counter: %w", ConnectivityErrorCount, err)
+	}
+	return c, nil
+}
+
+// WithRetry returns a copy of c that retries failed attempts with p,
+// sharing c's pool, traces and metrics.
+func (c *Client) WithRetry(p RetryPolicy) *Client {
+	cc := *c
+	cc.retry = p
+	return &cc
+}
+
+// Cmd is a Redis command and its arguments.
+type Cmd struct {
+	Name string
+	Args []any
+}
+
+// do runs cmd on conn, honoring ctx's deadline. Redigo connections don't
+// take a context.
+func do(ctx context.Context, conn redis.Conn, cmd Cmd) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return redis.DoWithTimeout(conn, time.Until(deadline), cmd.Name, cmd.Args...)
+	}
+	return conn.Do(cmd.Name, cmd.Args...)
+}
+
+// receive receives a pipelined reply on conn, honoring ctx's deadline.
+func receive(ctx context.Context, conn redis.Conn) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return redis.ReceiveWithTimeout(conn, time.Until(deadline))
+	}
+	return conn.Receive()
+}
+
+func cmdNames(cmds []Cmd) string {
+	if len(cmds) == 1 {
+		return cmds[0].Name
+	}
+	return fmt.Sprintf("pipeline(%d)", len(cmds))
+}
+
+// sinceMs returns the time elapsed since start in fractional
+// milliseconds, to avoid truncating sub-millisecond durations.
+func sinceMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000.0
+}
+
+// [END memorystore_redis_client_side_metrics]
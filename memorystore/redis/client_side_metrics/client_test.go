@@ -0,0 +1,377 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testClient is a Client whose metrics and spans are kept in memory.
+type testClient struct {
+	*Client
+	reader *sdkmetric.ManualReader
+	spans  *tracetest.SpanRecorder
+}
+
+func newTestClient(t *testing.T, dial func() (redis.Conn, error), retry RetryPolicy) *testClient {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter))
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	pool := &redis.Pool{MaxIdle: 1, Dial: dial}
+	t.Cleanup(func() { pool.Close() })
+
+	c, err := NewClient(pool, Options{TracerProvider: tp, MeterProvider: mp, Retry: retry})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return &testClient{c, reader, spans}
+}
+
+// metrics collects the metrics recorded so far, by name.
+func (c *testClient) metrics(t *testing.T) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := c.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	m := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			m[metric.Name] = metric.Data
+		}
+	}
+	return m
+}
+
+// count returns the value of counter name for attrs, as "k=v" strings.
+func count(m map[string]metricdata.Aggregation, name string, attrs ...string) int64 {
+	sum, _ := m[name].(metricdata.Sum[int64])
+	for _, dp := range sum.DataPoints {
+		if hasAttrs(dp.Attributes, attrs) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+// histogram returns the data point of histogram name for attrs.
+func histogram(m map[string]metricdata.Aggregation, name string, attrs ...string) metricdata.HistogramDataPoint[float64] {
+	h, _ := m[name].(metricdata.Histogram[float64])
+	for _, dp := range h.DataPoints {
+		if hasAttrs(dp.Attributes, attrs) {
+			return dp
+		}
+	}
+	return metricdata.HistogramDataPoint[float64]{}
+}
+
+func hasAttrs(set attribute.Set, attrs []string) bool {
+	if set.Len() != len(attrs) {
+		return false
+	}
+	for _, a := range attrs {
+		k, v, _ := strings.Cut(a, "=")
+		if got, ok := set.Value(attribute.Key(k)); !ok || got.Emit() != v {
+			return false
+		}
+	}
+	return true
+}
+
+func dialMiniredis(t *testing.T) func() (redis.Conn, error) {
+	s := miniredis.RunT(t)
+	return func() (redis.Conn, error) { return redis.Dial("tcp", s.Addr()) }
+}
+
+var errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+// fastRetry is a retry policy that doesn't slow tests down.
+var fastRetry = RetryPolicy{InitialBackoff: time.Microsecond}
+
+func TestDoAndPipeline(t *testing.T) {
+	c := newTestClient(t, dialMiniredis(t), fastRetry)
+	ctx := context.Background()
+
+	if _, err := c.Do(ctx, "set_user", "SET", "user:123", "active"); err != nil {
+		t.Fatalf("Do(SET): %v", err)
+	}
+	got, err := redis.String(c.Do(ctx, "get_user", "GET", "user:123"))
+	if err != nil || got != "active" {
+		t.Fatalf("Do(GET) = %q, %v; want active", got, err)
+	}
+	if err := c.Process(ctx, "get_user", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	replies, err := c.Pipeline(ctx, "update_user",
+		Cmd{"INCR", []any{"visits"}},
+		Cmd{"INCR", []any{"user:123"}},
+		Cmd{"GET", []any{"visits"}},
+	)
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if len(replies) != 3 || replies[0] != int64(1) || string(replies[2].([]byte)) != "1" {
+		t.Errorf("Pipeline replies = %v, want [1 <error> 1]", replies)
+	}
+	if _, ok := replies[1].(redis.Error); !ok {
+		t.Errorf("reply to INCR of a string = %v, want a redis.Error", replies[1])
+	}
+
+	m := c.metrics(t)
+	for _, op := range []string{"set_user", "get_user", "update_user"} {
+		for _, name := range []string{ClientBlockingLatency, RTT} {
+			if got := histogram(m, name, "operation="+op).Count; got != 1 {
+				t.Errorf("%s{operation=%s} count = %d, want 1", name, op, got)
+			}
+		}
+	}
+	if got := histogram(m, ApplicationBlockingLatency, "operation=get_user").Count; got != 1 {
+		t.Errorf("%s count = %d, want 1", ApplicationBlockingLatency, got)
+	}
+	if _, ok := m[RetryCount]; ok {
+		t.Errorf("%s recorded without retries", RetryCount)
+	}
+
+	// Exemplars link each call's RTT to its span.
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range c.spans.Ended() {
+		spans[s.Name()] = s
+	}
+	for _, op := range []string{"set_user", "get_user", "update_user"} {
+		span, ok := spans[op]
+		if !ok {
+			t.Errorf("no span for %s", op)
+			continue
+		}
+		ex := histogram(m, RTT, "operation="+op).Exemplars
+		if len(ex) != 1 {
+			t.Errorf("%s{operation=%s} has %d exemplars, want 1", RTT, op, len(ex))
+			continue
+		}
+		if trace.TraceID(ex[0].TraceID) != span.SpanContext().TraceID() {
+			t.Errorf("%s exemplar trace = %x, want %s", op, ex[0].TraceID, span.SpanContext().TraceID())
+		}
+		if trace.SpanID(ex[0].SpanID) != span.SpanContext().SpanID() {
+			t.Errorf("%s exemplar span = %x, want %s", op, ex[0].SpanID, span.SpanContext().SpanID())
+		}
+	}
+	if got := spans["update_user"].Attributes(); !hasAttr(got, "db.operation", "pipeline(3)") {
+		t.Errorf("pipeline span attributes = %v, want db.operation=pipeline(3)", got)
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, k, v string) bool {
+	for _, a := range attrs {
+		if string(a.Key) == k && a.Value.Emit() == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetry(t *testing.T) {
+	dial := dialMiniredis(t)
+	refusals := 1
+	c := newTestClient(t, func() (redis.Conn, error) {
+		if refusals > 0 {
+			refusals--
+			return nil, errRefused
+		}
+		return dial()
+	}, fastRetry)
+
+	if _, err := c.Do(context.Background(), "set_user", "SET", "user:123", "active"); err != nil {
+		t.Fatalf("Do after a refused connection: %v", err)
+	}
+	m := c.metrics(t)
+	attrs := []string{"operation=set_user", "error.type=connection_refused"}
+	if got := count(m, RetryCount, attrs...); got != 1 {
+		t.Errorf("%s%v = %d, want 1", RetryCount, attrs, got)
+	}
+	if got := count(m, ConnectivityErrorCount, attrs...); got != 1 {
+		t.Errorf("%s%v = %d, want 1", ConnectivityErrorCount, attrs, got)
+	}
+	if got := histogram(m, ClientBlockingLatency, "operation=set_user").Count; got != 2 {
+		t.Errorf("%s count = %d, want 2, one per attempt", ClientBlockingLatency, got)
+	}
+	span := c.spans.Ended()[0]
+	if !hasAttr(span.Attributes(), "redis.attempts", "2") || len(span.Events()) != 1 {
+		t.Errorf("span has attributes %v and %d events, want redis.attempts=2 and 1 error event", span.Attributes(), len(span.Events()))
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	c := newTestClient(t, func() (redis.Conn, error) { return nil, errRefused }, RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Microsecond})
+
+	_, err := c.Do(context.Background(), "get_user", "GET", "user:123")
+	if err == nil || !strings.Contains(err.Error(), "get_user failed after 4 attempts") || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("Do with Redis down = %v, want failure after 4 attempts wrapping ECONNREFUSED", err)
+	}
+	m := c.metrics(t)
+	attrs := []string{"operation=get_user", "error.type=connection_refused"}
+	if got := count(m, RetryCount, attrs...); got != 3 {
+		t.Errorf("%s = %d, want 3", RetryCount, got)
+	}
+	if got := count(m, ConnectivityErrorCount, attrs...); got != 4 {
+		t.Errorf("%s = %d, want 4", ConnectivityErrorCount, got)
+	}
+	if got := histogram(m, RTT, "operation=get_user").Count; got != 0 {
+		t.Errorf("%s count = %d, want 0: no command was sent", RTT, got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	dials := 0
+	c := newTestClient(t, func() (redis.Conn, error) { dials++; return nil, errRefused }, fastRetry)
+
+	once := c.WithRetry(RetryPolicy{MaxAttempts: 1})
+	if _, err := once.Pipeline(context.Background(), "record_visit", Cmd{Name: "INCR", Args: []any{"visits"}}); err == nil {
+		t.Fatal("Pipeline with Redis down succeeded, want error")
+	}
+	if dials != 1 {
+		t.Errorf("WithRetry(MaxAttempts: 1) made %d attempts, want 1", dials)
+	}
+	if got := count(c.metrics(t), RetryCount, "operation=record_visit", "error.type=connection_refused"); got != 0 {
+		t.Errorf("%s = %d, want 0", RetryCount, got)
+	}
+
+	// The original client keeps its policy.
+	dials = 0
+	c.Do(context.Background(), "get_user", "GET", "user:123")
+	if dials != 3 {
+		t.Errorf("original client made %d attempts, want 3", dials)
+	}
+}
+
+// fakeConn is a redis.Conn replying to every command with err, and
+// counting them in calls.
+type fakeConn struct {
+	redis.Conn
+	err   error
+	calls *int
+}
+
+func (c fakeConn) Do(cmd string, args ...any) (any, error) {
+	// The pool sends an empty command when it takes back a connection.
+	if cmd == "" {
+		return nil, nil
+	}
+	*c.calls++
+	return nil, c.err
+}
+func (c fakeConn) Err() error   { return nil }
+func (c fakeConn) Close() error { return nil }
+
+func TestNoRetry(t *testing.T) {
+	for _, err := range []error{
+		redis.Error("MOVED 3999 10.0.0.4:6379"),
+		redis.Error("ASK 3999 10.0.0.4:6379"),
+		redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+	} {
+		attempts := 0
+		c := newTestClient(t, func() (redis.Conn, error) {
+			return fakeConn{err: err, calls: &attempts}, nil
+		}, fastRetry)
+
+		_, got := c.Do(context.Background(), "get_user", "GET", "user:123")
+		if !errors.Is(got, err) || attempts != 1 {
+			t.Errorf("Do replying %q = %v after %d attempts, want the error after 1 attempt", err, got, attempts)
+		}
+		if m := c.metrics(t); count(m, RetryCount, "operation=get_user", "error.type="+Classify(err).String()) != 0 {
+			t.Errorf("%s recorded for %q", RetryCount, err)
+		}
+	}
+
+	// A custom policy can retry them.
+	attempts := 0
+	c := newTestClient(t, func() (redis.Conn, error) {
+		return fakeConn{err: redis.Error("ASK 3999 10.0.0.4:6379"), calls: &attempts}, nil
+	}, RetryPolicy{InitialBackoff: time.Microsecond, Retryable: func(c ErrorClass) bool { return c == ClassAsk }})
+	c.Do(context.Background(), "get_user", "GET", "user:123")
+	if attempts != 3 {
+		t.Errorf("custom policy retrying ASK made %d attempts, want 3", attempts)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	c := newTestClient(t, func() (redis.Conn, error) { return nil, errRefused }, RetryPolicy{InitialBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Do(ctx, "get_user", "GET", "user:123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do canceled while backing off = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ClassNone},
+		{context.DeadlineExceeded, ClassTimeout},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, ClassTimeout},
+		{errRefused, ClassConnRefused},
+		{fmt.Errorf("dial: %w", errRefused), ClassConnRefused},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ClassConnection},
+		{io.EOF, ClassConnection},
+		{redis.ErrPoolExhausted, ClassPoolExhausted},
+		{redis.Error("MOVED 3999 10.0.0.4:6379"), ClassMoved},
+		{redis.Error("ASK 3999 10.0.0.4:6379"), ClassAsk},
+		{redis.Error("ERR unknown command"), ClassServer},
+		{errors.New("boom"), ClassOther},
+	}
+	for _, test := range tests {
+		if got := Classify(test.err); got != test.want {
+			t.Errorf("Classify(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var zero RetryPolicy
+	for n, want := range map[int]time.Duration{1: 200 * time.Millisecond, 2: 400 * time.Millisecond, 4: 1600 * time.Millisecond, 5: 2 * time.Second, 20: 2 * time.Second} {
+		if got := zero.backoff(n); got != want {
+			t.Errorf("default backoff(%d) = %v, want %v", n, got, want)
+		}
+	}
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 3}
+	for n, want := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 30 * time.Millisecond, 3: 50 * time.Millisecond} {
+		if got := p.backoff(n); got != want {
+			t.Errorf("%+v backoff(%d) = %v, want %v", p, n, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// [START memorystore_redis_client_side_metrics]
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrorClass classifies the errors of Redis calls, to decide whether to
+// retry them and to label metrics.
+type ErrorClass int
+
+const (
+	// ClassNone is the class of a nil error.
+	ClassNone ErrorClass = iota
+	// ClassTimeout is a deadline exceeded while dialing, or waiting for
+	// a reply.
+	ClassTimeout
+	// ClassConnRefused is a refused connection, usually because the
+	// instance is down or failing over.
+	ClassConnRefused
+	// ClassConnection is any other network error, such as a connection
+	// reset or closed by the server.
+	ClassConnection
+	// ClassPoolExhausted means the pool has no connection to spare.
+	ClassPoolExhausted
+	// ClassMoved is a MOVED redirection: the key's hash slot is served by
+	// another node of the cluster.
+	ClassMoved
+	// ClassAsk is an ASK redirection: the key's hash slot is being
+	// migrated to another node.
+	ClassAsk
+	// ClassServer is any other error reply from Redis, such as WRONGTYPE.
+	ClassServer
+	// ClassOther is an error of none of the classes above.
+	ClassOther
+)
+
+var classNames = [...]string{
+	ClassNone:          "none",
+	ClassTimeout:       "timeout",
+	ClassConnRefused:   "connection_refused",
+	ClassConnection:    "connection",
+	ClassPoolExhausted: "pool_exhausted",
+	ClassMoved:         "moved",
+	ClassAsk:           "ask",
+	ClassServer:        "server",
+	ClassOther:         "other",
+}
+
+// String returns the class's name, used as the error.type metric
+// attribute.
+func (c ErrorClass) String() string {
+	if c < 0 || int(c) >= len(classNames) {
+		return "unknown"
+	}
+	return classNames[c]
+}
+
+// Connectivity reports whether errors of class c mean the client couldn't
+// reach Redis. They count towards the redis_connectivity_error_count
+// metric.
+func (c ErrorClass) Connectivity() bool {
+	return c == ClassConnRefused || c == ClassConnection
+}
+
+// Classify returns the class of err.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		switch {
+		case strings.HasPrefix(string(redisErr), "MOVED "):
+			return ClassMoved
+		case strings.HasPrefix(string(redisErr), "ASK "):
+			return ClassAsk
+		}
+		return ClassServer
+	}
+	if errors.Is(err, redis.ErrPoolExhausted) {
+		return ClassPoolExhausted
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ClassTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ClassConnRefused
+	}
+	if netErr != nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ClassConnection
+	}
+	return ClassOther
+}
+
+// [END memorystore_redis_client_side_metrics]
@@ -17,6 +17,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -32,173 +33,194 @@ import (
 	gcpmetric "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/metric"
 	gcptrace "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// MetricClient encapsulates the tracer and metric histograms to avoid package-level globals.
-type MetricClient struct {
-	tracer           trace.Tracer
-	rttHist          metric.Float64Histogram
-	clientBlockHist  metric.Float64Histogram
-	appBlockHist     metric.Float64Histogram
-	retryCounter     metric.Int64Counter
-	connErrorCounter metric.Int64Counter
-}
-
-// sleep hook enables lightning-fast unit tests by stubbing out real time.Sleep
-var sleep = time.Sleep
-
-// sinceMs calculates elapsed time in fractional milliseconds to avoid truncating sub-millisecond durations.
-func sinceMs(start time.Time) float64 {
-	return float64(time.Since(start).Microseconds()) / 1000.0
-}
-
-func initTelemetry(ctx context.Context) (*MetricClient, func(), error) {
+// initTelemetry sets up the global tracer and meter providers, which
+// export to Cloud Trace and Cloud Monitoring.
+func initTelemetry(ctx context.Context) (func(), error) {
 	traceExporter, err := gcptrace.New()
 	if err != nil {
-		return nil, nil, fmt.Errorf("gcptrace.New: %w", err)
+		return nil, fmt.Errorf("gcptrace.New: %w", err)
 	}
 	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter))
 	otel.SetTracerProvider(tp)
-	tracer := tp.Tracer("redigo.client")
 
 	metricExporter, err := gcpmetric.New()
 	if err != nil {
-		return nil, nil, fmt.Errorf("gcpmetric.New: %w", err)
+		return nil, fmt.Errorf("gcpmetric.New: %w", err)
 	}
-	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(10*time.Second))))
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(10*time.Second))),
+		// Attach exemplars to the metrics recorded in sampled spans, so
+		// Cloud Monitoring links latency outliers to their traces.
+		sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+	)
 	otel.SetMeterProvider(mp)
-	meter := mp.Meter("redigo.metrics")
-
-	rttHist, err := meter.Float64Histogram("redis_client_rtt", metric.WithUnit("ms"))
-	if err != nil {
-		return nil, nil, fmt.Errorf("redis_client_rtt histogram: %w", err)
-	}
-	clientBlockHist, err := meter.Float64Histogram("redis_client_blocking_latency", metric.WithUnit("ms"))
-	if err != nil {
-		return nil, nil, fmt.Errorf("redis_client_blocking_latency histogram: %w", err)
-	}
-	appBlockHist, err := meter.Float64Histogram("redis_application_blocking_latency", metric.WithUnit("ms"))
-	if err != nil {
-		return nil, nil, fmt.Errorf("redis_application_blocking_latency histogram: %w", err)
-	}
-	retryCounter, err := meter.Int64Counter("redis_retry_count")
-	if err != nil {
-		return nil, nil, fmt.Errorf("redis_retry_count counter: %w", err)
-	}
-	connErrorCounter, err := meter.Int64Counter("redis_connectivity_error_count")
-	if err != nil {
-		return nil, nil, fmt.Errorf("redis_connectivity_error_count counter: %w", err)
-	}
-
-	client := &MetricClient{
-		tracer:           tracer,
-		rttHist:          rttHist,
-		clientBlockHist:  clientBlockHist,
-		appBlockHist:     appBlockHist,
-		retryCounter:     retryCounter,
-		connErrorCounter: connErrorCounter,
-	}
-
-	initAttrs := metric.WithAttributes(attribute.String("operation", "startup"))
-	client.retryCounter.Add(ctx, 0, initAttrs)
-	client.connErrorCounter.Add(ctx, 0, initAttrs)
 
 	shutdown := func() {
 		tp.Shutdown(ctx)
 		mp.Shutdown(ctx)
 	}
-
-	return client, shutdown, nil
+	return shutdown, nil
 }
 
-func (c *MetricClient) smartRedisCall(ctx context.Context, pool *redis.Pool, operationName string, commandName string, args ...interface{}) (interface{}, error) {
-	// Create a dedicated child span for the Redis command
-	ctx, span := c.tracer.Start(ctx, operationName)
-	span.SetAttributes(attribute.String("redis.command", commandName))
+// call runs the attempts of a call, running cmds with send.
+func (c *Client) call(ctx context.Context, op string, cmds []Cmd, send func(redis.Conn, []Cmd) ([]any, error)) ([]any, error) {
+	ctx, span := c.tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient))
 	defer span.End()
+	span.SetAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", cmdNames(cmds)))
+	opAttr := attribute.String("operation", op)
+	opAttrs := metric.WithAttributes(opAttr)
 
-	maxRetries := 3
-	attempt := 0
-	metricOpts := metric.WithAttributes(attribute.String("operation", operationName))
-	var lastErr error
-
-	for attempt < maxRetries {
-		poolStart := time.Now()
-		// Use GetContext to respect context deadlines and cancellation
-		conn, err := pool.GetContext(ctx)
-		c.clientBlockHist.Record(ctx, sinceMs(poolStart), metricOpts)
-
-		if err != nil {
-			c.connErrorCounter.Add(ctx, 1, metricOpts)
-			c.retryCounter.Add(ctx, 1, metricOpts)
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			lastErr = err
-			attempt++
-			if attempt >= maxRetries {
-				break
-			}
-			sleep(time.Duration(100<<attempt) * time.Millisecond)
-			continue
+	maxAttempts := c.retry.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		replies, err := c.attempt(ctx, opAttrs, cmds, send)
+		class := Classify(err)
+		if err == nil {
+			span.SetAttributes(attribute.Int("redis.attempts", attempt))
+			return replies, nil
 		}
 
-		// Check if the connection is dead
-		if err := conn.Err(); err != nil {
-			conn.Close()
-			c.connErrorCounter.Add(ctx, 1, metricOpts)
-			c.retryCounter.Add(ctx, 1, metricOpts)
-			span.RecordError(err)
+		errAttrs := metric.WithAttributes(opAttr, attribute.String("error.type", class.String()))
+		span.RecordError(err, trace.WithAttributes(attribute.String("error.type", class.String()), attribute.Int("redis.attempt", attempt)))
+		if class.Connectivity() {
+			c.connectivityError.Add(ctx, 1, errAttrs)
+		}
+		if attempt >= maxAttempts || !c.retry.retryable(class) {
+			span.SetAttributes(attribute.Int("redis.attempts", attempt))
 			span.SetStatus(codes.Error, err.Error())
-			lastErr = err
-			attempt++
-			if attempt >= maxRetries {
-				break
+			if attempt > 1 {
+				return nil, fmt.Errorf("%s failed after %d attempts: %w", op, attempt, err)
 			}
-			sleep(time.Duration(100<<attempt) * time.Millisecond)
-			continue
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		reqStart := time.Now()
-		// Redigo has no native DoContext; pass timeouts using redis.DoWithTimeout when context has a deadline
-		var reply interface{}
-		if deadline, ok := ctx.Deadline(); ok {
-			reply, err = redis.DoWithTimeout(conn, time.Until(deadline), commandName, args...)
-		} else {
-			reply, err = conn.Do(commandName, args...)
+		c.retries.Add(ctx, 1, errAttrs)
+		t := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			span.SetStatus(codes.Error, ctx.Err().Error())
+			return nil, fmt.Errorf("%s: %w (last error: %v)", op, ctx.Err(), err)
+		case <-t.C:
 		}
-		c.rttHist.Record(ctx, sinceMs(reqStart), metricOpts)
-		conn.Close()
+	}
+}
 
-		if err != nil {
-			c.retryCounter.Add(ctx, 1, metricOpts)
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			lastErr = err
-			attempt++
-			if attempt >= maxRetries {
-				break
+// attempt gets a connection and runs cmds on it once.
+func (c *Client) attempt(ctx context.Context, opAttrs metric.MeasurementOption, cmds []Cmd, send func(redis.Conn, []Cmd) ([]any, error)) ([]any, error) {
+	start := time.Now()
+	conn, err := c.pool.GetContext(ctx)
+	c.clientBlocking.Record(ctx, sinceMs(start), opAttrs)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// The pool returns a connection that failed to dial as a connection
+	// whose Err is set.
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	replies, err := send(conn, cmds)
+	c.rtt.Record(ctx, sinceMs(start), opAttrs)
+	return replies, err
+}
+
+// Do runs a command, named op in traces and metrics, and returns its
+// reply.
+func (c *Client) Do(ctx context.Context, op, name string, args ...any) (any, error) {
+	replies, err := c.call(ctx, op, []Cmd{{name, args}}, func(conn redis.Conn, cmds []Cmd) ([]any, error) {
+		reply, err := do(ctx, conn, cmds[0])
+		return []any{reply}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends cmds in a single round trip, named op in traces and
+// metrics, and returns their replies.
+//
+// An error reply to one of the commands doesn't fail the call: it's
+// returned as the command's reply, a redis.Error. Other errors fail the
+// attempt, which is retried as a whole.
+func (c *Client) Pipeline(ctx context.Context, op string, cmds ...Cmd) ([]any, error) {
+	return c.call(ctx, op, cmds, func(conn redis.Conn, cmds []Cmd) ([]any, error) {
+		for _, cmd := range cmds {
+			if err := conn.Send(cmd.Name, cmd.Args...); err != nil {
+				return nil, err
 			}
-			sleep(time.Duration(100<<attempt) * time.Millisecond)
-			continue
 		}
+		if err := conn.Flush(); err != nil {
+			return nil, err
+		}
+		replies := make([]any, len(cmds))
+		for i := range cmds {
+			reply, err := receive(ctx, conn)
+			var redisErr redis.Error
+			if errors.As(err, &redisErr) {
+				reply, err = redisErr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			replies[i] = reply
+		}
+		return replies, nil
+	})
+}
 
-		appStart := time.Now()
-		// Replace fmt.Sprintf to remove unnecessary string formatting overhead
-		sleep(2 * time.Millisecond)
-		c.appBlockHist.Record(ctx, sinceMs(appStart), metricOpts)
+// Process runs fn, the application's processing of the replies of op, and
+// records its duration as application blocking latency.
+func (c *Client) Process(ctx context.Context, op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	c.appBlocking.Record(ctx, sinceMs(start), metric.WithAttributes(attribute.String("operation", op)))
+	return err
+}
 
-		// Reset span status to Ok if the retry or execution eventually succeeds
-		span.SetStatus(codes.Ok, "")
+// fetchUser writes and reads a user's status, and records the visit.
+func fetchUser(ctx context.Context, client *Client, user string) (status string, visits int64, err error) {
+	key := "user:" + user
+	if _, err := client.Do(ctx, "set_user", "SET", key, "active"); err != nil {
+		return "", 0, err
+	}
+	status, err = redis.String(client.Do(ctx, "get_user", "GET", key))
+	if err != nil {
+		return "", 0, err
+	}
 
-		return reply, nil
+	// Pipelined commands share a round trip. A retry would resend INCR,
+	// which may already have run if the attempt timed out, and count the
+	// visit twice, so the pipeline isn't retried.
+	replies, err := client.WithRetry(RetryPolicy{MaxAttempts: 1}).Pipeline(ctx, "record_visit",
+		Cmd{Name: "INCR", Args: []any{"visits:" + key}},
+		Cmd{Name: "EXPIRE", Args: []any{"visits:" + key, 3600}},
+	)
+	if err != nil {
+		return "", 0, err
 	}
-	return nil, fmt.Errorf("max retries reached for %s: %w", operationName, lastErr)
+	if visits, err = redis.Int64(replies[0], nil); err != nil {
+		return "", 0, err
+	}
+
+	// Record the time spent using the replies as application blocking
+	// latency.
+	err = client.Process(ctx, "get_user", func() error {
+		log.Printf("Retrieved value: %s, visit number %d", status, visits)
+		return nil
+	})
+	return status, visits, err
 }
 
 func main() {
 	ctx := context.Background()
-	client, shutdown, err := initTelemetry(ctx)
+	shutdown, err := initTelemetry(ctx)
 	if err != nil {
 		log.Printf("Failed to initialize telemetry: %v", err)
 		os.Exit(1)
@@ -222,19 +244,22 @@ func main() {
 	}
 	defer pool.Close()
 
-	ctx, span := client.tracer.Start(ctx, "fetch_data_span")
-	defer span.End()
-
-	// Simple write and read operations
-	_, err = client.smartRedisCall(ctx, pool, "set_user", "SET", "user:123", "active")
+	client, err := NewClient(pool, Options{
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 200 * time.Millisecond,
+		},
+	})
 	if err != nil {
-		log.Printf("Error setting data: %v", err)
+		log.Printf("Failed to create Redis client: %v", err)
+		os.Exit(1)
 	}
-	val, err := client.smartRedisCall(ctx, pool, "get_user", "GET", "user:123")
-	if err != nil {
+
+	ctx, span := otel.Tracer("client_side_metrics").Start(ctx, "fetch_data_span")
+	defer span.End()
+
+	if _, _, err := fetchUser(ctx, client, "123"); err != nil {
 		log.Printf("Error fetching data: %v", err)
-	} else {
-		log.Printf("Retrieved value: %s", val)
 	}
 }
 
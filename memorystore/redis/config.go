@@ -0,0 +1,206 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// Client libraries the sample can use, selected with REDIS_CLIENT.
+const (
+	clientRedigo  = "redigo"
+	clientGoRedis = "go-redis"
+)
+
+// config is how the sample connects to Redis. It's read from the
+// environment by configFromEnv:
+//
+//   - REDISHOST, REDISPORT: the address of the instance, or of the
+//     discovery endpoint of a cluster. REDISPORT defaults to 6379.
+//   - REDIS_CLIENT: the client library, redigo (the default) or go-redis.
+//   - REDIS_CLUSTER: set to true to connect to a Redis Cluster. Cluster
+//     mode uses go-redis, which routes each command to the node serving
+//     its key's hash slot.
+//   - REDIS_TLS_CA_FILE: the instance's server CA certificates, in PEM
+//     format. Setting it enables in-transit encryption.
+//   - REDIS_AUTH_SECRET: a Secret Manager secret version holding the AUTH
+//     string, such as projects/PROJECT/secrets/SECRET/versions/latest.
+//   - REDIS_POOL_SIZE, REDIS_MAX_IDLE: the maximum number of connections,
+//     and of idle connections, per node. They default to 10.
+//   - REDIS_DIAL_TIMEOUT, REDIS_READ_TIMEOUT, REDIS_WRITE_TIMEOUT,
+//     REDIS_IDLE_TIMEOUT: durations, such as 500ms. They default to 5s, 3s,
+//     3s and 5m.
+type config struct {
+	Addr     string
+	Client   string
+	Cluster  bool
+	TLS      *tls.Config
+	Password string
+
+	PoolSize     int
+	MaxIdle      int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// hasOptions reports whether any REDIS_* environment variable is set, in
+// which case main connects as configFromEnv describes.
+func hasOptions() bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "REDIS_") {
+			return true
+		}
+	}
+	return false
+}
+
+// [START memorystore_auth]
+
+// accessSecret returns the payload of a Secret Manager secret version.
+// Tests replace it.
+var accessSecret = func(ctx context.Context, name string) (string, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("secretmanager.NewClient: %w", err)
+	}
+	defer client.Close()
+
+	result, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", fmt.Errorf("AccessSecretVersion: %w", err)
+	}
+	return string(result.Payload.Data), nil
+}
+
+// [END memorystore_auth]
+
+// configFromEnv returns the configuration described by the environment.
+func configFromEnv(ctx context.Context) (*config, error) {
+	host := os.Getenv("REDISHOST")
+	if host == "" {
+		return nil, fmt.Errorf("REDISHOST must be set")
+	}
+	port := os.Getenv("REDISPORT")
+	if port == "" {
+		port = "6379"
+	}
+	cfg := &config{
+		Addr:   net.JoinHostPort(host, port),
+		Client: os.Getenv("REDIS_CLIENT"),
+	}
+
+	var err error
+	if s := os.Getenv("REDIS_CLUSTER"); s != "" {
+		if cfg.Cluster, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("REDIS_CLUSTER: %w", err)
+		}
+	}
+	switch cfg.Client {
+	case "":
+		cfg.Client = clientRedigo
+		if cfg.Cluster {
+			cfg.Client = clientGoRedis
+		}
+	case clientGoRedis:
+	case clientRedigo:
+		if cfg.Cluster {
+			return nil, fmt.Errorf("REDIS_CLUSTER requires REDIS_CLIENT=%s", clientGoRedis)
+		}
+	default:
+		return nil, fmt.Errorf("REDIS_CLIENT: unknown client %q, want %s or %s", cfg.Client, clientRedigo, clientGoRedis)
+	}
+
+	for _, v := range []struct {
+		name string
+		dst  *int
+		def  int
+	}{
+		{"REDIS_POOL_SIZE", &cfg.PoolSize, 10},
+		{"REDIS_MAX_IDLE", &cfg.MaxIdle, 10},
+	} {
+		*v.dst = v.def
+		if s := os.Getenv(v.name); s != "" {
+			if *v.dst, err = strconv.Atoi(s); err != nil || *v.dst < 1 {
+				return nil, fmt.Errorf("%s: invalid size %q", v.name, s)
+			}
+		}
+	}
+	for _, v := range []struct {
+		name string
+		dst  *time.Duration
+		def  time.Duration
+	}{
+		{"REDIS_DIAL_TIMEOUT", &cfg.DialTimeout, 5 * time.Second},
+		{"REDIS_READ_TIMEOUT", &cfg.ReadTimeout, 3 * time.Second},
+		{"REDIS_WRITE_TIMEOUT", &cfg.WriteTimeout, 3 * time.Second},
+		{"REDIS_IDLE_TIMEOUT", &cfg.IdleTimeout, 5 * time.Minute},
+	} {
+		*v.dst = v.def
+		if s := os.Getenv(v.name); s != "" {
+			if *v.dst, err = time.ParseDuration(s); err != nil || *v.dst <= 0 {
+				return nil, fmt.Errorf("%s: invalid duration %q", v.name, s)
+			}
+		}
+	}
+
+	if name := os.Getenv("REDIS_TLS_CA_FILE"); name != "" {
+		if cfg.TLS, err = loadServerCA(name); err != nil {
+			return nil, fmt.Errorf("REDIS_TLS_CA_FILE: %w", err)
+		}
+	}
+
+	if name := os.Getenv("REDIS_AUTH_SECRET"); name != "" {
+		password, err := accessSecret(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("REDIS_AUTH_SECRET: %w", err)
+		}
+		cfg.Password = strings.TrimSpace(password)
+	}
+	return cfg, nil
+}
+
+// [START memorystore_tls]
+
+// loadServerCA returns a TLS configuration trusting the server CA
+// certificates in the PEM file name, for in-transit encryption.
+func loadServerCA(name string) (*tls.Config, error) {
+	pem, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", name)
+	}
+	// Both clients verify the server's certificate against the address
+	// they dial, since ServerName isn't set. In cluster mode, that's the
+	// address of each node.
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// [END memorystore_tls]
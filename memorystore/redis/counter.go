@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newCounter connects to Redis as cfg describes.
+func newCounter(cfg *config) (counter, error) {
+	opts := &goredis.Options{
+		Addr:            cfg.Addr,
+		Password:        cfg.Password,
+		TLSConfig:       cfg.TLS,
+		PoolSize:        cfg.PoolSize,
+		MaxIdleConns:    cfg.MaxIdle,
+		DialTimeout:     cfg.DialTimeout,
+		ReadTimeout:     cfg.ReadTimeout,
+		WriteTimeout:    cfg.WriteTimeout,
+		ConnMaxIdleTime: cfg.IdleTimeout,
+	}
+	switch {
+	case cfg.Client == clientRedigo:
+		return newRedigoCounter(cfg), nil
+	case cfg.Cluster:
+		return &goRedisCounter{newClusterClient(opts)}, nil
+	case cfg.Client == clientGoRedis:
+		return &goRedisCounter{goredis.NewClient(opts)}, nil
+	}
+	return nil, fmt.Errorf("unknown client %q", cfg.Client)
+}
+
+// newRedigoCounter returns a redigo counter configured by cfg.
+func newRedigoCounter(cfg *config) *redigoCounter {
+	opts := []redis.DialOption{
+		redis.DialConnectTimeout(cfg.DialTimeout),
+		redis.DialReadTimeout(cfg.ReadTimeout),
+		redis.DialWriteTimeout(cfg.WriteTimeout),
+	}
+	if cfg.TLS != nil {
+		opts = append(opts, redis.DialUseTLS(true), redis.DialTLSConfig(cfg.TLS))
+	}
+	if cfg.Password != "" {
+		opts = append(opts, redis.DialPassword(cfg.Password))
+	}
+	return &redigoCounter{&redis.Pool{
+		MaxIdle:     cfg.MaxIdle,
+		MaxActive:   cfg.PoolSize,
+		IdleTimeout: cfg.IdleTimeout,
+		// Wait for a connection when the pool is exhausted, rather than
+		// failing the request.
+		Wait: true,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", cfg.Addr, opts...)
+		},
+	}}
+}
+
+// [START memorystore_cluster]
+
+// newClusterClient returns a go-redis client for the Redis Cluster whose
+// discovery endpoint is opts.Addr. The client discovers the cluster's nodes
+// and routes each command to the node serving its key's hash slot. The rest
+// of opts configures the connections to each node.
+func newClusterClient(opts *goredis.Options) *goredis.ClusterClient {
+	return goredis.NewClusterClient(&goredis.ClusterOptions{
+		Addrs:           []string{opts.Addr},
+		Password:        opts.Password,
+		TLSConfig:       opts.TLSConfig,
+		PoolSize:        opts.PoolSize,
+		MaxIdleConns:    opts.MaxIdleConns,
+		DialTimeout:     opts.DialTimeout,
+		ReadTimeout:     opts.ReadTimeout,
+		WriteTimeout:    opts.WriteTimeout,
+		ConnMaxIdleTime: opts.ConnMaxIdleTime,
+	})
+}
+
+// [END memorystore_cluster]
+
+// goRedisCounter is a counter using a go-redis client, or cluster client.
+type goRedisCounter struct {
+	client goredis.UniversalClient
+}
+
+func (c *goRedisCounter) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+func (c *goRedisCounter) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *goRedisCounter) Close() error {
+	return c.client.Close()
+}
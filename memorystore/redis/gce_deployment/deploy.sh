@@ -23,7 +23,7 @@ fi
 
 
 # Cross compile the app for linux/amd64
-GOOS=linux GOARCH=amd64 go build -v -o app ../main.go
+GOOS=linux GOARCH=amd64 go build -v -o app ..
 # Add the app binary
 tar -cvf app.tar app
 # Copy to GCS bucket
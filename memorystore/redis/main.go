@@ -18,26 +18,75 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
-var redisPool *redis.Pool
+// counter is the Redis client used by the handlers.
+type counter interface {
+	// Incr increments key and returns its new value.
+	Incr(ctx context.Context, key string) (int64, error)
+	// Ping checks that Redis is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
 
-func incrementHandler(w http.ResponseWriter, r *http.Request) {
-	conn := redisPool.Get()
+var visits counter
+
+// redigoCounter is a counter using a redigo connection pool.
+type redigoCounter struct {
+	pool *redis.Pool
+}
+
+func (c *redigoCounter) Incr(ctx context.Context, key string) (int64, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return redis.Int64(conn.Do("INCR", key))
+}
+
+func (c *redigoCounter) Ping(ctx context.Context) error {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
+	_, err = conn.Do("PING")
+	return err
+}
+
+func (c *redigoCounter) Close() error {
+	return c.pool.Close()
+}
 
-	counter, err := redis.Int(conn.Do("INCR", "visits"))
+func incrementHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := visits.Incr(r.Context(), "visits")
 	if err != nil {
+		log.Printf("INCR: %v", err)
 		http.Error(w, "Error incrementing visitor counter", http.StatusInternalServerError)
 		return
 	}
-	fmt.Fprintf(w, "Visitor number: %d", counter)
+	fmt.Fprintf(w, "Visitor number: %d", n)
+}
+
+// healthzHandler reports whether Redis answers a PING.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := visits.Ping(ctx); err != nil {
+		log.Printf("PING: %v", err)
+		http.Error(w, "Redis unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "ok")
 }
 
 func main() {
@@ -46,12 +95,28 @@ func main() {
 	redisAddr := fmt.Sprintf("%s:%s", redisHost, redisPort)
 
 	const maxConnections = 10
-	redisPool = &redis.Pool{
+	visits = &redigoCounter{&redis.Pool{
 		MaxIdle: maxConnections,
 		Dial:    func() (redis.Conn, error) { return redis.Dial("tcp", redisAddr) },
+	}}
+	// [END memorystore_main_go]
+	// The REDIS_* environment variables select go-redis or Redis Cluster,
+	// and configure TLS, AUTH, the pool and timeouts. See config.go.
+	if hasOptions() {
+		visits.Close()
+		cfg, err := configFromEnv(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if visits, err = newCounter(cfg); err != nil {
+			log.Fatal(err)
+		}
 	}
+	// [START memorystore_main_go]
+	defer visits.Close()
 
 	http.HandleFunc("/", incrementHandler)
+	http.HandleFunc("/healthz", healthzHandler)
 
 	port := os.Getenv("PORT")
 	if port == "" {
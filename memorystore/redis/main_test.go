@@ -0,0 +1,268 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// setEnv sets the environment variables configFromEnv reads, clearing
+// the ones not in env.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{
+		"REDISHOST", "REDISPORT", "REDIS_CLIENT", "REDIS_CLUSTER",
+		"REDIS_TLS_CA_FILE", "REDIS_AUTH_SECRET", "REDIS_POOL_SIZE", "REDIS_MAX_IDLE",
+		"REDIS_DIAL_TIMEOUT", "REDIS_READ_TIMEOUT", "REDIS_WRITE_TIMEOUT", "REDIS_IDLE_TIMEOUT",
+	} {
+		t.Setenv(name, env[name])
+	}
+}
+
+// startCounter configures and connects the counter used by the handlers,
+// from env.
+func startCounter(t *testing.T, env map[string]string) {
+	t.Helper()
+	setEnv(t, env)
+	cfg, err := configFromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("configFromEnv: %v", err)
+	}
+	c, err := newCounter(cfg)
+	if err != nil {
+		t.Fatalf("newCounter: %v", err)
+	}
+	visits = c
+	t.Cleanup(func() { c.Close() })
+}
+
+func get(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", path, nil))
+	return rr
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	accessSecret = func(ctx context.Context, name string) (string, error) {
+		if name != "projects/p/secrets/redis-auth/versions/latest" {
+			return "", errors.New("not found")
+		}
+		return "s3cret\n", nil
+	}
+	t.Cleanup(func() { accessSecret = nil })
+
+	tests := []struct {
+		env     map[string]string
+		check   func(*config) bool
+		wantErr bool
+	}{
+		{
+			env: map[string]string{"REDISHOST": "10.0.0.3"},
+			check: func(c *config) bool {
+				return c.Addr == "10.0.0.3:6379" && c.Client == clientRedigo && !c.Cluster && c.TLS == nil &&
+					c.PoolSize == 10 && c.MaxIdle == 10 && c.DialTimeout == 5*time.Second && c.IdleTimeout == 5*time.Minute
+			},
+		},
+		{
+			env: map[string]string{"REDISHOST": "10.0.0.3", "REDISPORT": "6378", "REDIS_CLUSTER": "true",
+				"REDIS_POOL_SIZE": "50", "REDIS_READ_TIMEOUT": "250ms"},
+			check: func(c *config) bool {
+				return c.Addr == "10.0.0.3:6378" && c.Client == clientGoRedis && c.Cluster &&
+					c.PoolSize == 50 && c.ReadTimeout == 250*time.Millisecond
+			},
+		},
+		{
+			env:   map[string]string{"REDISHOST": "10.0.0.3", "REDIS_AUTH_SECRET": "projects/p/secrets/redis-auth/versions/latest"},
+			check: func(c *config) bool { return c.Password == "s3cret" },
+		},
+		{env: map[string]string{}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_CLIENT": "jedis"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_CLIENT": "redigo", "REDIS_CLUSTER": "1"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_CLUSTER": "maybe"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_MAX_IDLE": "0"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_DIAL_TIMEOUT": "5"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_TLS_CA_FILE": "testdata/missing.pem"}, wantErr: true},
+		{env: map[string]string{"REDISHOST": "h", "REDIS_AUTH_SECRET": "projects/p/secrets/other/versions/1"}, wantErr: true},
+	}
+	for _, test := range tests {
+		setEnv(t, test.env)
+		cfg, err := configFromEnv(context.Background())
+		if (err != nil) != test.wantErr {
+			t.Errorf("configFromEnv with %v: error %v, want error %v", test.env, err, test.wantErr)
+			continue
+		}
+		if err == nil && !test.check(cfg) {
+			t.Errorf("configFromEnv with %v = %+v", test.env, cfg)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"REDIS_CLIENT": "redigo"},
+		{"REDIS_CLIENT": "go-redis"},
+		{"REDIS_CLUSTER": "true"},
+	} {
+		t.Run(env["REDIS_CLIENT"]+env["REDIS_CLUSTER"], func(t *testing.T) {
+			s := miniredis.RunT(t)
+			env["REDISHOST"], env["REDISPORT"] = s.Host(), s.Port()
+			startCounter(t, env)
+
+			for _, want := range []string{"Visitor number: 1", "Visitor number: 2"} {
+				rr := get(incrementHandler, "/")
+				if rr.Code != http.StatusOK || rr.Body.String() != want {
+					t.Errorf("GET / = %d %q, want 200 %q", rr.Code, rr.Body, want)
+				}
+			}
+			if got, err := s.Get("visits"); err != nil || got != "2" {
+				t.Errorf("visits = %q, %v; want 2", got, err)
+			}
+
+			if rr := get(healthzHandler, "/healthz"); rr.Code != http.StatusOK {
+				t.Errorf("GET /healthz = %d, want 200", rr.Code)
+			}
+			s.Close()
+			if rr := get(healthzHandler, "/healthz"); rr.Code != http.StatusServiceUnavailable {
+				t.Errorf("GET /healthz with Redis down = %d, want 503", rr.Code)
+			}
+			if rr := get(incrementHandler, "/"); rr.Code != http.StatusInternalServerError {
+				t.Errorf("GET / with Redis down = %d, want 500", rr.Code)
+			}
+		})
+	}
+}
+
+func TestTLSAndAuth(t *testing.T) {
+	serverCert, caFile := newCertificate(t)
+	s, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	s.RequireAuth("s3cret")
+
+	password := "s3cret"
+	accessSecret = func(ctx context.Context, name string) (string, error) { return password, nil }
+	t.Cleanup(func() { accessSecret = nil })
+
+	for _, env := range []map[string]string{
+		{"REDIS_CLIENT": "redigo"},
+		{"REDIS_CLIENT": "go-redis"},
+		{"REDIS_CLUSTER": "true"},
+	} {
+		env["REDISHOST"], env["REDISPORT"] = s.Host(), s.Port()
+		env["REDIS_TLS_CA_FILE"] = caFile
+		env["REDIS_AUTH_SECRET"] = "projects/p/secrets/redis-auth/versions/latest"
+		name := env["REDIS_CLIENT"] + env["REDIS_CLUSTER"]
+
+		password = "s3cret"
+		startCounter(t, env)
+		if rr := get(healthzHandler, "/healthz"); rr.Code != http.StatusOK {
+			t.Errorf("%s: GET /healthz = %d %q, want 200", name, rr.Code, rr.Body)
+		}
+
+		password = "wrong"
+		startCounter(t, env)
+		if rr := get(healthzHandler, "/healthz"); rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: GET /healthz with the wrong AUTH string = %d, want 503", name, rr.Code)
+		}
+	}
+
+	// Without the CA, the server's certificate isn't trusted.
+	setEnv(t, map[string]string{"REDISHOST": s.Host(), "REDISPORT": s.Port(), "REDIS_CLIENT": "go-redis"})
+	cfg, err := configFromEnv(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TLS = &tls.Config{}
+	cfg.Password = "s3cret"
+	c, err := newCounter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Ping(context.Background()); err == nil {
+		t.Errorf("Ping without the server CA succeeded, want error")
+	}
+}
+
+// newCertificate returns a certificate for 127.0.0.1, and the name of a
+// PEM file holding the CA that issued it.
+func newCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Redis CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "redis"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "server-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{leafDER}, PrivateKey: key}, caFile
+}
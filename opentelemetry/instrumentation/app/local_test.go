@@ -0,0 +1,239 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// collector is an in-process OTLP/HTTP receiver which keeps the spans and
+// metrics it receives.
+type collector struct {
+	t *testing.T
+
+	lock  sync.Mutex
+	spans []ptrace.Span
+	// metrics holds the latest data received for each metric name. The
+	// application exports cumulative metrics, so it holds the totals.
+	metrics map[string]pmetric.Metric
+}
+
+func newCollector(t *testing.T) *collector {
+	return &collector{t: t, metrics: make(map[string]pmetric.Metric)}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	body, err := readAndCloseBody(req)
+	if err != nil {
+		c.t.Error(err)
+		return
+	}
+	switch req.URL.Path {
+	case "/v1/traces":
+		r, err := unmarshalTracesRequest(body)
+		if err != nil {
+			c.t.Error(err)
+			return
+		}
+		resourceSpans := r.Traces().ResourceSpans()
+		for i := 0; i < resourceSpans.Len(); i++ {
+			scopeSpans := resourceSpans.At(i).ScopeSpans()
+			for j := 0; j < scopeSpans.Len(); j++ {
+				spans := scopeSpans.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					c.spans = append(c.spans, spans.At(k))
+				}
+			}
+		}
+	case "/v1/metrics":
+		r, err := unmarshalMetricsRequest(body)
+		if err != nil {
+			c.t.Error(err)
+			return
+		}
+		resourceMetrics := r.Metrics().ResourceMetrics()
+		for i := 0; i < resourceMetrics.Len(); i++ {
+			scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+			for j := 0; j < scopeMetrics.Len(); j++ {
+				metrics := scopeMetrics.At(j).Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					c.metrics[metrics.At(k).Name()] = metrics.At(k)
+				}
+			}
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestLocalCollector(t *testing.T) {
+	ctx := context.Background()
+
+	col := newCollector(t)
+	collectorSrv := httptest.NewServer(col)
+	defer collectorSrv.Close()
+
+	// Export to the collector as OTLP over HTTP every second.
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collectorSrv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	t.Setenv("OTEL_METRICS_EXPORTER", "otlp")
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "1000")
+	t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "1000")
+
+	// Write logs as setupLogging does, but to a buffer.
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	jsonHandler := slog.NewJSONHandler(&logs, &slog.HandlerOptions{ReplaceAttr: replacer})
+	slog.SetDefault(slog.New(handlerWithSpanContext(jsonHandler)))
+
+	shutdown, err := setupOpenTelemetry(ctx)
+	require.NoError(t, err)
+
+	app := httptest.NewServer(newServeMux())
+	defer app.Close()
+	defer func(u string) { singleURL = u }(singleURL)
+	singleURL = app.URL + "/single"
+
+	const multiRequests = 3
+	for i := 0; i < multiRequests; i++ {
+		res, err := http.Get(app.URL + "/multi")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	// Shutting down flushes the remaining spans and metrics to the collector.
+	require.NoError(t, shutdown(ctx))
+
+	col.lock.Lock()
+	defer col.lock.Unlock()
+
+	spansByID := make(map[string]ptrace.Span)
+	for _, span := range col.spans {
+		spansByID[span.SpanID().String()] = span
+	}
+	// parentOf returns the parent of span, which must be in the same trace.
+	parentOf := func(span ptrace.Span) (ptrace.Span, bool) {
+		parent, ok := spansByID[span.ParentSpanID().String()]
+		if !ok || parent.TraceID() != span.TraceID() {
+			return ptrace.Span{}, false
+		}
+		return parent, true
+	}
+
+	// Each /multi request is the root of its own trace, and its /single
+	// subrequests are children of the client spans made under the
+	// subrequests span:
+	//
+	//	/multi -> subrequests -> client span -> /single
+	singlesByTrace := make(map[string]int)
+	multiTraces := make(map[string]bool)
+	for _, span := range col.spans {
+		switch span.Name() {
+		case "/multi":
+			assert.Equal(t, ptrace.SpanKindServer, span.Kind())
+			assert.True(t, span.ParentSpanID().IsEmpty(), "/multi span has a parent")
+			multiTraces[span.TraceID().String()] = true
+		case "/single":
+			assert.Equal(t, ptrace.SpanKindServer, span.Kind())
+			client, ok := parentOf(span)
+			if !assert.True(t, ok, "no parent for /single span %v", span.SpanID()) {
+				continue
+			}
+			assert.Equal(t, ptrace.SpanKindClient, client.Kind())
+			subrequests, ok := parentOf(client)
+			if !assert.True(t, ok, "no parent for client span %v", client.SpanID()) {
+				continue
+			}
+			assert.Equal(t, "subrequests", subrequests.Name())
+			multi, ok := parentOf(subrequests)
+			if !assert.True(t, ok, "no parent for subrequests span %v", subrequests.SpanID()) {
+				continue
+			}
+			assert.Equal(t, "/multi", multi.Name())
+			singlesByTrace[span.TraceID().String()]++
+		}
+	}
+	require.Len(t, multiTraces, multiRequests)
+
+	// Every log line carries the trace and span IDs of the request it was
+	// written for.
+	logsByMessage := make(map[string]int)
+	subRequests := 0
+	scanner := bufio.NewScanner(&logs)
+	for scanner.Scan() {
+		var line expectedLogFormat
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		logsByMessage[line.Message]++
+
+		span, ok := spansByID[line.SpanID]
+		if !assert.True(t, ok, "log line %q has unknown span ID", scanner.Text()) {
+			continue
+		}
+		assert.Equal(t, span.TraceID().String(), line.TraceID, "log line %q", scanner.Text())
+		assert.True(t, line.TraceSampled, "log line %q", scanner.Text())
+		switch line.Message {
+		case expectedLogMessage:
+			assert.Equal(t, "/multi", span.Name())
+			assert.Equal(t, line.SubRequests, singlesByTrace[line.TraceID])
+			subRequests += line.SubRequests
+		case "handle /single request":
+			assert.Equal(t, "/single", span.Name())
+		default:
+			t.Errorf("unexpected log line %q", scanner.Text())
+		}
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, multiRequests, logsByMessage[expectedLogMessage])
+	assert.Equal(t, subRequests, logsByMessage["handle /single request"])
+
+	// The custom metrics are exported with the spans.
+	wantRequests := map[string]int64{"/multi": multiRequests, "/single": int64(subRequests)}
+	requests, ok := col.metrics["example.requests"]
+	require.True(t, ok, "example.requests not received")
+	gotRequests := make(map[string]int64)
+	for i := 0; i < requests.Sum().DataPoints().Len(); i++ {
+		dp := requests.Sum().DataPoints().At(i)
+		route, _ := dp.Attributes().Get("http.route")
+		gotRequests[route.Str()] += dp.IntValue()
+	}
+	assert.Equal(t, wantRequests, gotRequests)
+
+	duration, ok := col.metrics["example.request.duration"]
+	require.True(t, ok, "example.request.duration not received")
+	gotDurations := make(map[string]int64)
+	for i := 0; i < duration.Histogram().DataPoints().Len(); i++ {
+		dp := duration.Histogram().DataPoints().At(i)
+		route, _ := dp.Attributes().Get("http.route")
+		gotDurations[route.Str()] += int64(dp.Count())
+		assert.Greater(t, dp.Sum(), 0.0)
+	}
+	assert.Equal(t, wantRequests, gotDurations)
+}
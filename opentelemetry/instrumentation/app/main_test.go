@@ -75,6 +75,8 @@ func TestWriteTelemetry(t *testing.T) {
 			{name: "http.server.request.duration"},
 			{name: "example.subrequests"},
 			{name: "example.sleep.duration"},
+			{name: "example.requests"},
+			{name: "example.request.duration"},
 		},
 	}
 	http.HandleFunc("/v1/metrics", ms.handleMetrics)
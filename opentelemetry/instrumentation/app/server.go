@@ -19,8 +19,11 @@ import (
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // handleSingle handles an http request by sleeping for 100-200 ms. It writes
@@ -28,6 +31,7 @@ import (
 // [START opentelemetry_instrumentation_handle_single]
 func handleSingle(w http.ResponseWriter, r *http.Request) {
 	sleepTime := randomSleep(r)
+	slog.InfoContext(r.Context(), "handle /single request", slog.Duration("sleepTime", sleepTime))
 	fmt.Fprintf(w, "work completed in %v\n", sleepTime)
 }
 
@@ -57,19 +61,53 @@ func handleMulti(w http.ResponseWriter, r *http.Request) {
 // /multi and /single endpoints.
 // [START opentelemetry_instrumentation_run_server]
 func runServer() error {
-	handleHTTP("/single", handleSingle)
-	handleHTTP("/multi", handleMulti)
+	return http.ListenAndServe(":8080", newServeMux())
+}
 
-	return http.ListenAndServe(":8080", nil)
+// newServeMux returns a ServeMux which handles requests to the /multi and
+// /single endpoints.
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	handleHTTP(mux, "/single", handleSingle)
+	handleHTTP(mux, "/multi", handleMulti)
+	return mux
 }
 
 // handleHTTP handles the http HandlerFunc on the specified route, and uses
 // otelhttp for context propagation, trace instrumentation, and metric
 // instrumentation.
-func handleHTTP(route string, handleFn http.HandlerFunc) {
-	instrumentedHandler := otelhttp.NewHandler(otelhttp.WithRouteTag(route, handleFn), route)
+func handleHTTP(mux *http.ServeMux, route string, handleFn http.HandlerFunc) {
+	instrumentedHandler := otelhttp.NewHandler(otelhttp.WithRouteTag(route, countRequests(route, handleFn)), route)
+
+	mux.Handle(route, instrumentedHandler)
+}
+
+// countRequests wraps handleFn to record the example.requests and
+// example.request.duration metrics for each request to route.
+func countRequests(route string, handleFn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handleFn(sw, r)
+
+		attrs := metric.WithAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", sw.status),
+		)
+		requestCounter.Add(r.Context(), 1, attrs)
+		requestDurationHistogram.Record(r.Context(), time.Since(start).Seconds(), attrs)
+	}
+}
+
+// statusWriter records the status code written to an http.ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
 
-	http.Handle(route, instrumentedHandler)
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
 }
 
 // [END opentelemetry_instrumentation_run_server]
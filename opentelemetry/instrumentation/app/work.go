@@ -28,10 +28,12 @@ import (
 const scopeName = "github.com/GoogleCloudPlatform/golang-samples/opentelemetry/instrumentation/app/work"
 
 var (
-	meter                = otel.Meter(scopeName)
-	tracer               = otel.Tracer(scopeName)
-	sleepHistogram       metric.Float64Histogram
-	subRequestsHistogram metric.Int64Histogram
+	meter                    = otel.Meter(scopeName)
+	tracer                   = otel.Tracer(scopeName)
+	sleepHistogram           metric.Float64Histogram
+	subRequestsHistogram     metric.Int64Histogram
+	requestCounter           metric.Int64Counter
+	requestDurationHistogram metric.Float64Histogram
 )
 
 // [END opentelemetry_instrumentation_work_globals]
@@ -55,6 +57,21 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	requestCounter, err = meter.Int64Counter("example.requests",
+		metric.WithDescription("Sample counter of the requests handled, by route"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		panic(err)
+	}
+
+	requestDurationHistogram, err = meter.Float64Histogram("example.request.duration",
+		metric.WithDescription("Sample histogram to measure the latency of the requests handled, by route"),
+		metric.WithExplicitBucketBoundaries(0.1, 0.2, 0.4, 0.6, 0.8, 1, 1.5, 2),
+		metric.WithUnit("s"))
+	if err != nil {
+		panic(err)
+	}
 }
 
 // randomSleep simulates a some job being triggerred in response to an API call to the server.
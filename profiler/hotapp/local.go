@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// runLocal runs sc for d, and writes its profiles to dir, as
+// TYPE.pprof files for each of profileTypes. The CPU profile covers the
+// whole run. The others are snapshots taken before the scenario stops.
+func runLocal(ctx context.Context, sc scenario, dir string, d time.Duration) (err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	// Record every contention and blocking event, like Cloud Profiler does
+	// with MutexProfiling.
+	prevMutex := runtime.SetMutexProfileFraction(1)
+	runtime.SetBlockProfileRate(1)
+	defer func() {
+		runtime.SetMutexProfileFraction(prevMutex)
+		runtime.SetBlockProfileRate(0)
+	}()
+
+	cpu, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := cpu.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc.run(ctx)
+	}()
+
+	time.Sleep(d)
+	pprof.StopCPUProfile()
+	// Update the heap profile, which is as of the last garbage collection.
+	runtime.GC()
+	var errs []error
+	for _, typ := range profileTypes[1:] {
+		errs = append(errs, writeProfile(dir, typ))
+	}
+	cancel()
+	<-done
+	return errors.Join(errs...)
+}
+
+// writeProfile writes the profile typ to dir/typ.pprof.
+func writeProfile(dir, typ string) error {
+	f, err := os.Create(filepath.Join(dir, typ+".pprof"))
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(typ).WriteTo(f, 0); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s profile: %w", typ, err)
+	}
+	return f.Close()
+}
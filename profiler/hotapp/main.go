@@ -14,10 +14,17 @@
 
 // Sample hotapp is a synthetic application that exhibits different types of
 // profiling hotspots: CPU, heap allocations, thread contention.
+//
+// The hotspots it exhibits are chosen by a scenario, from scenarios.json or
+// from the file given by -scenarios. With -local_profiles, it writes pprof
+// profiles to a local directory instead of sending them to Cloud Profiler.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"runtime"
 	"sync"
@@ -37,73 +44,155 @@ var (
 	skew = flag.Int("skew", 100, "skew of foo2 over foo1: foo2 will consume skew/100 CPU time compared to foo1 (default is no skew)")
 	// Whether to run some local CPU work to increase the self metric.
 	localWork = flag.Bool("local_work", false, "whether to run some local CPU work")
+	// Scenario to run, and the file defining it.
+	scenariosFile = flag.String("scenarios", "", "JSON file defining the scenarios (default is the scenarios.json built into hotapp)")
+	scenarioName  = flag.String("scenario", "default", "scenario to run")
+	listScenarios = flag.Bool("list", false, "list the scenarios and exit")
+	// Local mode.
+	localProfiles = flag.String("local_profiles", "", "run without Cloud Profiler, and write pprof profiles to this directory")
+	duration      = flag.Duration("duration", 0, "how long to run the scenario (default is forever, or 10s with -local_profiles)")
 	// There are several goroutines continuously fighting for this mutex.
 	mu sync.Mutex
 	// Some allocated memory. Held in a global variable to protect it from GC.
-	mem [][]byte
+	memMu sync.Mutex
+	mem   [][]byte
 )
 
 // Simulates some work that contends over a shared mutex. It calls an "impl"
 // function to produce a bit deeper stacks in the profiler visualization,
 // merely for illustration purpose.
-func contention(d time.Duration) {
-	contentionImpl(d)
+func contention(ctx context.Context, d time.Duration) {
+	contentionImpl(ctx, d)
 }
 
-func contentionImpl(d time.Duration) {
-	for {
+func contentionImpl(ctx context.Context, d time.Duration) {
+	for ctx.Err() == nil {
 		mu.Lock()
 		time.Sleep(d)
 		mu.Unlock()
 	}
 }
 
-// Waits forever simulating a goroutine that is consistently blocked on I/O.
-// It calls an "impl" function to produce a bit deeper stacks in the profiler
-// visualization, merely for illustration purpose.
-func wait() {
-	waitImpl()
+// Waits until ctx is done, simulating a goroutine that is consistently
+// blocked. It calls an "impl" function to produce a bit deeper stacks in the
+// profiler visualization, merely for illustration purpose.
+func wait(ctx context.Context) {
+	waitImpl(ctx)
+}
+
+func waitImpl(ctx context.Context) {
+	<-ctx.Done()
+}
+
+// Simulates goroutines blocked on I/O: they read from a pipe, which is
+// written to every interval. It calls an "impl" function to produce a bit
+// deeper stacks in the profiler visualization, merely for illustration
+// purpose.
+func blockingIO(ctx context.Context, interval time.Duration) {
+	blockingIOImpl(ctx, interval)
+}
+
+func blockingIOImpl(ctx context.Context, interval time.Duration) {
+	r, w := io.Pipe()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				w.Close()
+				return
+			case <-t.C:
+				w.Write([]byte("x"))
+			}
+		}
+	}()
+	buf := make([]byte, 1)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// Simulates a goroutine leak: n goroutines are started every interval, and
+// wait for a signal that never comes. They only exit when ctx is done, so
+// that hotapp can stop cleanly.
+func leakGoroutines(ctx context.Context, n int, interval time.Duration) {
+	never := make(chan struct{})
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for i := 0; i < n; i++ {
+			go leakImpl(ctx, never)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
 }
 
-func waitImpl() {
-	select {}
+func leakImpl(ctx context.Context, never <-chan struct{}) {
+	select {
+	case <-never:
+	case <-ctx.Done():
+	}
 }
 
 // Simulates a memory-hungry function. It calls an "impl" function to produce
 // a bit deeper stacks in the profiler visualization, merely for illustration
 // purpose.
-func allocOnce() {
-	allocImpl()
+func allocOnce(size int) {
+	allocImpl(size)
 }
 
-func allocImpl() {
-	// Allocate 64 MiB in 64 KiB chunks
-	for i := 0; i < 64*16; i++ {
+func allocImpl(size int) {
+	// Allocate size bytes in 64 KiB chunks.
+	memMu.Lock()
+	defer memMu.Unlock()
+	for i := 0; i < size/(64*1024); i++ {
 		mem = append(mem, make([]byte, 64*1024))
 	}
 }
 
+// growHeap simulates a memory leak, allocating size more bytes that are
+// never freed every interval.
+func growHeap(ctx context.Context, size int, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		allocImpl(size)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
 // allocMany simulates a function which allocates a lot of memory, but does not
 // hold on to that memory.
-func allocMany() {
-	// Allocate 1 MiB of 64 KiB chunks repeatedly.
-	for {
-		for i := 0; i < 16; i++ {
+func allocMany(ctx context.Context, size int, interval time.Duration) {
+	// Allocate size bytes of 64 KiB chunks repeatedly.
+	for ctx.Err() == nil {
+		for i := 0; i < size/(64*1024); i++ {
 			_ = make([]byte, 64*1024)
 		}
-		time.Sleep(100 * time.Millisecond)
+		time.Sleep(interval)
 	}
 }
 
 // Simulates a CPU-intensive computation.
-func busyloop() {
-	for {
+func busyloop(ctx context.Context, skew int) {
+	for ctx.Err() == nil {
 		if *localWork {
 			for i := 0; i < 100*(1<<16); i++ {
 			}
 		}
 		foo1(100)
-		foo2(*skew)
+		foo2(skew)
 		// Yield so that some preemption happens.
 		runtime.Gosched()
 	}
@@ -151,7 +240,38 @@ func load(scale int) {
 func main() {
 	flag.Parse()
 
-	err := profiler.Start(profiler.Config{
+	scenarios, err := loadScenarios(*scenariosFile)
+	if err != nil {
+		log.Fatalf("failed to load the scenarios: %v", err)
+	}
+	if *listScenarios {
+		for _, name := range scenarios.names() {
+			fmt.Printf("%-20s %s\n", name, scenarios[name].Description)
+		}
+		return
+	}
+	sc, ok := scenarios[*scenarioName]
+	if !ok {
+		log.Fatalf("unknown scenario %q, want one of %q", *scenarioName, scenarios.names())
+	}
+
+	// Use four OS threads for the contention simulation.
+	runtime.GOMAXPROCS(4)
+
+	ctx := context.Background()
+	if *localProfiles != "" {
+		d := *duration
+		if d <= 0 {
+			d = 10 * time.Second
+		}
+		if err := runLocal(ctx, sc, *localProfiles, d); err != nil {
+			log.Fatalf("failed to run the %s scenario: %v", *scenarioName, err)
+		}
+		log.Printf("wrote the profiles of the %s scenario to %s", *scenarioName, *localProfiles)
+		return
+	}
+
+	err = profiler.Start(profiler.Config{
 		ProjectID:      *projectID,
 		Service:        *service,
 		ServiceVersion: *version,
@@ -162,23 +282,11 @@ func main() {
 		log.Fatalf("failed to start the profiler: %v", err)
 	}
 
-	// Use four OS threads for the contention simulation.
-	runtime.GOMAXPROCS(4)
-	for i := 0; i < 4; i++ {
-		go contention(time.Duration(i) * 50 * time.Millisecond)
-	}
-
-	// Simulate some waiting goroutines.
-	for i := 0; i < 100; i++ {
-		go wait()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
 	}
-
-	// Simulate some memory allocation.
-	allocOnce()
-
-	// Simulate repeated memory allocations.
-	go allocMany()
-
-	// Simulate CPU load.
-	busyloop()
+	log.Printf("running the %s scenario", *scenarioName)
+	sc.run(ctx)
 }
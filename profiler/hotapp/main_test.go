@@ -0,0 +1,161 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// TestScenarios runs each scenario in local mode, and checks that the
+// functions it expects show up in its profiles.
+func TestScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping scenarios in short mode")
+	}
+	scenarios, err := loadScenarios("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range scenarios.names() {
+		sc := scenarios[name]
+		t.Run(name, func(t *testing.T) {
+			if len(sc.Expect) == 0 {
+				t.Fatalf("scenario %s expects nothing", name)
+			}
+			dir := t.TempDir()
+			if err := runLocal(context.Background(), sc, dir, 1500*time.Millisecond); err != nil {
+				t.Fatalf("runLocal: %v", err)
+			}
+			for typ, funcs := range sc.Expect {
+				p := readProfile(t, filepath.Join(dir, typ+".pprof"))
+				for _, fn := range funcs {
+					if share := share(p, fn); share == 0 {
+						t.Errorf("%s doesn't show up in the %s profile", fn, typ)
+					} else {
+						t.Logf("%s profile: %s %.1f%%", typ, fn, 100*share)
+					}
+				}
+			}
+		})
+	}
+}
+
+func readProfile(t *testing.T, name string) *profile.Profile {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return p
+}
+
+// share returns the share of the samples of p whose stacks include the
+// function fn, weighted by the default sample type of p: CPU time,
+// in-use heap space, goroutines or contention delay.
+func share(p *profile.Profile, fn string) float64 {
+	idx := len(p.SampleType) - 1
+	for i, st := range p.SampleType {
+		if st.Type == p.DefaultSampleType {
+			idx = i
+		}
+	}
+	var total, matched int64
+	for _, s := range p.Sample {
+		v := s.Value[idx]
+		total += v
+		if hasFunc(s, fn) {
+			matched += v
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
+
+// hasFunc reports whether the stack of s includes fn, including inlined
+// calls.
+func hasFunc(s *profile.Sample, fn string) bool {
+	for _, loc := range s.Location {
+		for _, line := range loc.Line {
+			if line.Function != nil && mainFunc(line.Function.Name) == fn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mainFunc returns name, a function of package main, as it's named in the
+// profiles of the hotapp command: test binaries name package main after
+// its import path.
+func mainFunc(name string) string {
+	const path = "github.com/GoogleCloudPlatform/golang-samples/profiler/hotapp."
+	if rest, ok := strings.CutPrefix(name, path); ok {
+		return "main." + rest
+	}
+	return name
+}
+
+func TestLoadScenarios(t *testing.T) {
+	tests := []struct {
+		json    string
+		wantErr string
+	}{
+		{json: `{"s": {"workloads": [{"type": "gpu"}]}}`, wantErr: `unknown type "gpu"`},
+		{json: `{"s": {"workloads": [{"type": "cpu"}], "expect": {"threads": ["main.load"]}}}`, wantErr: `unknown profile type "threads"`},
+		{json: `{"s": {"workloads": [{"type": "leak", "interval": "soon"}]}}`, wantErr: `invalid duration`},
+		{json: `{"s": {"workloads": [{"type": "mutex", "goroutines": -1}]}}`, wantErr: `negative`},
+	}
+	for _, test := range tests {
+		name := filepath.Join(t.TempDir(), "scenarios.json")
+		if err := os.WriteFile(name, []byte(test.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadScenarios(name); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("loadScenarios(%s) = %v, want error containing %q", test.json, err, test.wantErr)
+		}
+	}
+
+	name := filepath.Join(t.TempDir(), "scenarios.json")
+	if err := os.WriteFile(name, []byte(`{"s": {"workloads": [{"type": "leak", "goroutines": 3}, {"type": "mutex"}]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadScenarios(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []workload{
+		{Type: "leak", Goroutines: 3, Interval: jsonDuration(100 * time.Millisecond)},
+		{Type: "mutex", Goroutines: 4, Hold: jsonDuration(50 * time.Millisecond)},
+	}
+	for i, w := range s["s"].Workloads {
+		if w != want[i] {
+			t.Errorf("workload %d = %+v, want %+v", i, w, want[i])
+		}
+	}
+}
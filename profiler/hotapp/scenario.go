@@ -0,0 +1,201 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+//go:embed scenarios.json
+var defaultScenarios []byte
+
+// scenario is a set of workloads run together, and the functions they
+// make hot in each type of profile.
+type scenario struct {
+	Description string     `json:"description"`
+	Workloads   []workload `json:"workloads"`
+	// Expect maps profile types (cpu, heap, goroutine, mutex or block) to
+	// functions which should show up in those profiles.
+	Expect map[string][]string `json:"expect"`
+}
+
+// workload is a workload of a scenario. Its fields other than Type are
+// optional, and only apply to some types of workloads.
+type workload struct {
+	// Type is one of:
+	//   - cpu: CPU-intensive loops, in busyloop.
+	//   - heap: retaining Bytes of memory, once or every Interval.
+	//   - alloc: allocating Bytes of garbage every Interval.
+	//   - wait: Goroutines goroutines waiting forever.
+	//   - leak: starting Goroutines goroutines that never exit every
+	//     Interval.
+	//   - mutex: Goroutines goroutines contending over a mutex, which
+	//     goroutine i holds for i*Hold.
+	//   - blocking: Goroutines goroutines blocked reading from pipes written
+	//     to every Interval.
+	Type       string       `json:"type"`
+	Goroutines int          `json:"goroutines"`
+	Bytes      int          `json:"bytes"`
+	Interval   jsonDuration `json:"interval"`
+	Hold       jsonDuration `json:"hold"`
+	// Skew is the skew of foo2 over foo1 in cpu workloads. It defaults to
+	// the -skew flag.
+	Skew int `json:"skew"`
+}
+
+// jsonDuration is a time.Duration written as a string in JSON, such as
+// "50ms".
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// defaults of workload fields, by type.
+var defaults = map[string]workload{
+	"cpu":      {Goroutines: 1},
+	"heap":     {Bytes: 64 << 20},
+	"alloc":    {Bytes: 1 << 20, Interval: jsonDuration(100 * time.Millisecond)},
+	"wait":     {Goroutines: 100},
+	"leak":     {Goroutines: 10, Interval: jsonDuration(100 * time.Millisecond)},
+	"mutex":    {Goroutines: 4, Hold: jsonDuration(50 * time.Millisecond)},
+	"blocking": {Goroutines: 10, Interval: jsonDuration(time.Second)},
+}
+
+// profileTypes are the types of profiles scenarios can expect functions in.
+var profileTypes = []string{"cpu", "heap", "goroutine", "mutex", "block"}
+
+// scenarios maps the names of scenarios to scenarios.
+type scenarios map[string]scenario
+
+// loadScenarios reads the scenarios of the JSON file name, or the built-in
+// ones if name is empty.
+func loadScenarios(name string) (scenarios, error) {
+	b := defaultScenarios
+	if name != "" {
+		var err error
+		if b, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	var s scenarios
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for name, sc := range s {
+		for i, w := range sc.Workloads {
+			def, ok := defaults[w.Type]
+			if !ok {
+				return nil, fmt.Errorf("scenario %s: workload %d has unknown type %q", name, i, w.Type)
+			}
+			if w.Goroutines == 0 {
+				w.Goroutines = def.Goroutines
+			}
+			if w.Bytes == 0 {
+				w.Bytes = def.Bytes
+			}
+			if w.Interval == 0 {
+				w.Interval = def.Interval
+			}
+			if w.Hold == 0 {
+				w.Hold = def.Hold
+			}
+			if w.Goroutines < 0 || w.Bytes < 0 || w.Interval < 0 || w.Hold < 0 || w.Skew < 0 {
+				return nil, fmt.Errorf("scenario %s: workload %d has negative settings", name, i)
+			}
+			sc.Workloads[i] = w
+		}
+		for typ := range sc.Expect {
+			if !slices.Contains(profileTypes, typ) {
+				return nil, fmt.Errorf("scenario %s: unknown profile type %q, want one of %q", name, typ, profileTypes)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s scenarios) names() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run runs the workloads of the scenario until ctx is done.
+func (sc scenario) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	start := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	for _, w := range sc.Workloads {
+		interval, hold := time.Duration(w.Interval), time.Duration(w.Hold)
+		switch w.Type {
+		case "cpu":
+			s := w.Skew
+			if s == 0 {
+				s = *skew
+			}
+			for i := 0; i < w.Goroutines; i++ {
+				start(func() { busyloop(ctx, s) })
+			}
+		case "heap":
+			if interval == 0 {
+				allocOnce(w.Bytes)
+				continue
+			}
+			start(func() { growHeap(ctx, w.Bytes, interval) })
+		case "alloc":
+			start(func() { allocMany(ctx, w.Bytes, interval) })
+		case "wait":
+			for i := 0; i < w.Goroutines; i++ {
+				start(func() { wait(ctx) })
+			}
+		case "leak":
+			start(func() { leakGoroutines(ctx, w.Goroutines, interval) })
+		case "mutex":
+			for i := 0; i < w.Goroutines; i++ {
+				start(func() { contention(ctx, time.Duration(i)*hold) })
+			}
+		case "blocking":
+			for i := 0; i < w.Goroutines; i++ {
+				start(func() { blockingIO(ctx, interval) })
+			}
+		}
+	}
+	wg.Wait()
+}
@@ -33,7 +33,9 @@ import (
 
 var (
 	projectID        = flag.String("project_id", "", "project ID to run profiler with; only required when running outside of GCP.")
-	version          = flag.String("version", "original", "version to run profiler with")
+	version          = flag.String("version", "", "version to run profiler with; defaults to the mode")
+	mode             = flag.String("mode", "slow", "how the server searches: slow, or optimized to compare their profiles")
+	corpus           = flag.String("corpus", "gs://dataflow-samples/shakespeare/", "texts to search: gs://BUCKET/PREFIX, or a local directory")
 	port             = flag.Int("port", 7788, "service port")
 	numReqs          = flag.Int("num_requests", 20, "number of requests to simulate")
 	concurrency      = flag.Int("concurrency", 1, "number of requests to run in parallel")
@@ -47,6 +49,26 @@ var (
 func main() {
 	flag.Parse()
 
+	m, err := shakesapp.ParseMode(*mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source, err := shakesapp.ParseSource(*corpus)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The expected match counts of the default queries are those of the
+	// default corpus.
+	var queries []shakesapp.Query
+	if source != shakesapp.DefaultSource {
+		for _, q := range shakesapp.DefaultQueries {
+			queries = append(queries, shakesapp.Query{Query: q.Query, WantMatchCount: -1})
+		}
+	}
+	if *version == "" {
+		*version = m.String()
+	}
+
 	if err := profiler.Start(profiler.Config{
 		Service:              "shakesapp",
 		ServiceVersion:       *version,
@@ -61,7 +83,7 @@ func main() {
 	}
 
 	server := grpc.NewServer()
-	shakesapp.RegisterShakespeareServiceServer(server, shakesapp.NewServer())
+	shakesapp.RegisterShakespeareServiceServer(server, shakesapp.NewServer(shakesapp.Config{Source: source, Mode: m}))
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
@@ -72,10 +94,12 @@ func main() {
 	for i := 1; *numRounds == 0 || i <= *numRounds; i++ {
 		start := time.Now()
 		log.Printf("Simulating client requests, round %d", i)
-		if err := shakesapp.SimulateClient(ctx, fmt.Sprintf(":%d", *port), *numReqs, *concurrency); err != nil {
+		stats, err := shakesapp.SimulateClient(ctx, fmt.Sprintf(":%d", *port), *numReqs, *concurrency, queries)
+		if err != nil {
 			log.Fatalf("Failed to simulate client requests: %v", err)
 		}
 		delta := time.Since(start).Round(10 * time.Millisecond)
 		log.Printf("Simulated %d requests in %s, rate of %f reqs / sec", *numReqs, delta, float64(*numReqs)/delta.Seconds())
+		log.Printf("%s mode: %v", m, stats)
 	}
 }
@@ -17,28 +17,66 @@ package shakesapp
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"slices"
+	"time"
 
 	"google.golang.org/grpc"
 )
 
-type query struct {
-	query          string
-	wantMatchCount int64
+// Query is a query sent by SimulateClient.
+type Query struct {
+	Query string
+	// WantMatchCount is the number of matches the server should find, or
+	// -1 not to check it.
+	WantMatchCount int64
 }
 
-var queries = []query{
+// DefaultQueries are queries whose match counts are those in the works of
+// Shakespeare, in DefaultSource.
+var DefaultQueries = []Query{
 	{"hello", 349},
 	{"world", 728},
 	{"to be, or not to be", 1},
 	{"insolence", 14},
 }
 
-// SimulateClient creates a client which will send load to the server.
-func SimulateClient(ctx context.Context, addr string, numReqs, reqsInFlight int) error {
+// Stats summarizes the requests of a simulation.
+type Stats struct {
+	Requests int
+	Errors   int
+	// P50, P90 and P99 are percentiles of the latencies of the requests,
+	// and Max is the highest latency.
+	P50, P90, P99, Max time.Duration
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d requests, %d errors, latency p50 %v, p90 %v, p99 %v, max %v",
+		s.Requests, s.Errors, s.P50, s.P90, s.P99, s.Max)
+}
+
+// percentile returns the p-th percentile of sorted, using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// SimulateClient creates a client which will send load to the server: numReqs
+// queries picked at random from queries, or from DefaultQueries if it's nil,
+// with up to reqsInFlight of them in flight at once. It returns statistics
+// about the requests, and the last error, if any request failed.
+func SimulateClient(ctx context.Context, addr string, numReqs, reqsInFlight int, queries []Query) (Stats, error) {
+	if queries == nil {
+		queries = DefaultQueries
+	}
 	conn, err := grpc.Dial(addr, grpc.WithInsecure())
 	if err != nil {
-		return err
+		return Stats{}, err
 	}
 	defer conn.Close()
 	client := NewShakespeareServiceClient(conn)
@@ -46,30 +84,46 @@ func SimulateClient(ctx context.Context, addr string, numReqs, reqsInFlight int)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	respErrs := make(chan error)
+	type result struct {
+		latency time.Duration
+		err     error
+	}
+	results := make(chan result)
 	inFlightCh := make(chan bool, reqsInFlight)
 	for i := 0; i < numReqs; i++ {
 		go func() {
 			inFlightCh <- true
 			defer func() { <-inFlightCh }()
-			respErrs <- func() error {
+			start := time.Now()
+			err := func() error {
 				q := queries[rand.Intn(len(queries))]
-				resp, err := client.GetMatchCount(ctx, &ShakespeareRequest{Query: q.query})
+				resp, err := client.GetMatchCount(ctx, &ShakespeareRequest{Query: q.Query})
 				if err != nil {
 					return err
 				}
-				if resp.MatchCount != q.wantMatchCount {
-					return fmt.Errorf("GetMatchCount(%q): got %d matches, want %d", q.query, resp.MatchCount, q.wantMatchCount)
+				if q.WantMatchCount >= 0 && resp.MatchCount != q.WantMatchCount {
+					return fmt.Errorf("GetMatchCount(%q): got %d matches, want %d", q.Query, resp.MatchCount, q.WantMatchCount)
 				}
 				return nil
 			}()
+			results <- result{time.Since(start), err}
 		}()
 	}
 	var retErr error
+	stats := Stats{Requests: numReqs}
+	latencies := make([]time.Duration, 0, numReqs)
 	for i := 0; i < numReqs; i++ {
-		if err := <-respErrs; err != nil {
-			retErr = err
+		r := <-results
+		if r.err != nil {
+			retErr = r.err
+			stats.Errors++
 		}
+		latencies = append(latencies, r.latency)
 	}
-	return retErr
+	slices.Sort(latencies)
+	stats.P50 = percentile(latencies, 50)
+	stats.P90 = percentile(latencies, 90)
+	stats.P99 = percentile(latencies, 99)
+	stats.Max = percentile(latencies, 100)
+	return stats, retErr
 }
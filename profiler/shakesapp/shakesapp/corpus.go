@@ -0,0 +1,157 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shakesapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// CorpusSource reads the texts the server searches.
+type CorpusSource interface {
+	ReadTexts(ctx context.Context) ([]string, error)
+}
+
+// DefaultSource is the works of Shakespeare, in a public bucket.
+var DefaultSource CorpusSource = BucketSource{Bucket: "dataflow-samples", Prefix: "shakespeare/"}
+
+// ParseSource returns the source for location: gs://BUCKET/PREFIX for the
+// objects under a prefix of a Cloud Storage bucket, or a local directory.
+func ParseSource(location string) (CorpusSource, error) {
+	if rest, ok := strings.CutPrefix(location, "gs://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid corpus %q: no bucket", location)
+		}
+		return BucketSource{Bucket: bucket, Prefix: prefix}, nil
+	}
+	fi, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid corpus: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("invalid corpus %q: not a directory", location)
+	}
+	return DirSource(location), nil
+}
+
+// BucketSource reads the objects under Prefix in a public Cloud Storage
+// bucket.
+type BucketSource struct {
+	Bucket string
+	Prefix string
+}
+
+// ReadTexts reads the content of the objects in parallel. It fails if
+// listing or reading any of the objects fails.
+func (b BucketSource) ReadTexts(ctx context.Context) ([]string, error) {
+	type resp struct {
+		s   string
+		err error
+	}
+
+	client, err := storage.NewClient(ctx, option.WithoutAuthentication())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(b.Bucket)
+
+	var paths []string
+	it := bucket.Objects(ctx, &storage.Query{Prefix: b.Prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate over files in %s starting with %s: %w", b.Bucket, b.Prefix, err)
+		}
+		if attrs.Name != "" {
+			paths = append(paths, attrs.Name)
+		}
+	}
+
+	resps := make(chan resp)
+	for _, path := range paths {
+		go func(path string) {
+			r, err := bucket.Object(path).NewReader(ctx)
+			if err != nil {
+				resps <- resp{"", err}
+				return
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			resps <- resp{string(data), err}
+		}(path)
+	}
+	ret := make([]string, len(paths))
+	for i := 0; i < len(paths); i++ {
+		r := <-resps
+		if r.err != nil {
+			err = r.err
+		}
+		ret[i] = r.s
+	}
+	return ret, err
+}
+
+// DirSource reads the files in a local directory, such as a copy of the
+// bucket made with
+//
+//	gcloud storage cp -r gs://dataflow-samples/shakespeare .
+type DirSource string
+
+// ReadTexts reads the regular files in the directory and its
+// subdirectories, in name order.
+func (d DirSource) ReadTexts(ctx context.Context) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(string(d), func(path string, e os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no files in " + string(d))
+	}
+	sort.Strings(paths)
+	texts := make([]string, len(paths))
+	for i, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = string(b)
+	}
+	return texts, nil
+}
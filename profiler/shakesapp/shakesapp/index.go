@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shakesapp
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// corpus is the searchable form of the texts: their lowercased lines, and
+// an index of the lines each word appears in.
+type corpus struct {
+	lines []string
+	// index maps each word to the ascending indices of the lines it
+	// appears in, as a whole word.
+	index map[string][]int
+}
+
+func newCorpus(texts []string) *corpus {
+	c := &corpus{index: make(map[string][]int)}
+	for _, text := range texts {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.ToLower(line)
+			i := len(c.lines)
+			c.lines = append(c.lines, line)
+			for _, w := range strings.FieldsFunc(line, isSeparator) {
+				if p := c.index[w]; len(p) == 0 || p[len(p)-1] != i {
+					c.index[w] = append(p, i)
+				}
+			}
+		}
+	}
+	return c
+}
+
+// isSeparator reports whether r separates words.
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// count returns the number of lines matching re.
+func (c *corpus) count(re *regexp.Regexp) int64 {
+	prefix, complete := re.LiteralPrefix()
+	if complete {
+		return c.countLiteral(prefix)
+	}
+	var n int64
+	for _, line := range c.lines {
+		// Every match starts with prefix, so lines without it can't match,
+		// and strings.Contains is faster than the regexp.
+		if strings.Contains(line, prefix) && re.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// countLiteral returns the number of lines containing s.
+func (c *corpus) countLiteral(s string) int64 {
+	var n int64
+	for _, i := range c.candidates(s) {
+		if strings.Contains(c.lines[i], s) {
+			n++
+		}
+	}
+	return n
+}
+
+// candidates returns the indices of the lines which may contain s.
+//
+// A word of s with separators on both sides within s is a whole word of
+// any line containing s, so only the lines the index lists for every such
+// word are candidates. Other words may be parts of longer words: "hello"
+// is in "othello". If s has no such word, every line is a candidate.
+func (c *corpus) candidates(s string) []int {
+	var words []string
+	start := -1
+	for i, r := range s {
+		switch {
+		case !isSeparator(r):
+			if start < 0 {
+				start = i
+			}
+		case start > 0:
+			// The word runs from start to i, and is preceded by a separator.
+			words = append(words, s[start:i])
+			start = -1
+		default:
+			start = -1
+		}
+	}
+	if len(words) == 0 {
+		all := make([]int, len(c.lines))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	// Intersect the postings, from the shortest.
+	slices.SortFunc(words, func(a, b string) int { return len(c.index[a]) - len(c.index[b]) })
+	lines := c.index[words[0]]
+	for _, w := range words[1:] {
+		if len(lines) == 0 {
+			break
+		}
+		lines = intersect(lines, c.index[w])
+	}
+	return lines
+}
+
+// intersect returns the elements of both ascending slices a and b.
+func intersect(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
@@ -20,40 +20,104 @@ package shakesapp
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Mode selects how the server searches the corpus.
+type Mode int
 
-	"cloud.google.com/go/storage"
-	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
+const (
+	// Slow reads the corpus and compiles the query for every line, on
+	// every request. Profiles of this mode show where the time goes.
+	Slow Mode = iota
+	// Optimized loads the corpus once into an index, and caches compiled
+	// queries.
+	Optimized
 )
 
+func (m Mode) String() string {
+	switch m {
+	case Slow:
+		return "slow"
+	case Optimized:
+		return "optimized"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode returns the mode named s, slow or optimized.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "slow":
+		return Slow, nil
+	case "optimized":
+		return Optimized, nil
+	}
+	return 0, fmt.Errorf("unknown mode %q, want slow or optimized", s)
+}
+
+// DefaultMaxCachedQueries is the number of compiled queries an Optimized
+// server caches, if Config.MaxCachedQueries isn't set.
+const DefaultMaxCachedQueries = 1000
+
+// Config configures a server.
+type Config struct {
+	// Source is the corpus to search. It defaults to DefaultSource.
+	Source CorpusSource
+	// Mode defaults to Slow.
+	Mode Mode
+	// MaxCachedQueries is the number of compiled queries an Optimized
+	// server caches. It defaults to DefaultMaxCachedQueries.
+	MaxCachedQueries int
+}
+
 // server is an implementation of the server for ShakespeareService (defined
 // in shakesapp.proto).
-type server struct{}
+type server struct {
+	source CorpusSource
+	mode   Mode
+
+	// In Optimized mode, the corpus is loaded by the first request, and
+	// read without locking afterwards.
+	loadMu  sync.Mutex
+	corpus  atomic.Pointer[corpus]
+	regexps *regexpCache
+}
 
 // NewServer returns an implementation of the server for ShakespeareService
 // (defined in shakesapp.proto).
-func NewServer() ShakespeareServiceServer {
-	return &server{}
+func NewServer(cfg Config) ShakespeareServiceServer {
+	s := &server{source: cfg.Source, mode: cfg.Mode}
+	if s.source == nil {
+		s.source = DefaultSource
+	}
+	size := cfg.MaxCachedQueries
+	if size <= 0 {
+		size = DefaultMaxCachedQueries
+	}
+	s.regexps = &regexpCache{max: size, m: make(map[string]*regexp.Regexp)}
+	return s
 }
 
-const bucketName = "dataflow-samples"
-const bucketPrefix = "shakespeare/"
-
 // GetMatchCount implements a server for ShakespeareService.
 func (s *server) GetMatchCount(ctx context.Context, req *ShakespeareRequest) (*ShakespeareResponse, error) {
+	if s.mode == Optimized {
+		return s.getMatchCountOptimized(ctx, req)
+	}
+
 	resp := &ShakespeareResponse{}
-	texts, err := readFiles(ctx, bucketName, bucketPrefix)
+	texts, err := s.source.ReadTexts(ctx)
 	if err != nil {
 		return resp, fmt.Errorf("fails to read files: %s", err)
 	}
 	for _, text := range texts {
 		for _, line := range strings.Split(text, "\n") {
 			line, query := strings.ToLower(line), strings.ToLower(req.Query)
-			// TODO: Compiling and matching a regular expression on every request
-			// might be too expensive? Consider optimizing.
+			// Compiling and matching a regular expression on every line of
+			// every request is expensive. The Optimized mode doesn't.
 			isMatch, err := regexp.MatchString(query, line)
 			if err != nil {
 				return resp, err
@@ -66,58 +130,67 @@ func (s *server) GetMatchCount(ctx context.Context, req *ShakespeareRequest) (*S
 	return resp, nil
 }
 
-// readFiles reads the content of files within the specified bucket with the
-// specified prefix path in parallel and returns their content. It fails if
-// operations to find or read any of the files fails.
-func readFiles(ctx context.Context, bucketName, prefix string) ([]string, error) {
-	type resp struct {
-		s   string
-		err error
+func (s *server) getMatchCountOptimized(ctx context.Context, req *ShakespeareRequest) (*ShakespeareResponse, error) {
+	c, err := s.loadCorpus(ctx)
+	if err != nil {
+		return &ShakespeareResponse{}, fmt.Errorf("fails to read files: %s", err)
+	}
+	re, err := s.regexps.compile(strings.ToLower(req.Query))
+	if err != nil {
+		return &ShakespeareResponse{}, err
 	}
+	return &ShakespeareResponse{MatchCount: c.count(re)}, nil
+}
 
-	client, err := storage.NewClient(ctx, option.WithoutAuthentication())
+// loadCorpus returns the corpus, loading it if it isn't loaded yet. A
+// failed load is retried by the next request.
+func (s *server) loadCorpus(ctx context.Context) (*corpus, error) {
+	if c := s.corpus.Load(); c != nil {
+		return c, nil
+	}
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if c := s.corpus.Load(); c != nil {
+		return c, nil
+	}
+	texts, err := s.source.ReadTexts(ctx)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to create storage client: %s", err)
+		return nil, err
 	}
-	defer client.Close()
+	c := newCorpus(texts)
+	s.corpus.Store(c)
+	return c, nil
+}
 
-	bucket := client.Bucket(bucketName)
+// regexpCache caches compiled queries. When it's full, it evicts an
+// arbitrary entry.
+type regexpCache struct {
+	max int
 
-	var paths []string
-	it := bucket.Objects(ctx, &storage.Query{Prefix: bucketPrefix})
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return []string{}, fmt.Errorf("failed to iterate over files in %s starting with %s: %w", bucketName, prefix, err)
-		}
-		if attrs.Name != "" {
-			paths = append(paths, attrs.Name)
-		}
+	mu sync.RWMutex
+	m  map[string]*regexp.Regexp
+}
+
+func (c *regexpCache) compile(query string) (*regexp.Regexp, error) {
+	c.mu.RLock()
+	re, ok := c.m[query]
+	c.mu.RUnlock()
+	if ok {
+		return re, nil
 	}
 
-	resps := make(chan resp)
-	for _, path := range paths {
-		go func(path string) {
-			obj := bucket.Object(path)
-			r, err := obj.NewReader(ctx)
-			if err != nil {
-				resps <- resp{"", err}
-			}
-			defer r.Close()
-			data, err := ioutil.ReadAll(r)
-			resps <- resp{string(data), err}
-		}(path)
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, err
 	}
-	ret := make([]string, len(paths))
-	for i := 0; i < len(paths); i++ {
-		r := <-resps
-		if r.err != nil {
-			err = r.err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.m) >= c.max {
+		for k := range c.m {
+			delete(c.m, k)
+			break
 		}
-		ret[i] = r.s
 	}
-	return ret, err
+	c.m[query] = re
+	return re, nil
 }
@@ -0,0 +1,168 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shakesapp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestGetMatchCount(t *testing.T) {
+	ctx := context.Background()
+	slow := NewServer(Config{Source: DirSource("testdata/corpus"), Mode: Slow})
+	optimized := NewServer(Config{Source: DirSource("testdata/corpus"), Mode: Optimized, MaxCachedQueries: 2})
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{"hello", 2}, // Including "OTHELLO".
+		{"To be, or not to be", 2},
+		{"sleep", 5},
+		{"a sleep to", 1},
+		{"ll i comp", 1},
+		{"'tis", 3},
+		{"not to be, or not to be right", 0},
+		{"^to", 2},
+		{"s[lw]", 7},
+		{"summer'?s (day|lease)", 2},
+		{`\bthe\b`, 10},
+		{"", 35}, // Including the empty last line of each file.
+	}
+	// Run the queries twice, to use the optimized server's cache.
+	for range 2 {
+		for _, test := range tests {
+			for _, s := range []ShakespeareServiceServer{slow, optimized} {
+				resp, err := s.GetMatchCount(ctx, &ShakespeareRequest{Query: test.query})
+				if err != nil {
+					t.Errorf("%v GetMatchCount(%q): %v", s.(*server).mode, test.query, err)
+					continue
+				}
+				if resp.MatchCount != test.want {
+					t.Errorf("%v GetMatchCount(%q) = %d, want %d", s.(*server).mode, test.query, resp.MatchCount, test.want)
+				}
+			}
+		}
+	}
+	if n := len(optimized.(*server).regexps.m); n > 2 {
+		t.Errorf("optimized server caches %d queries, want at most 2", n)
+	}
+
+	for _, s := range []ShakespeareServiceServer{slow, optimized} {
+		if _, err := s.GetMatchCount(ctx, &ShakespeareRequest{Query: "(to be"}); err == nil {
+			t.Errorf("%v GetMatchCount of an invalid query succeeded, want error", s.(*server).mode)
+		}
+	}
+}
+
+// TestIndex checks that every literal query finds the same lines with
+// the index as by scanning every line.
+func TestIndex(t *testing.T) {
+	texts, err := DirSource("testdata/corpus").ReadTexts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCorpus(texts)
+	for _, line := range c.lines {
+		for i := range len(line) {
+			for j := i + 1; j <= len(line) && j <= i+20; j++ {
+				s := line[i:j]
+				var want int64
+				for _, l := range c.lines {
+					if strings.Contains(l, s) {
+						want++
+					}
+				}
+				if got := c.countLiteral(s); got != want {
+					t.Fatalf("countLiteral(%q) = %d, want %d", s, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		location string
+		want     CorpusSource
+		wantErr  bool
+	}{
+		{location: "gs://dataflow-samples/shakespeare/", want: DefaultSource},
+		{location: "gs://my-bucket", want: BucketSource{Bucket: "my-bucket"}},
+		{location: "testdata/corpus", want: DirSource("testdata/corpus")},
+		{location: "gs://", wantErr: true},
+		{location: "testdata/missing", wantErr: true},
+		{location: "testdata/corpus/hamlet.txt", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSource(test.location)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseSource(%q) = %v, %v; want %v, error %v", test.location, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestSimulateClient(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	RegisterShakespeareServiceServer(s, NewServer(Config{Source: DirSource("testdata/corpus"), Mode: Optimized}))
+	go s.Serve(lis)
+	defer s.Stop()
+
+	ctx := context.Background()
+	queries := []Query{{"hello", 2}, {"sleep", 5}, {"summer", -1}}
+	stats, err := SimulateClient(ctx, lis.Addr().String(), 50, 4, queries)
+	if err != nil {
+		t.Fatalf("SimulateClient: %v", err)
+	}
+	if stats.Requests != 50 || stats.Errors != 0 {
+		t.Errorf("SimulateClient stats = %v, want 50 requests and no errors", stats)
+	}
+	if !(0 < stats.P50 && stats.P50 <= stats.P90 && stats.P90 <= stats.P99 && stats.P99 <= stats.Max) {
+		t.Errorf("SimulateClient latencies aren't ordered: %v", stats)
+	}
+
+	stats, err = SimulateClient(ctx, lis.Addr().String(), 10, 2, []Query{{"hello", 3}})
+	if err == nil || stats.Errors != 10 {
+		t.Errorf("SimulateClient with wrong counts = %v, %v; want 10 errors", stats, err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 200; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{0: time.Millisecond, 50: 100 * time.Millisecond, 99: 198 * time.Millisecond, 100: 200 * time.Millisecond} {
+		if got := percentile(latencies, p); got != want {
+			t.Errorf("percentile(1ms..200ms, %v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of nothing = %v, want 0", got)
+	}
+	if got := fmt.Sprint(Stats{Requests: 1, P50: time.Second}); !strings.Contains(got, "p50 1s") {
+		t.Errorf("Stats.String() = %q", got)
+	}
+}
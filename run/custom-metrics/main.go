@@ -21,6 +21,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/metric"
@@ -32,9 +35,7 @@ import (
 var counter metric.Int64Counter
 
 func main() {
-	ctx := context.Background()
-	shutdown := setupCounter(ctx)
-	defer shutdown(ctx)
+	shutdown := setupCounter(context.Background())
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -43,7 +44,30 @@ func main() {
 	}
 
 	http.HandleFunc("/", handler)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	srv := &http.Server{Addr: ":" + port}
+
+	// Shut down on SIGTERM, which Cloud Run sends before stopping an
+	// instance, or SIGINT, which Ctrl+C sends locally.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+	stop()
+
+	// Finish in-flight requests, then flush buffered metrics to the
+	// collector.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown failed: %v", err)
+	}
+	if err := shutdown(shutdownCtx); err != nil {
+		log.Printf("flushing metrics: %v", err)
+	}
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
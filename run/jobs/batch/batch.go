@@ -0,0 +1,301 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batch runs batch jobs as Cloud Run jobs.
+//
+// A Cloud Run job execution runs CLOUD_RUN_TASK_COUNT copies of a
+// container, each with its own CLOUD_RUN_TASK_INDEX, and retries tasks
+// that fail. Job.RunTask splits the job's input into one shard per task,
+// processes the task's shard and merges the results of its items. It
+// checkpoints its progress to a Store as it goes, so a retried attempt
+// resumes where the failed one stopped instead of starting over, and
+// writes the task's result when it's done. The last task to finish
+// merges the results of all tasks into the job's result.
+//
+// Job.RunLocal runs the tasks of a job as goroutines, retrying failed
+// ones, for running jobs locally and in tests.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// Task identifies a task of a job execution.
+type Task struct {
+	// Index is the task's index, from 0 to Count-1.
+	Index int
+	// Count is the number of tasks in the execution.
+	Count int
+	// Attempt counts the times the task has been retried, from 0.
+	Attempt int
+}
+
+func (t Task) String() string {
+	return fmt.Sprintf("Task #%d of %d, Attempt #%d", t.Index, t.Count, t.Attempt)
+}
+
+// TaskFromEnv returns the task Cloud Run is running, from the
+// CLOUD_RUN_TASK_INDEX, CLOUD_RUN_TASK_COUNT and CLOUD_RUN_TASK_ATTEMPT
+// environment variables. Unset variables describe the only task of a
+// single-task execution.
+func TaskFromEnv() (Task, error) {
+	t := Task{Count: 1}
+	for _, v := range []struct {
+		name string
+		dst  *int
+	}{
+		{"CLOUD_RUN_TASK_INDEX", &t.Index},
+		{"CLOUD_RUN_TASK_COUNT", &t.Count},
+		{"CLOUD_RUN_TASK_ATTEMPT", &t.Attempt},
+	} {
+		s := os.Getenv(v.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return Task{}, fmt.Errorf("%s: %w", v.name, err)
+		}
+		*v.dst = n
+	}
+	if t.Count < 1 || t.Index < 0 || t.Index >= t.Count || t.Attempt < 0 {
+		return Task{}, fmt.Errorf("invalid task: %v", t)
+	}
+	return t, nil
+}
+
+// DefaultCheckpointEvery is how often, in items, tasks checkpoint when
+// Job.CheckpointEvery isn't set.
+const DefaultCheckpointEvery = 10
+
+// Job is a batch job whose items produce results of type R, which must
+// round-trip through encoding/json.
+type Job[R any] struct {
+	// Source lists the job's input.
+	Source Source
+
+	// Store holds checkpoints and results, under Prefix. Give each job
+	// execution its own prefix, for example by including the
+	// CLOUD_RUN_EXECUTION environment variable, so that executions don't
+	// resume from each other's checkpoints.
+	Store  Store
+	Prefix string
+
+	// Process processes an item.
+	Process func(ctx context.Context, item Item) (R, error)
+
+	// Merge merges the result of an item, or of a task, into acc and
+	// returns the merged result. The zero R is the result of no items.
+	Merge func(acc, r R) R
+
+	// CheckpointEvery is how often, in items, a task checkpoints. It
+	// defaults to DefaultCheckpointEvery. Items processed after the last
+	// checkpoint are processed again when a task is retried.
+	CheckpointEvery int
+
+	// Logf logs progress. It defaults to log.Printf.
+	Logf func(format string, args ...any)
+}
+
+// checkpoint records a task's progress through its shard.
+type checkpoint[R any] struct {
+	// Count is the number of tasks, which determines the shard.
+	Count int `json:"count"`
+	// Done is the number of items of the shard processed.
+	Done int `json:"done"`
+	// Result is the merged result of those items.
+	Result R `json:"result"`
+}
+
+func (j *Job[R]) checkpointName(t Task) string {
+	return path.Join(j.Prefix, "checkpoints", fmt.Sprintf("task-%05d.json", t.Index))
+}
+
+func (j *Job[R]) resultName(index int) string {
+	return path.Join(j.Prefix, "results", fmt.Sprintf("task-%05d.json", index))
+}
+
+// ResultName is the name of the object holding the job's result.
+func (j *Job[R]) ResultName() string {
+	return path.Join(j.Prefix, "result.json")
+}
+
+// RunTask processes task t's shard of the input, resuming from its last
+// checkpoint, and writes the task's result. If all tasks have finished,
+// it also writes the job's result, with Aggregate. It returns the task's
+// result.
+func (j *Job[R]) RunTask(ctx context.Context, t Task) (R, error) {
+	var zero R
+	items, err := j.Source.List(ctx)
+	if err != nil {
+		return zero, fmt.Errorf("listing input: %w", err)
+	}
+	shard := Shard(items, t.Index, t.Count)
+
+	// A task that finished, but whose attempt failed afterwards, doesn't
+	// need to run again.
+	var done checkpoint[R]
+	if ok, err := j.read(ctx, j.resultName(t.Index), &done); err != nil {
+		return zero, err
+	} else if ok && done.Count == t.Count {
+		j.logf("%v: already done", t)
+		return done.Result, j.aggregateIfDone(ctx, t.Count)
+	}
+
+	var cp checkpoint[R]
+	if ok, err := j.read(ctx, j.checkpointName(t), &cp); err != nil {
+		return zero, err
+	} else if ok && cp.Count == t.Count && cp.Done <= len(shard) {
+		j.logf("%v: resuming after %d of %d items", t, cp.Done, len(shard))
+	} else {
+		cp = checkpoint[R]{Count: t.Count}
+		j.logf("%v: starting %d items", t, len(shard))
+	}
+
+	every := j.CheckpointEvery
+	if every <= 0 {
+		every = DefaultCheckpointEvery
+	}
+	for cp.Done < len(shard) {
+		item := shard[cp.Done]
+		r, err := j.Process(ctx, item)
+		if err != nil {
+			return zero, fmt.Errorf("processing %s: %w", item.Key, err)
+		}
+		cp.Result = j.Merge(cp.Result, r)
+		cp.Done++
+		if cp.Done%every == 0 && cp.Done < len(shard) {
+			if err := j.write(ctx, j.checkpointName(t), cp); err != nil {
+				return zero, fmt.Errorf("checkpointing: %w", err)
+			}
+		}
+	}
+
+	if err := j.write(ctx, j.resultName(t.Index), cp); err != nil {
+		return zero, fmt.Errorf("writing result: %w", err)
+	}
+	j.logf("%v: completed %d items", t, len(shard))
+	return cp.Result, j.aggregateIfDone(ctx, t.Count)
+}
+
+// ErrIncomplete is returned by Aggregate if some tasks haven't finished.
+var ErrIncomplete = errors.New("not all tasks have finished")
+
+// Aggregate merges the results of the count tasks of the job, and writes
+// the merged result to ResultName. It returns an error wrapping
+// ErrIncomplete if some of the tasks haven't finished.
+func (j *Job[R]) Aggregate(ctx context.Context, count int) (R, error) {
+	var acc R
+	for i := range count {
+		var res checkpoint[R]
+		ok, err := j.read(ctx, j.resultName(i), &res)
+		if err != nil {
+			return acc, err
+		}
+		if !ok || res.Count != count {
+			return acc, fmt.Errorf("task %d: %w", i, ErrIncomplete)
+		}
+		acc = j.Merge(acc, res.Result)
+	}
+	if err := j.write(ctx, j.ResultName(), acc); err != nil {
+		return acc, fmt.Errorf("writing job result: %w", err)
+	}
+	return acc, nil
+}
+
+// aggregateIfDone aggregates the job's result if every task has finished.
+// Tasks finishing at the same time may both aggregate, which is harmless,
+// since they write the same result.
+func (j *Job[R]) aggregateIfDone(ctx context.Context, count int) error {
+	_, err := j.Aggregate(ctx, count)
+	if errors.Is(err, ErrIncomplete) {
+		return nil
+	}
+	if err == nil {
+		j.logf("all %d tasks done, wrote %s", count, j.ResultName())
+	}
+	return err
+}
+
+// RunLocal runs count tasks as goroutines, like a Cloud Run job execution
+// with --tasks=count and --max-retries=maxRetries, and returns the job's
+// result. It returns the errors of the tasks whose last attempt failed.
+func (j *Job[R]) RunLocal(ctx context.Context, count, maxRetries int) (R, error) {
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 0; attempt <= maxRetries; attempt++ {
+				t := Task{Index: i, Count: count, Attempt: attempt}
+				_, err := j.RunTask(ctx, t)
+				if err == nil {
+					errs[i] = nil
+					return
+				}
+				j.logf("%v failed: %v", t, err)
+				errs[i] = fmt.Errorf("task %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		var zero R
+		return zero, err
+	}
+	var acc R
+	_, err := j.read(ctx, j.ResultName(), &acc)
+	return acc, err
+}
+
+// read decodes the JSON object name into v, reporting false if it
+// doesn't exist.
+func (j *Job[R]) read(ctx context.Context, name string, v any) (bool, error) {
+	b, err := j.Store.Read(ctx, name)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (j *Job[R]) write(ctx context.Context, name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return j.Store.Write(ctx, name, b)
+}
+
+func (j *Job[R]) logf(format string, args ...any) {
+	if j.Logf != nil {
+		j.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
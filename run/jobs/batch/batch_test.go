@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sumJob returns a job summing the numbers in a manifest of n rows, 1 to
+// n, in store. fail is called before processing each item, and fails it
+// if it returns an error.
+func sumJob(t *testing.T, store Store, n int, fail func(row int) error) *Job[int] {
+	t.Helper()
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	if err := store.Write(context.Background(), "input/manifest.jsonl", []byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	return &Job[int]{
+		Source: Manifest{Store: store, Name: "input/manifest.jsonl"},
+		Store:  store,
+		Prefix: "output/exec-1",
+		Process: func(ctx context.Context, item Item) (int, error) {
+			var row int
+			if err := json.Unmarshal(item.Data, &row); err != nil {
+				return 0, err
+			}
+			if err := fail(row); err != nil {
+				return 0, err
+			}
+			return row, nil
+		},
+		Merge:           func(acc, r int) int { return acc + r },
+		CheckpointEvery: 5,
+		Logf:            t.Logf,
+	}
+}
+
+func TestShard(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 10, 101} {
+		for _, count := range []int{1, 3, 10, 16} {
+			var items []Item
+			for i := range n {
+				items = append(items, Item{Key: fmt.Sprint(i)})
+			}
+			var got []Item
+			for index := range count {
+				s := Shard(items, index, count)
+				if len(s) > n/count+1 {
+					t.Errorf("Shard(%d items, %d, %d) has %d items, want at most %d", n, index, count, len(s), n/count+1)
+				}
+				got = append(got, s...)
+			}
+			if !slices.EqualFunc(got, items, func(a, b Item) bool { return a.Key == b.Key }) {
+				t.Errorf("shards of %d items for %d tasks don't cover the items once, in order", n, count)
+			}
+		}
+	}
+}
+
+func TestTaskFromEnv(t *testing.T) {
+	tests := []struct {
+		index, count, attempt string
+		want                  Task
+		wantErr               bool
+	}{
+		{want: Task{Count: 1}},
+		{index: "2", count: "3", attempt: "1", want: Task{Index: 2, Count: 3, Attempt: 1}},
+		{index: "3", count: "3", wantErr: true},
+		{index: "x", wantErr: true},
+		{count: "0", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Setenv("CLOUD_RUN_TASK_INDEX", test.index)
+		t.Setenv("CLOUD_RUN_TASK_COUNT", test.count)
+		t.Setenv("CLOUD_RUN_TASK_ATTEMPT", test.attempt)
+		got, err := TaskFromEnv()
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("TaskFromEnv with index %q, count %q, attempt %q = %v, %v; want %v, error %v",
+				test.index, test.count, test.attempt, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestRunTaskResumes(t *testing.T) {
+	ctx := context.Background()
+	store := DirStore(t.TempDir())
+
+	var processed []int
+	failAt := 13
+	job := sumJob(t, store, 20, func(row int) error {
+		if row == failAt {
+			failAt = 0 // Only fail once.
+			return errors.New("boom")
+		}
+		processed = append(processed, row)
+		return nil
+	})
+
+	if _, err := job.RunTask(ctx, Task{Index: 0, Count: 1}); err == nil {
+		t.Fatal("first attempt succeeded, want error")
+	}
+	processed = nil
+	got, err := job.RunTask(ctx, Task{Index: 0, Count: 1, Attempt: 1})
+	if err != nil {
+		t.Fatalf("second attempt: %v", err)
+	}
+	if want := 210; got != want {
+		t.Errorf("second attempt result = %d, want %d", got, want)
+	}
+	// The checkpoint after 10 items lets the retry skip them.
+	if want := []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}; !slices.Equal(processed, want) {
+		t.Errorf("second attempt processed %v, want %v", processed, want)
+	}
+
+	// Running the task again after it finished does nothing.
+	processed = nil
+	if got, err := job.RunTask(ctx, Task{Index: 0, Count: 1, Attempt: 2}); err != nil || got != 210 {
+		t.Errorf("third attempt = %d, %v; want 210, nil", got, err)
+	}
+	if len(processed) != 0 {
+		t.Errorf("third attempt processed %v, want nothing", processed)
+	}
+
+	b, err := store.Read(ctx, job.ResultName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "210" {
+		t.Errorf("%s = %s, want 210", job.ResultName(), b)
+	}
+}
+
+func TestAggregateIncomplete(t *testing.T) {
+	ctx := context.Background()
+	job := sumJob(t, DirStore(t.TempDir()), 10, func(int) error { return nil })
+
+	if _, err := job.RunTask(ctx, Task{Index: 0, Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Aggregate(ctx, 2); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Aggregate with one of two tasks done = %v, want %v", err, ErrIncomplete)
+	}
+	if _, err := job.Store.Read(ctx, job.ResultName()); !errors.Is(err, ErrNotExist) {
+		t.Errorf("job result written before all tasks finished")
+	}
+
+	// The last task to finish aggregates.
+	if _, err := job.RunTask(ctx, Task{Index: 1, Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := job.Store.Read(ctx, job.ResultName())
+	if err != nil || string(b) != "55" {
+		t.Errorf("job result = %s, %v; want 55", b, err)
+	}
+}
+
+func TestRunLocal(t *testing.T) {
+	ctx := context.Background()
+
+	// Every row divisible by 7 fails the first time it's processed, so
+	// every task needs to be retried, up to four times.
+	var mu sync.Mutex
+	failed := make(map[int]bool)
+	job := sumJob(t, DirStore(t.TempDir()), 100, func(row int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if row%7 == 0 && !failed[row] {
+			failed[row] = true
+			return fmt.Errorf("row %d failed", row)
+		}
+		return nil
+	})
+
+	got, err := job.RunLocal(ctx, 4, 5)
+	if err != nil {
+		t.Fatalf("RunLocal: %v", err)
+	}
+	if want := 5050; got != want {
+		t.Errorf("RunLocal = %d, want %d: items were skipped or merged twice", got, want)
+	}
+
+	// Without enough retries, the job fails.
+	clear(failed)
+	job.Prefix = "output/exec-2"
+	if _, err := job.RunLocal(ctx, 4, 0); err == nil {
+		t.Errorf("RunLocal without retries succeeded, want error")
+	}
+}
+
+func TestSources(t *testing.T) {
+	ctx := context.Background()
+	store := DirStore(t.TempDir())
+	for _, name := range []string{"docs/b.txt", "docs/a.txt", "docs/sub/c.txt", "other.txt"} {
+		if err := store.Write(ctx, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := Objects{Store: store, Prefix: "docs/"}.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, it := range items {
+		keys = append(keys, it.Key)
+	}
+	if want := []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt"}; !slices.Equal(keys, want) {
+		t.Errorf("Objects.List = %q, want %q", keys, want)
+	}
+
+	if err := store.Write(ctx, "m.jsonl", []byte("{\"a\":1}\n\n{\"a\":2}\n")); err != nil {
+		t.Fatal(err)
+	}
+	items, err = Manifest{Store: store, Name: "m.jsonl"}.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{{Key: "m.jsonl:1", Data: json.RawMessage(`{"a":1}`)}, {Key: "m.jsonl:3", Data: json.RawMessage(`{"a":2}`)}}
+	if len(items) != len(want) {
+		t.Fatalf("Manifest.List = %d items, want %d", len(items), len(want))
+	}
+	for i := range want {
+		if items[i].Key != want[i].Key || string(items[i].Data) != string(want[i].Data) {
+			t.Errorf("Manifest.List item %d = %s %s, want %s %s", i, items[i].Key, items[i].Data, want[i].Key, want[i].Data)
+		}
+	}
+
+	if err := store.Write(ctx, "bad.jsonl", []byte("{\"a\":1}\nnot json\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Manifest{Store: store, Name: "bad.jsonl"}).List(ctx); err == nil {
+		t.Errorf("Manifest.List with an invalid row succeeded, want error")
+	}
+	if _, err := (Manifest{Store: store, Name: "missing.jsonl"}).List(ctx); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Manifest.List of a missing manifest = %v, want %v", err, ErrNotExist)
+	}
+}
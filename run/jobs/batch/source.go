@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Item is a unit of work in a job's input.
+type Item struct {
+	// Key identifies the item: the object name for objects under a
+	// prefix, or the manifest name and line number for manifest rows.
+	Key string
+	// Data is the row for manifest rows, or nil for objects, which Process
+	// reads from the store itself.
+	Data json.RawMessage
+}
+
+// Source lists a job's input. Every task lists the whole input and takes
+// its shard, so List must return the same items in the same order every
+// time it's called during a job execution.
+type Source interface {
+	List(ctx context.Context) ([]Item, error)
+}
+
+// Objects is a Source listing the objects under Prefix in Store, in
+// name order.
+type Objects struct {
+	Store  Store
+	Prefix string
+}
+
+// List implements Source.
+func (o Objects) List(ctx context.Context) ([]Item, error) {
+	names, err := o.Store.List(ctx, o.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, len(names))
+	for i, name := range names {
+		items[i] = Item{Key: name}
+	}
+	return items, nil
+}
+
+// Manifest is a Source listing the rows of the JSONL object Name in Store,
+// one JSON value per line. Blank lines are skipped.
+type Manifest struct {
+	Store Store
+	Name  string
+}
+
+// List implements Source.
+func (m Manifest) List(ctx context.Context) ([]Item, error) {
+	b, err := m.Store.Read(ctx, m.Name)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for i, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("%s:%d: invalid JSON", m.Name, i+1)
+		}
+		items = append(items, Item{Key: fmt.Sprintf("%s:%d", m.Name, i+1), Data: line})
+	}
+	return items, nil
+}
+
+// Shard returns the items task index of count processes: a contiguous
+// run of about len(items)/count items. Together, the shards of tasks 0 to
+// count-1 cover every item exactly once.
+func Shard(items []Item, index, count int) []Item {
+	n := len(items)
+	return items[index*n/count : (index+1)*n/count]
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ErrNotExist is returned by Store.Read for objects that don't exist.
+var ErrNotExist = errors.New("object does not exist")
+
+// Store is an object store holding a job's input, checkpoints and
+// results. Object names use "/" as a separator.
+type Store interface {
+	// List returns the names of the objects starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Read returns the contents of an object, or an error wrapping
+	// ErrNotExist if there is no such object.
+	Read(ctx context.Context, name string) ([]byte, error)
+	// Write creates or replaces an object. Readers see either the old or
+	// the new contents, never a partial write.
+	Write(ctx context.Context, name string, data []byte) error
+}
+
+// OpenStore returns the Store for a location given as gs://BUCKET for a
+// Cloud Storage bucket, or as a local directory. The returned close
+// function releases the resources the store uses.
+func OpenStore(ctx context.Context, location string) (s Store, close func() error, err error) {
+	bucket, ok := strings.CutPrefix(location, "gs://")
+	if !ok {
+		return DirStore(location), func() error { return nil }, nil
+	}
+	bucket = strings.TrimSuffix(bucket, "/")
+	if bucket == "" || strings.Contains(bucket, "/") {
+		return nil, nil, fmt.Errorf("%q isn't gs://BUCKET", location)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
+	return &GCSStore{Bucket: client.Bucket(bucket)}, client.Close, nil
+}
+
+// DirStore is a Store keeping objects as files under a local directory,
+// for running jobs locally.
+type DirStore string
+
+func (d DirStore) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+// List implements Store.
+func (d DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(string(d), func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	slices.Sort(names)
+	return names, err
+}
+
+// Read implements Store.
+func (d DirStore) Read(ctx context.Context, name string) ([]byte, error) {
+	b, err := os.ReadFile(d.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotExist)
+	}
+	return b, err
+}
+
+// Write implements Store. It writes to a temporary file and renames it,
+// so a crash doesn't leave a partial object.
+func (d DirStore) Write(ctx context.Context, name string, data []byte) error {
+	path := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// GCSStore is a Store keeping objects in a Cloud Storage bucket.
+type GCSStore struct {
+	Bucket *storage.BucketHandle
+}
+
+// List implements Store.
+func (s *GCSStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	it := s.Bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Bucket.Objects(%q): %w", prefix, err)
+		}
+		names = append(names, attrs.Name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Read implements Store.
+func (s *GCSStore) Read(ctx context.Context, name string) ([]byte, error) {
+	r, err := s.Bucket.Object(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Object(%q).NewReader: %w", name, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Write implements Store. Cloud Storage only makes an object visible once
+// it's completely written.
+func (s *GCSStore) Write(ctx context.Context, name string, data []byte) error {
+	w := s.Bucket.Object(name).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("Object(%q).NewWriter: %w", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Object(%q).Close: %w", name, err)
+	}
+	return nil
+}
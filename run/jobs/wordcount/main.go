@@ -0,0 +1,218 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command wordcount is a Cloud Run job that counts the words in a set of
+// documents, split across the job's tasks.
+//
+// The documents are the objects under a prefix, or the rows of a JSONL
+// manifest with a "text" field. Tasks checkpoint their progress, so a
+// retried task resumes where it failed, and the last task to finish writes
+// the word counts of all documents to result.json.
+//
+// It's configured by environment variables:
+//
+//   - INPUT: the documents, as gs://BUCKET/PREFIX/ or a local directory
+//     for objects under a prefix, or as gs://BUCKET/NAME.jsonl or a local
+//     .jsonl file for a manifest.
+//   - OUTPUT: where to write checkpoints and results, as gs://BUCKET/PREFIX
+//     or a local directory. Each execution writes under its own
+//     subdirectory, named after CLOUD_RUN_EXECUTION.
+//   - FAIL_RATE: the probability that processing a document fails, to try
+//     out retries. It defaults to 0.
+//
+// Run it locally with -tasks to simulate a job execution with that many
+// tasks.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/GoogleCloudPlatform/golang-samples/run/jobs/batch"
+)
+
+// Counts is the result of counting words in some documents.
+type Counts struct {
+	Documents int            `json:"documents"`
+	Words     map[string]int `json:"words"`
+}
+
+// merge adds the counts of r to acc.
+func merge(acc, r Counts) Counts {
+	if acc.Words == nil {
+		acc.Words = make(map[string]int)
+	}
+	acc.Documents += r.Documents
+	for w, n := range r.Words {
+		acc.Words[w] += n
+	}
+	return acc
+}
+
+// count counts the words in text, ignoring case and punctuation.
+func count(text string) Counts {
+	c := Counts{Documents: 1, Words: make(map[string]int)}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+	for _, w := range words {
+		if w = strings.Trim(w, "'"); w != "" {
+			c.Words[w]++
+		}
+	}
+	return c
+}
+
+// openLocation opens the store holding location, and returns the name of
+// location in it: a prefix for directories, or an object name.
+func openLocation(ctx context.Context, location string) (batch.Store, string, func() error, error) {
+	if rest, ok := strings.CutPrefix(location, "gs://"); ok {
+		bucket, name, _ := strings.Cut(rest, "/")
+		s, closeStore, err := batch.OpenStore(ctx, "gs://"+bucket)
+		return s, name, closeStore, err
+	}
+	noop := func() error { return nil }
+	if fi, err := os.Stat(location); err == nil && !fi.IsDir() {
+		return batch.DirStore(filepath.Dir(location)), filepath.Base(location), noop, nil
+	}
+	return batch.DirStore(location), "", noop, nil
+}
+
+// newJob returns the word count job configured by the environment.
+func newJob(ctx context.Context, execution string) (*batch.Job[Counts], func() error, error) {
+	failRate := 0.0
+	if s := os.Getenv("FAIL_RATE"); s != "" {
+		var err error
+		if failRate, err = strconv.ParseFloat(s, 64); err != nil || failRate < 0 || failRate > 1 {
+			return nil, nil, fmt.Errorf("invalid FAIL_RATE %q: must be a number between 0 and 1", s)
+		}
+	}
+	input, output := os.Getenv("INPUT"), os.Getenv("OUTPUT")
+	if input == "" || output == "" {
+		return nil, nil, fmt.Errorf("set INPUT and OUTPUT")
+	}
+
+	in, inName, closeIn, err := openLocation(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("INPUT: %w", err)
+	}
+	out, outName, closeOut, err := openLocation(ctx, output)
+	if err != nil {
+		closeIn()
+		return nil, nil, fmt.Errorf("OUTPUT: %w", err)
+	}
+	closeAll := func() error {
+		err := closeIn()
+		if err2 := closeOut(); err == nil {
+			err = err2
+		}
+		return err
+	}
+
+	var source batch.Source = batch.Objects{Store: in, Prefix: inName}
+	if strings.HasSuffix(inName, ".jsonl") {
+		source = batch.Manifest{Store: in, Name: inName}
+	}
+
+	job := &batch.Job[Counts]{
+		Source: source,
+		Store:  out,
+		Prefix: strings.TrimPrefix(outName+"/"+execution, "/"),
+		Merge:  merge,
+		Process: func(ctx context.Context, item batch.Item) (Counts, error) {
+			if failRate > 0 && rand.Float64() < failRate {
+				return Counts{}, fmt.Errorf("simulated failure")
+			}
+			if item.Data != nil {
+				var row struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(item.Data, &row); err != nil {
+					return Counts{}, err
+				}
+				return count(row.Text), nil
+			}
+			b, err := in.Read(ctx, item.Key)
+			if err != nil {
+				return Counts{}, err
+			}
+			return count(string(b)), nil
+		},
+	}
+	return job, closeAll, nil
+}
+
+// top returns the n most frequent words, most frequent first.
+func top(c Counts, n int) []string {
+	words := slices.Collect(maps.Keys(c.Words))
+	slices.SortFunc(words, func(a, b string) int {
+		if d := c.Words[b] - c.Words[a]; d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+	return words[:min(n, len(words))]
+}
+
+func main() {
+	tasks := flag.Int("tasks", 0, "run locally with this many tasks in parallel, instead of as a Cloud Run job task")
+	retries := flag.Int("max-retries", 3, "with -tasks, the number of times to retry failed tasks")
+	flag.Parse()
+
+	ctx := context.Background()
+	execution := os.Getenv("CLOUD_RUN_EXECUTION")
+	if execution == "" {
+		execution = "local"
+	}
+	job, closeStores, err := newJob(ctx, execution)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeStores()
+
+	if *tasks > 0 {
+		counts, err := job.RunLocal(ctx, *tasks, *retries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d documents, %d distinct words\n", counts.Documents, len(counts.Words))
+		for _, w := range top(counts, 10) {
+			fmt.Printf("%6d %s\n", counts.Words[w], w)
+		}
+		return
+	}
+
+	t, err := batch.TaskFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Starting %v ...", t)
+	if _, err := job.RunTask(ctx, t); err != nil {
+		// Exit with an error, so Cloud Run retries the task.
+		closeStores()
+		log.Fatalf("%v failed: %v", t, err)
+	}
+	log.Printf("Completed %v", t)
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	got := count("It's the cat's turn -- the CAT'S, 'quoted' 42!")
+	want := map[string]int{"it's": 1, "the": 2, "cat's": 2, "turn": 1, "quoted": 1, "42": 1}
+	if len(got.Words) != len(want) || got.Documents != 1 {
+		t.Fatalf("count = %v, want %v in 1 document", got, want)
+	}
+	for w, n := range want {
+		if got.Words[w] != n {
+			t.Errorf("count[%q] = %d, want %d", w, got.Words[w], n)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	for _, input := range []string{"testdata/books/", "testdata/manifest.jsonl"} {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			ctx := context.Background()
+			output := t.TempDir()
+			t.Setenv("INPUT", input)
+			t.Setenv("OUTPUT", output)
+			// Fail a third of the documents, to exercise retries.
+			t.Setenv("FAIL_RATE", "0.3")
+
+			job, closeStores, err := newJob(ctx, "exec-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeStores()
+			job.Logf = t.Logf
+
+			got, err := job.RunLocal(ctx, 3, 50)
+			if err != nil {
+				t.Fatalf("RunLocal: %v", err)
+			}
+			if got.Documents != 4 {
+				t.Errorf("counted %d documents, want 4", got.Documents)
+			}
+			if got.Words["the"] != 5 || got.Words["fox"] != 3 || got.Words["dog"] != 3 {
+				t.Errorf("counted the: %d, fox: %d, dog: %d; want 5, 3, 3", got.Words["the"], got.Words["fox"], got.Words["dog"])
+			}
+			if want := []string{"the", "a", "dog"}; !slices.Equal(top(got, 3), want) {
+				t.Errorf("top 3 words = %q, want %q", top(got, 3), want)
+			}
+
+			b, err := os.ReadFile(filepath.Join(output, "exec-1", "result.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written Counts
+			if err := json.Unmarshal(b, &written); err != nil {
+				t.Fatal(err)
+			}
+			if written.Documents != got.Documents || written.Words["the"] != got.Words["the"] {
+				t.Errorf("result.json = %+v, want %+v", written, got)
+			}
+		})
+	}
+}
+
+func TestNewJobErrors(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"no input":      {"INPUT": "", "OUTPUT": "out"},
+		"bad fail rate": {"INPUT": "in", "OUTPUT": "out", "FAIL_RATE": "2"},
+		"bad bucket":    {"INPUT": "gs://", "OUTPUT": "out"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if _, _, err := newJob(context.Background(), "exec-1"); err == nil {
+				t.Errorf("newJob succeeded, want error")
+			}
+		})
+	}
+}
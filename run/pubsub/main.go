@@ -18,15 +18,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+
+	"google.golang.org/api/idtoken"
 )
 
 func main() {
-	http.HandleFunc("/", HelloPubSub)
+	http.Handle("/", newHandler())
 	// Determine port for HTTP service.
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -40,6 +45,61 @@ func main() {
 	}
 }
 
+// validateToken validates the push subscription's OIDC tokens. Tests
+// replace it.
+var validateToken = idtoken.Validate
+
+// newHandler returns HelloPubSub. When PUBSUB_AUDIENCE is set, it only
+// accepts push requests carrying an OIDC token for that audience and, if
+// PUBSUB_SERVICE_ACCOUNT is set, issued to that service account. Configure
+// the push subscription with --push-auth-service-account and
+// --push-auth-token-audience to send them.
+//
+// Without PUBSUB_AUDIENCE, requests aren't authenticated, so deploy the
+// service with --no-allow-unauthenticated to let Cloud Run check them
+// instead.
+func newHandler() http.Handler {
+	h := http.HandlerFunc(HelloPubSub)
+	aud := os.Getenv("PUBSUB_AUDIENCE")
+	if aud == "" {
+		return h
+	}
+	log.Printf("Accepting push requests with OIDC tokens for audience %s", aud)
+	email := os.Getenv("PUBSUB_SERVICE_ACCOUNT")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifyToken(r.Context(), r.Header.Get("Authorization"), aud, email); err != nil {
+			log.Printf("Rejected push request: %v", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	})
+}
+
+// verifyToken validates the bearer token in an Authorization header for
+// audience and, if email isn't empty, checks it was issued to that verified
+// service account.
+func verifyToken(ctx context.Context, authorization, audience, email string) error {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return fmt.Errorf("no bearer token")
+	}
+	p, err := validateToken(ctx, token, audience)
+	if err != nil {
+		return err
+	}
+	if email == "" {
+		return nil
+	}
+	if got, _ := p.Claims["email"].(string); got != email {
+		return fmt.Errorf("token issued to %q, want %q", got, email)
+	}
+	if verified, _ := p.Claims["email_verified"].(bool); !verified {
+		return fmt.Errorf("email %q not verified", email)
+	}
+	return nil
+}
+
 // [END cloudrun_pubsub_server]
 
 // [START cloudrun_pubsub_handler]
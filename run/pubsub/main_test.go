@@ -15,7 +15,9 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -24,6 +26,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"google.golang.org/api/idtoken"
 )
 
 func TestHelloPubSubErrors(t *testing.T) {
@@ -95,3 +99,60 @@ func TestHelloPubSub(t *testing.T) {
 		}
 	}
 }
+
+const (
+	testAudience = "https://pubsub.example.run.app/"
+	testPusher   = "pusher@my-project.iam.gserviceaccount.com"
+)
+
+// fakeValidate accepts the token "valid-<email>" for testAudience.
+func fakeValidate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	email, ok := strings.CutPrefix(token, "valid-")
+	if !ok || audience != testAudience {
+		return nil, errors.New("idtoken: invalid token")
+	}
+	return &idtoken.Payload{
+		Audience: audience,
+		Claims:   map[string]interface{}{"email": email, "email_verified": true},
+	}, nil
+}
+
+func TestNewHandlerOIDC(t *testing.T) {
+	old := validateToken
+	validateToken = fakeValidate
+	defer func() { validateToken = old }()
+	t.Setenv("PUBSUB_AUDIENCE", testAudience)
+	t.Setenv("PUBSUB_SERVICE_ACCOUNT", testPusher)
+	h := newHandler()
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "push service account", token: "valid-" + testPusher, want: http.StatusOK},
+		{name: "other service account", token: "valid-other@my-project.iam.gserviceaccount.com", want: http.StatusUnauthorized},
+		{name: "invalid token", token: "forged", want: http.StatusUnauthorized},
+		{name: "no token", want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"message":{"data":"R28=","id":"test-123"}}`))
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, rr.Code, test.want)
+		}
+	}
+}
+
+func TestNewHandlerUnauthenticated(t *testing.T) {
+	t.Setenv("PUBSUB_AUDIENCE", "")
+	rr := httptest.NewRecorder()
+	newHandler().ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("status %d, want 200 without PUBSUB_AUDIENCE", rr.Code)
+	}
+}
@@ -27,9 +27,6 @@ import (
 	"time"
 )
 
-// Create channel to listen for signals.
-var signalChan chan (os.Signal) = make(chan os.Signal, 1)
-
 func main() {
 	// Determine port for HTTP service.
 	port := os.Getenv("PORT")
@@ -45,7 +42,8 @@ func main() {
 
 	// SIGINT handles Ctrl+C locally.
 	// SIGTERM handles Cloud Run termination signal.
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Start HTTP server.
 	go func() {
@@ -55,32 +53,52 @@ func main() {
 		}
 	}()
 
-	// Receive output from signalChan.
-	sig := <-signalChan
-	log.Printf("%s signal caught", sig)
+	// Wait for a signal, then restore the default behavior so a second
+	// signal stops the process immediately.
+	<-ctx.Done()
+	stop()
+	log.Print("shutdown signal caught")
 
 	// Timeout if waiting for connections to return idle.
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Add extra handling here to clean up resources, such as flushing logs and
-	// closing any database or Redis connections.
-
 	// Gracefully shutdown the server by waiting on existing requests (except websockets).
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("server shutdown failed: %+v", err)
 	}
+
+	// Add extra handling here to clean up resources, such as flushing logs and
+	// closing any database or Redis connections, now that no requests are
+	// using them.
 	log.Print("server exited")
 }
 
 // [END cloudrun_sigterm_handler]
 
 func handler(w http.ResponseWriter, r *http.Request) {
+	// The 'delay' parameter is used by tests in main_test.go to keep a
+	// request in flight during shutdown.
+	if d, err := time.ParseDuration(r.URL.Query().Get("delay")); err == nil {
+		time.Sleep(d)
+	}
 	// The 'terminate' parameter is used by tests in sigterm_handler.e2e_test.go
 	if r.URL.Query().Get("terminate") != "" {
 		fmt.Fprint(w, "Goodbye World!\n")
-		signalChan <- syscall.SIGTERM
+		terminate()
 		return
 	}
 	fmt.Fprint(w, "Hello World!\n")
 }
+
+// terminate sends SIGTERM to this process, like Cloud Run does when it stops
+// an instance.
+func terminate() {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(syscall.SIGTERM)
+	}
+	if err != nil {
+		log.Printf("sending SIGTERM: %v", err)
+	}
+}
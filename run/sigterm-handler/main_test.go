@@ -15,8 +15,19 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestHandler(t *testing.T) {
@@ -28,3 +39,161 @@ func TestHandler(t *testing.T) {
 		t.Errorf("got %q, want %q", got, "Hello World!\n")
 	}
 }
+
+// TestMain runs main instead of the tests when the test binary is started
+// by startServer, so the tests can signal a real server process.
+func TestMain(m *testing.M) {
+	if os.Getenv("SIGTERM_HANDLER_RUN_MAIN") == "1" {
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// server is main running in a child process.
+type server struct {
+	url  string
+	cmd  *exec.Cmd
+	out  *syncBuffer
+	done chan error
+}
+
+// startServer runs main in a child process and waits until it serves
+// requests.
+func startServer(t *testing.T) *server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	s := &server{
+		url:  fmt.Sprintf("http://127.0.0.1:%d", port),
+		cmd:  exec.Command(os.Args[0]),
+		out:  &syncBuffer{},
+		done: make(chan error, 1),
+	}
+	s.cmd.Env = append(os.Environ(), "SIGTERM_HANDLER_RUN_MAIN=1", fmt.Sprintf("PORT=%d", port))
+	s.cmd.Stdout = s.out
+	s.cmd.Stderr = s.out
+	if err := s.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { s.done <- s.cmd.Wait() }()
+	t.Cleanup(func() {
+		s.cmd.Process.Kill()
+		t.Logf("server output:\n%s", s.out)
+	})
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		resp, err := http.Get(s.url)
+		if err == nil {
+			resp.Body.Close()
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server not ready: %v", err)
+		}
+	}
+}
+
+// wait waits for the server to exit cleanly.
+func (s *server) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case err := <-s.done:
+		if err != nil {
+			t.Fatalf("server exited with %v, want a clean exit", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("server didn't exit")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s := startServer(t)
+
+	// Start a request that is still in flight when SIGTERM arrives.
+	type result struct {
+		body string
+		err  error
+	}
+	inflight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(s.url + "/?delay=1s")
+		if err != nil {
+			inflight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		inflight <- result{string(b), err}
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+
+	// The in-flight request finishes.
+	if res := <-inflight; res.err != nil || res.body != "Hello World!\n" {
+		t.Errorf("in-flight request = %q, %v; want %q, nil", res.body, res.err, "Hello World!\n")
+	}
+	s.wait(t)
+	checkOrder(t, s.out.String(), "shutdown signal caught", "server exited")
+}
+
+func TestInterrupt(t *testing.T) {
+	s := startServer(t)
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	s.wait(t)
+	checkOrder(t, s.out.String(), "shutdown signal caught", "server exited")
+}
+
+func TestTerminateParameter(t *testing.T) {
+	s := startServer(t)
+	resp, err := http.Get(s.url + "/?terminate=1")
+	if err != nil {
+		t.Fatalf("GET /?terminate=1: %v", err)
+	}
+	resp.Body.Close()
+	s.wait(t)
+	checkOrder(t, s.out.String(), "shutdown signal caught", "server exited")
+}
+
+// checkOrder checks that each of want appears in output, in order.
+func checkOrder(t *testing.T, output string, want ...string) {
+	t.Helper()
+	rest := output
+	for _, w := range want {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Errorf("output doesn't contain %q after the previous lines:\n%s", w, output)
+			return
+		}
+		rest = rest[i+len(w):]
+	}
+}
+
+// syncBuffer is a bytes.Buffer that the child process's output can be
+// written to while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
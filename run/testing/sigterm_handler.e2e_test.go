@@ -63,7 +63,7 @@ func GetLogEntries(service *cloudrunci.Service, t *testing.T) {
 	timeFormat := minsAgo.Format(time.RFC3339)
 	filter := fmt.Sprintf(`timestamp>="%s" severity="default" NOT protoPayload.serviceName="run.googleapis.com"`, timeFormat)
 
-	find := "terminated signal caught"
+	find := "shutdown signal caught"
 	attempts := 6
 	found, err := service.LogEntries(filter, find, attempts)
 	if err != nil || !found {
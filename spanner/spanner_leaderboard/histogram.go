@@ -0,0 +1,164 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// bucketBounds are the upper bounds of the latency buckets, growing by 20%
+// from 100µs to a minute. Quantiles are therefore accurate to within 20%.
+var bucketBounds = func() []time.Duration {
+	var bounds []time.Duration
+	for b := float64(100 * time.Microsecond); b < float64(time.Minute); b *= 1.2 {
+		bounds = append(bounds, time.Duration(b))
+	}
+	return append(bounds, time.Minute)
+}()
+
+// histogram records a latency distribution. It is safe for concurrent use.
+type histogram struct {
+	mu     sync.Mutex
+	counts []int64 // counts[i] is the number of samples <= bucketBounds[i]; the last counts overflows
+	n      int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(bucketBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(bucketBounds), func(i int) bool { return bucketBounds[i] >= d })
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.n++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// quantile returns the upper bound of the bucket containing the q-th
+// quantile, capped at the largest observed value.
+func (h *histogram) quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantileLocked(q)
+}
+
+func (h *histogram) quantileLocked(q float64) time.Duration {
+	if h.n == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			if i < len(bucketBounds) && bucketBounds[i] < h.max {
+				return bucketBounds[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// latencySummary is a point-in-time summary of a histogram, in milliseconds.
+type latencySummary struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"meanMs"`
+	P50   float64 `json:"p50Ms"`
+	P90   float64 `json:"p90Ms"`
+	P99   float64 `json:"p99Ms"`
+	Max   float64 `json:"maxMs"`
+}
+
+func (h *histogram) summary() latencySummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	s := latencySummary{
+		Count: h.n,
+		P50:   ms(h.quantileLocked(0.5)),
+		P90:   ms(h.quantileLocked(0.9)),
+		P99:   ms(h.quantileLocked(0.99)),
+		Max:   ms(h.max),
+	}
+	if h.n > 0 {
+		s.Mean = ms(h.sum / time.Duration(h.n))
+	}
+	return s
+}
+
+// histograms is a set of histograms keyed by operation name.
+type histograms struct {
+	mu sync.Mutex
+	m  map[string]*histogram
+}
+
+func newHistograms() *histograms {
+	return &histograms{m: map[string]*histogram{}}
+}
+
+// get returns the histogram for name, creating it if needed.
+func (hs *histograms) get(name string) *histogram {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	h, ok := hs.m[name]
+	if !ok {
+		h = newHistogram()
+		hs.m[name] = h
+	}
+	return h
+}
+
+func (hs *histograms) summaries() map[string]latencySummary {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	s := make(map[string]latencySummary, len(hs.m))
+	for name, h := range hs.m {
+		s[name] = h.summary()
+	}
+	return s
+}
+
+// writeSummaries prints a table of latency summaries sorted by name.
+func writeSummaries(w io.Writer, hs *histograms) {
+	s := hs.summaries()
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\tcount\tmean ms\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+	for _, name := range names {
+		l := s[name]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", name, l.Count, l.Mean, l.P50, l.P90, l.P99, l.Max)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	if got := h.quantile(0.5); got != 0 {
+		t.Errorf("empty quantile(0.5) = %v, want 0", got)
+	}
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	// Quantiles are bucket upper bounds, so within 20% above the exact value.
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 50 * time.Millisecond},
+		{0.9, 90 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+	} {
+		got := h.quantile(tc.q)
+		if got < tc.want || float64(got) > 1.2*float64(tc.want) {
+			t.Errorf("quantile(%v) = %v, want within 20%% above %v", tc.q, got, tc.want)
+		}
+	}
+	if got := h.quantile(1); got != 100*time.Millisecond {
+		t.Errorf("quantile(1) = %v, want the maximum 100ms", got)
+	}
+
+	s := h.summary()
+	if s.Count != 100 || s.Mean != 50.5 || s.Max != 100 {
+		t.Errorf("summary() = %+v, want count 100, mean 50.5ms, max 100ms", s)
+	}
+}
+
+func TestHistogramOverflow(t *testing.T) {
+	h := newHistogram()
+	h.observe(2 * time.Hour)
+	if got := h.quantile(0.5); got != 2*time.Hour {
+		t.Errorf("quantile(0.5) = %v, want 2h", got)
+	}
+}
+
+func TestWriteSummaries(t *testing.T) {
+	hs := newHistograms()
+	hs.get("top").observe(time.Millisecond)
+	hs.get("submit").observe(time.Millisecond)
+	if hs.get("top") != hs.get("top") {
+		t.Error("get returned different histograms for the same name")
+	}
+
+	var b bytes.Buffer
+	writeSummaries(&b, hs)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("writeSummaries printed %d lines, want header and 2 rows:\n%s", len(lines), b.String())
+	}
+	if !strings.Contains(lines[1], "submit") || !strings.Contains(lines[2], "top") {
+		t.Errorf("writeSummaries rows not sorted by name:\n%s", b.String())
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -30,40 +32,29 @@ import (
 
 	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
 
 	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
-	"google.golang.org/api/iterator"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
 )
 
 type command func(ctx context.Context, w io.Writer, client *spanner.Client) error
-type uniqueRand struct {
-	used map[int64]bool
-	rand *rand.Rand
-}
-
-func newUniqueRand() uniqueRand {
-	return uniqueRand{
-		used: map[int64]bool{},
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-	}
-}
-
-func (r *uniqueRand) rnd(min, max int64) int64 {
-	for {
-		rnd := r.rand.Int63n(max) + min
-		if !r.used[rnd] {
-			r.used[rnd] = true
-			return rnd
-		}
-	}
-}
 
 var (
 	commands = map[string]command{
 		"insertplayers": insertPlayers,
 		"insertscores":  insertScores,
 		"query":         query,
+		"serve":         serve,
+		"loadgen":       loadgen,
 	}
+
+	concurrency  = flag.Int("concurrency", 8, "loadgen: number of concurrent workers")
+	duration     = flag.Duration("duration", 30*time.Second, "loadgen: how long to generate load for")
+	players      = flag.Int("players", 0, "loadgen: number of players to create before generating load")
+	mix          = flag.String("mix", "submit=50,top=20,around=20,player=10", "loadgen: relative weights of operations")
+	loadTimespan = flag.Int("timespan", 0, "loadgen: timespan of ranking queries in hours; 0 ranks all scores")
 )
 
 func createDatabase(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, db string) error {
@@ -71,22 +62,15 @@ func createDatabase(ctx context.Context, w io.Writer, adminClient *database.Data
 	if matches == nil || len(matches) != 3 {
 		return fmt.Errorf("Invalid database id %s", db)
 	}
+	if os.Getenv("SPANNER_EMULATOR_HOST") != "" {
+		if err := createEmulatorInstance(ctx, matches[1]); err != nil {
+			return err
+		}
+	}
 	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
 		Parent:          matches[1],
 		CreateStatement: "CREATE DATABASE `" + matches[2] + "`",
-		ExtraStatements: []string{
-			`CREATE TABLE Players(
-			    PlayerId INT64 NOT NULL,
-			    PlayerName STRING(2048) NOT NULL
-			) PRIMARY KEY(PlayerId)`,
-			`CREATE TABLE Scores(
-			    PlayerId INT64 NOT NULL,
-			    Score INT64 NOT NULL,
-			    Timestamp TIMESTAMP NOT NULL
-			    OPTIONS(allow_commit_timestamp=true)
-			) PRIMARY KEY(PlayerId, Timestamp),
-			INTERLEAVE IN PARENT Players ON DELETE NO ACTION`,
-		},
+		ExtraStatements: schema,
 	})
 	if err != nil {
 		return err
@@ -98,174 +82,132 @@ func createDatabase(ctx context.Context, w io.Writer, adminClient *database.Data
 	return nil
 }
 
-func insertPlayers(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	// Get number of players to use as an incrementing value for each PlayerName to be inserted
-	stmt := spanner.Statement{
-		SQL: `SELECT Count(PlayerId) as PlayerCount FROM Players`,
+// createEmulatorInstance creates the instance on the Spanner emulator if it
+// doesn't already exist. Instances on Cloud Spanner must be created ahead of
+// time.
+func createEmulatorInstance(ctx context.Context, name string) error {
+	matches := regexp.MustCompile("^(projects/[^/]+)/instances/([^/]+)$").FindStringSubmatch(name)
+	if matches == nil {
+		return fmt.Errorf("Invalid instance id %s", name)
 	}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	row, err := iter.Next()
+	client, err := instance.NewInstanceAdminClient(ctx)
 	if err != nil {
 		return err
 	}
-	var numberOfPlayers int64 = 0
-	if err := row.Columns(&numberOfPlayers); err != nil {
+	defer client.Close()
+	if _, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name}); err == nil {
+		return nil
+	} else if spanner.ErrCode(err) != codes.NotFound {
 		return err
 	}
-	// Intialize values for random PlayerId
-	min := int64(1000000000)
-	max := int64(9000000000)
-	rnd := newUniqueRand()
-	// Insert 100 player records into the Players table
-	_, err = client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-		stmts := []spanner.Statement{}
-		for i := 1; i <= 100; i++ {
-			numberOfPlayers++
-			playerID := rnd.rnd(min, max)
-
-			playerName := fmt.Sprintf("Player %d", numberOfPlayers)
-			stmts = append(stmts, spanner.Statement{
-				SQL: `INSERT INTO Players
-						(PlayerId, PlayerName)
-						VALUES (@playerID, @playerName)`,
-				Params: map[string]interface{}{
-					"playerID":   playerID,
-					"playerName": playerName,
-				},
-			})
-		}
-		_, err := txn.BatchUpdate(ctx, stmts)
-		if err != nil {
-			return err
-		}
-		return nil
+	op, err := client.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     matches[1],
+		InstanceId: matches[2],
+		Instance: &instancepb.Instance{
+			Config:      matches[1] + "/instanceConfigs/emulator-config",
+			DisplayName: matches[2],
+			NodeCount:   1,
+		},
 	})
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+func insertPlayers(ctx context.Context, w io.Writer, client *spanner.Client) error {
+	lb := &leaderboard{client: client}
+	// Get number of players to use as an incrementing value for each PlayerName to be inserted
+	numberOfPlayers, err := lb.countPlayers(ctx)
+	if err != nil {
+		return err
+	}
+	// Insert 100 player records into the Players table
+	if _, err := seedPlayers(ctx, lb, numberOfPlayers+1, 100, 1, nil); err != nil {
+		return err
+	}
 	fmt.Fprintf(w, "Inserted players \n")
 	return nil
 }
 
 func insertScores(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	playerRecordsFound := false
-	// Create slice for insert statements
-	stmts := []spanner.Statement{}
+	lb := &leaderboard{client: client}
 	// Select all player records
-	stmt := spanner.Statement{SQL: `SELECT PlayerId FROM Players`}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	// Insert 4 score records into the Scores table for each player in the Players table
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		playerRecordsFound = true
-		var playerID int64
-		if err := row.ColumnByName("PlayerId", &playerID); err != nil {
-			return err
-		}
-		// Intialize values for random score and date
-		rand.Seed(time.Now().UnixNano())
-		min := 1000
-		max := 1000000
-		for i := 0; i < 4; i++ {
-			// Generate random score between 1,000 and 1,000,000
-			score := rand.Intn(max-min) + min
-			// Generate random day within the past two years
-			now := time.Now()
-			endDate := now.Unix()
-			past := now.AddDate(0, -24, 0)
-			startDate := past.Unix()
-			randomDateInSeconds := rand.Int63n(endDate-startDate) + startDate
-			randomDate := time.Unix(randomDateInSeconds, 0)
-			// Add insert statement to stmts slice
-			stmts = append(stmts, spanner.Statement{
-				SQL: `INSERT INTO Scores
-						(PlayerId, Score, Timestamp)
-						VALUES (@playerID, @score, @timestamp)`,
-				Params: map[string]interface{}{
-					"playerID":  playerID,
-					"score":     score,
-					"timestamp": randomDate,
-				},
-			})
-		}
-
+	ids, err := lb.playerIDs(ctx)
+	if err != nil {
+		return err
 	}
-	if !playerRecordsFound {
+	if len(ids) == 0 {
 		fmt.Fprintln(w, "No player records currently exist. First insert players then insert scores.")
-	} else {
-		_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-			// Commit insert statements for all scores to be inserted as a single transaction
-			_, err := txn.BatchUpdate(ctx, stmts)
-			return err
-		})
-		if err != nil {
-			return err
+		return nil
+	}
+	// Insert 4 score records into the Scores table for each player in the Players table,
+	// with random scores between 1,000 and 1,000,000 dated within the past two years
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	now := time.Now()
+	var scores []score
+	for _, id := range ids {
+		for i := 0; i < 4; i++ {
+			scores = append(scores, score{PlayerID: id, Score: randomScore(r), Timestamp: randomPastTime(r, now)})
 		}
-		fmt.Fprintln(w, "Inserted scores")
 	}
+	if err := lb.addScores(ctx, scores); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Inserted scores")
 	return nil
 }
 
 func query(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	stmt := spanner.Statement{
-		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
-		        FROM Players p
-		        JOIN Scores s ON p.PlayerId = s.PlayerId
-		        ORDER BY s.Score DESC LIMIT 10`}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		var playerID, score int64
-		var playerName string
-		var timestamp time.Time
-		if err := row.Columns(&playerID, &playerName, &score, &timestamp); err != nil {
-			return err
-		}
-		fmt.Fprintf(w, "PlayerId: %d  PlayerName: %s  Score: %s  Timestamp: %s\n",
-			playerID, playerName, formatWithCommas(score), timestamp.String()[0:10])
-	}
+	return queryWithTimespan(ctx, w, client, 0)
 }
 
 func queryWithTimespan(ctx context.Context, w io.Writer, client *spanner.Client, timespan int) error {
-	stmt := spanner.Statement{
-		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
-				FROM Players p
-				JOIN Scores s ON p.PlayerId = s.PlayerId 
-				WHERE s.Timestamp > TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL @Timespan HOUR)
-				ORDER BY s.Score DESC LIMIT 10`,
-		Params: map[string]interface{}{"Timespan": timespan},
-	}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		var playerID, score int64
-		var playerName string
-		var timestamp time.Time
-		if err := row.Columns(&playerID, &playerName, &score, &timestamp); err != nil {
-			return err
-		}
+	lb := &leaderboard{client: client}
+	entries, err := lb.top(ctx, 10, time.Duration(timespan)*time.Hour)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
 		fmt.Fprintf(w, "PlayerId: %d  PlayerName: %s  Score: %s  Timestamp: %s\n",
-			playerID, playerName, formatWithCommas(score), timestamp.String()[0:10])
+			e.PlayerID, e.PlayerName, formatWithCommas(e.Score), e.Timestamp.String()[0:10])
+	}
+	return nil
+}
+
+func serve(ctx context.Context, w io.Writer, client *spanner.Client) error {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
 	}
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     newServer(&leaderboard{client: client}).handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	fmt.Fprintf(w, "Listening on port %s\n", port)
+	return srv.ListenAndServe()
+}
+
+func loadgen(ctx context.Context, w io.Writer, client *spanner.Client) error {
+	m, err := parseMix(*mix)
+	if err != nil {
+		return err
+	}
+	res, err := generateLoad(ctx, w, &leaderboard{client: client}, loadConfig{
+		players:     *players,
+		concurrency: *concurrency,
+		duration:    *duration,
+		mix:         m,
+		timespan:    time.Duration(*loadTimespan) * time.Hour,
+		rankingSize: 10,
+	})
+	if err != nil {
+		return err
+	}
+	writeLoadResult(w, res)
+	return nil
 }
 
 func formatWithCommas(n int64) string {
@@ -344,9 +286,10 @@ func run(ctx context.Context, adminClient *database.DatabaseAdminClient, dataCli
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: leaderboard <command> <database_name> [command_option]
+		fmt.Fprintf(os.Stderr, `Usage: leaderboard [flags] <command> <database_name> [command_option]
 
-	Command can be one of: createdatabase, insertplayers, insertscores, query, querywithtimespan
+	Command can be one of: createdatabase, insertplayers, insertscores, query, querywithtimespan,
+	serve, loadgen
 
 Examples:
 	leaderboard createdatabase projects/my-project/instances/my-instance/databases/example-db
@@ -359,7 +302,17 @@ Examples:
 		- Query players with top ten scores of all time.
 	leaderboard querywithtimespan projects/my-project/instances/my-instance/databases/example-db 168
 		- Query players with top ten scores within a timespan specified in hours.
+	leaderboard serve projects/my-project/instances/my-instance/databases/example-db
+		- Serve the ranking API on $PORT (default 8080).
+	leaderboard -players 1000 -concurrency 16 -duration 1m loadgen projects/my-project/instances/my-instance/databases/example-db
+		- Create 1,000 players, then generate load and report latency percentiles.
+
+Set SPANNER_EMULATOR_HOST to run against the Cloud Spanner emulator; createdatabase
+creates the instance on the emulator if needed.
+
+Flags:
 `)
+		flag.PrintDefaults()
 	}
 
 	flag.Parse()
@@ -381,8 +334,13 @@ Examples:
 		timespan = parsedTimespan
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
+	// serve runs until it is stopped and loadgen is bounded by -duration.
+	ctx := context.Background()
+	if cmd != "serve" && cmd != "loadgen" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 1*time.Minute)
+		defer cancel()
+	}
 	adminClient, dataClient := createClients(ctx, db)
 	if err := run(ctx, adminClient, dataClient, os.Stdout, cmd, db, timespan); err != nil {
 		os.Exit(1)
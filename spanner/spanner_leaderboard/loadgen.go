@@ -0,0 +1,260 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadOps are the operations the load generator can issue.
+var loadOps = []string{"submit", "top", "around", "player"}
+
+// playerBatchSize is the number of players inserted per transaction.
+const playerBatchSize = 100
+
+// loadConfig configures a load generator run.
+type loadConfig struct {
+	players     int            // players to create before generating load
+	concurrency int            // concurrent workers
+	duration    time.Duration  // how long to generate load for
+	mix         map[string]int // relative weight of each operation in loadOps
+	timespan    time.Duration  // timespan of ranking queries; zero for all time
+	rankingSize int            // n for top and around queries
+}
+
+// parseMix parses an operation mix such as "submit=50,top=20,around=20,player=10".
+func parseMix(s string) (map[string]int, error) {
+	mix := map[string]int{}
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q: want op=weight", part)
+		}
+		if !validOp(op) {
+			return nil, fmt.Errorf("unknown operation %q: want one of %s", op, strings.Join(loadOps, ", "))
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weight, op)
+		}
+		mix[op] = w
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("mix has no operations with positive weight")
+	}
+	return mix, nil
+}
+
+func validOp(op string) bool {
+	for _, o := range loadOps {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// chooser picks operations at random according to a mix.
+type chooser struct {
+	ops     []string
+	cumul   []int
+	totalWt int
+}
+
+func newChooser(mix map[string]int) *chooser {
+	c := &chooser{}
+	for _, op := range loadOps {
+		if w := mix[op]; w > 0 {
+			c.totalWt += w
+			c.ops = append(c.ops, op)
+			c.cumul = append(c.cumul, c.totalWt)
+		}
+	}
+	return c
+}
+
+func (c *chooser) choose(r *rand.Rand) string {
+	n := r.Intn(c.totalWt)
+	return c.ops[sort.SearchInts(c.cumul, n+1)]
+}
+
+// seedPlayers creates n players named "Player <k>", numbering from first,
+// in batches spread over concurrency workers. It returns the new IDs.
+func seedPlayers(ctx context.Context, lb *leaderboard, first int64, n, concurrency int, latency *histogram) ([]int64, error) {
+	type batch struct{ names []string }
+	batches := make(chan batch)
+	go func() {
+		defer close(batches)
+		for start := 0; start < n; start += playerBatchSize {
+			var b batch
+			for i := start; i < n && i < start+playerBatchSize; i++ {
+				b.names = append(b.names, fmt.Sprintf("Player %d", first+int64(i)))
+			}
+			select {
+			case batches <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		ids      []int64
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				start := time.Now()
+				got, err := lb.addPlayers(ctx, b.names)
+				if latency != nil {
+					latency.observe(time.Since(start))
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				ids = append(ids, got...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return ids, ctx.Err()
+}
+
+// randomScore returns a score between 1,000 and 1,000,000.
+func randomScore(r *rand.Rand) int64 {
+	return 1000 + r.Int63n(999000)
+}
+
+// randomPastTime returns a time within the two years before now.
+func randomPastTime(r *rand.Rand, now time.Time) time.Time {
+	start := now.AddDate(-2, 0, 0)
+	return time.Unix(start.Unix()+r.Int63n(now.Unix()-start.Unix()), 0).UTC()
+}
+
+// loadResult reports the outcome of a load generator run.
+type loadResult struct {
+	latency *histograms
+	ops     int64
+	errors  map[string]int64
+	elapsed time.Duration
+}
+
+// generateLoad creates cfg.players players and then issues a random mix of
+// operations against existing players from cfg.concurrency workers until
+// cfg.duration elapses or ctx is done.
+func generateLoad(ctx context.Context, w io.Writer, lb *leaderboard, cfg loadConfig) (*loadResult, error) {
+	res := &loadResult{latency: newHistograms(), errors: map[string]int64{}}
+	if cfg.players > 0 {
+		existing, err := lb.countPlayers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := seedPlayers(ctx, lb, existing+1, cfg.players, cfg.concurrency, res.latency.get("addplayers"))
+		if err != nil {
+			return nil, fmt.Errorf("seeding players: %w", err)
+		}
+		fmt.Fprintf(w, "Seeded %d players\n", len(ids))
+	}
+	ids, err := lb.playerIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no players: create some with -players")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+	c := newChooser(cfg.mix)
+	var (
+		ops   atomic.Int64
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		begin = time.Now()
+	)
+	for i := 0; i < max(cfg.concurrency, 1); i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for ctx.Err() == nil {
+				op := c.choose(r)
+				id := ids[r.Intn(len(ids))]
+				start := time.Now()
+				var err error
+				switch op {
+				case "submit":
+					_, err = lb.submitScore(ctx, id, randomScore(r))
+				case "top":
+					_, err = lb.top(ctx, cfg.rankingSize, cfg.timespan)
+				case "around":
+					_, err = lb.around(ctx, id, cfg.rankingSize/2, cfg.timespan)
+				case "player":
+					_, err = lb.player(ctx, id)
+				}
+				if ctx.Err() != nil {
+					// Operations cut short by the deadline aren't representative.
+					return
+				}
+				res.latency.get(op).observe(time.Since(start))
+				ops.Add(1)
+				// A player with no scores yet isn't an error for around.
+				if err != nil && !errors.Is(err, errNotFound) {
+					mu.Lock()
+					res.errors[op]++
+					mu.Unlock()
+				}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+	res.ops = ops.Load()
+	res.elapsed = time.Since(begin)
+	return res, nil
+}
+
+// writeLoadResult prints throughput, errors and latency percentiles.
+func writeLoadResult(w io.Writer, res *loadResult) {
+	fmt.Fprintf(w, "Completed %d operations in %s (%.1f ops/s)\n",
+		res.ops, res.elapsed.Round(time.Millisecond), float64(res.ops)/res.elapsed.Seconds())
+	for _, op := range loadOps {
+		if n := res.errors[op]; n > 0 {
+			fmt.Fprintf(w, "%s: %d errors\n", op, n)
+		}
+	}
+	writeSummaries(w, res.latency)
+}
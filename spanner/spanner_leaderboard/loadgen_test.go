@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	got, err := parseMix("submit=5, top=3,around=0")
+	if err != nil {
+		t.Fatalf("parseMix: %v", err)
+	}
+	want := map[string]int{"submit": 5, "top": 3, "around": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMix = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"", "submit", "submit=x", "submit=-1", "delete=1", "top=0,around=0"} {
+		if _, err := parseMix(bad); err == nil {
+			t.Errorf("parseMix(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestChooser(t *testing.T) {
+	c := newChooser(map[string]int{"submit": 3, "top": 1, "player": 0})
+	r := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[c.choose(r)]++
+	}
+	if counts["player"] != 0 || counts["around"] != 0 {
+		t.Errorf("chose operations with zero weight: %v", counts)
+	}
+	if counts["submit"] < 2800 || counts["submit"] > 3200 {
+		t.Errorf("chose submit %d times out of 4000, want about 3000", counts["submit"])
+	}
+}
+
+func TestGenerateLoad(t *testing.T) {
+	lb := newTestLeaderboard(t)
+	var b bytes.Buffer
+	res, err := generateLoad(context.Background(), &b, lb, loadConfig{
+		players:     150,
+		concurrency: 4,
+		duration:    300 * time.Millisecond,
+		mix:         map[string]int{"submit": 2, "top": 1, "around": 1, "player": 1},
+		rankingSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("generateLoad: %v", err)
+	}
+	writeLoadResult(&b, res)
+	t.Log(b.String())
+
+	if !strings.Contains(b.String(), "Seeded 150 players") {
+		t.Errorf("output %q doesn't report seeded players", b.String())
+	}
+	if res.ops == 0 {
+		t.Error("generateLoad completed no operations")
+	}
+	if len(res.errors) > 0 {
+		t.Errorf("generateLoad errors: %v", res.errors)
+	}
+	if n := res.latency.get("addplayers").summary().Count; n != 2 {
+		t.Errorf("recorded %d player batches, want 2", n)
+	}
+	ids, err := lb.playerIDs(context.Background())
+	if err != nil {
+		t.Fatalf("playerIDs: %v", err)
+	}
+	if len(ids) != 150 {
+		t.Errorf("got %d players, want 150", len(ids))
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRankingSize = 10
+	maxRankingSize     = 100
+)
+
+// server exposes a leaderboard over HTTP:
+//
+//	POST /players                  {"playerName": "..."}
+//	GET  /players/{id}
+//	POST /scores                   {"playerId": 123, "score": 4567}
+//	GET  /leaderboard?n=10&timespan=168
+//	GET  /players/{id}/around?n=5&timespan=168
+//	GET  /metrics
+//
+// Timespans are in hours; omitting one ranks all scores.
+type server struct {
+	lb      *leaderboard
+	latency *histograms
+}
+
+func newServer(lb *leaderboard) *server {
+	return &server{lb: lb, latency: newHistograms()}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /players", s.timed("addplayer", s.handleAddPlayer))
+	mux.Handle("GET /players/{id}", s.timed("player", s.handlePlayer))
+	mux.Handle("GET /players/{id}/around", s.timed("around", s.handleAround))
+	mux.Handle("POST /scores", s.timed("submit", s.handleSubmit))
+	mux.Handle("GET /leaderboard", s.timed("top", s.handleTop))
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// timed records the latency of each call to h under name.
+func (s *server) timed(name string, h http.HandlerFunc) http.Handler {
+	hist := s.latency.get(name)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h(w, r)
+		hist.observe(time.Since(start))
+	})
+}
+
+func (s *server) handleAddPlayer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlayerName string `json:"playerName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.PlayerName = strings.TrimSpace(req.PlayerName); req.PlayerName == "" {
+		http.Error(w, "playerName is required", http.StatusBadRequest)
+		return
+	}
+	ids, err := s.lb.addPlayers(r.Context(), []string{req.PlayerName})
+	if err != nil {
+		serverError(w, "addPlayers", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, player{PlayerID: ids[0], PlayerName: req.PlayerName})
+}
+
+func (s *server) handlePlayer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid player id", http.StatusBadRequest)
+		return
+	}
+	p, err := s.lb.player(r.Context(), id)
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "player", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlayerID int64 `json:"playerId"`
+		Score    int64 `json:"score"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.PlayerID == 0 {
+		http.Error(w, "playerId is required", http.StatusBadRequest)
+		return
+	}
+	if req.Score < 0 {
+		http.Error(w, "score must not be negative", http.StatusBadRequest)
+		return
+	}
+	ts, err := s.lb.submitScore(r.Context(), req.PlayerID, req.Score)
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "submitScore", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, score{PlayerID: req.PlayerID, Score: req.Score, Timestamp: ts})
+}
+
+func (s *server) handleTop(w http.ResponseWriter, r *http.Request) {
+	n, timespan, err := rankingParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := s.lb.top(r.Context(), n, timespan)
+	if err != nil {
+		serverError(w, "top", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(entries))
+}
+
+func (s *server) handleAround(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid player id", http.StatusBadRequest)
+		return
+	}
+	n, timespan, err := rankingParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("n") == "" {
+		n = defaultRankingSize / 2
+	}
+	entries, err := s.lb.around(r.Context(), id, n, timespan)
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "player has no scores in timespan", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, "around", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.latency.summaries())
+}
+
+// rankingParams parses the n and timespan query parameters.
+func rankingParams(r *http.Request) (n int, timespan time.Duration, err error) {
+	q := r.URL.Query()
+	n = defaultRankingSize
+	if v := q.Get("n"); v != "" {
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRankingSize {
+			return 0, 0, fmt.Errorf("n must be between 1 and %d", maxRankingSize)
+		}
+	}
+	if v := q.Get("timespan"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 0 {
+			return 0, 0, fmt.Errorf("timespan must be a non-negative number of hours")
+		}
+		timespan = time.Duration(hours) * time.Hour
+	}
+	return n, timespan, nil
+}
+
+func nonNil(entries []entry) []entry {
+	if entries == nil {
+		return []entry{}
+	}
+	return entries
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+func serverError(w http.ResponseWriter, op string, err error) {
+	log.Printf("%s: %v", op, err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
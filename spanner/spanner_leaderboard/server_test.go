@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	h := newServer(newTestLeaderboard(t)).handler()
+
+	do := func(t *testing.T, method, target, body string, wantCode int, v interface{}) {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != wantCode {
+			t.Fatalf("%s %s = %d %q, want %d", method, target, w.Code, w.Body.String(), wantCode)
+		}
+		if v != nil {
+			if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s %s: decoding %q: %v", method, target, w.Body.String(), err)
+			}
+		}
+	}
+
+	var ids []int64
+	for _, name := range []string{"ann", "bob", "cat"} {
+		var p player
+		do(t, "POST", "/players", fmt.Sprintf(`{"playerName": %q}`, name), http.StatusCreated, &p)
+		if p.PlayerID == 0 || p.PlayerName != name {
+			t.Fatalf("POST /players = %+v, want new player %s", p, name)
+		}
+		ids = append(ids, p.PlayerID)
+	}
+	for i, v := range []int64{300, 100, 200} {
+		var s score
+		do(t, "POST", "/scores", fmt.Sprintf(`{"playerId": %d, "score": %d}`, ids[i], v), http.StatusCreated, &s)
+		if s.Score != v || s.Timestamp.IsZero() {
+			t.Errorf("POST /scores = %+v, want score %d with commit timestamp", s, v)
+		}
+	}
+
+	var top []entry
+	do(t, "GET", "/leaderboard?n=2&timespan=1", "", http.StatusOK, &top)
+	if got := strings.Join(entryNames(top), " "); got != "ann cat" {
+		t.Errorf("GET /leaderboard = %q, want %q", got, "ann cat")
+	}
+
+	var around []entry
+	do(t, "GET", fmt.Sprintf("/players/%d/around?n=1", ids[2]), "", http.StatusOK, &around)
+	if got := strings.Join(entryNames(around), " "); got != "ann cat bob" {
+		t.Errorf("GET around = %q, want %q", got, "ann cat bob")
+	}
+	if len(around) == 3 && around[1].Rank != 2 {
+		t.Errorf("GET around ranked cat %d, want 2", around[1].Rank)
+	}
+
+	var p player
+	do(t, "GET", fmt.Sprintf("/players/%d", ids[1]), "", http.StatusOK, &p)
+	if p.PlayerName != "bob" || p.Scores != 1 || p.BestScore != 100 {
+		t.Errorf("GET /players/{id} = %+v, want bob with one score of 100", p)
+	}
+
+	errorTests := []struct {
+		method, target, body string
+		wantCode             int
+	}{
+		{"POST", "/players", `{}`, http.StatusBadRequest},
+		{"POST", "/players", `not json`, http.StatusBadRequest},
+		{"POST", "/scores", `{"score": 5}`, http.StatusBadRequest},
+		{"POST", "/scores", `{"playerId": 1, "score": -5}`, http.StatusBadRequest},
+		{"POST", "/scores", `{"playerId": 1, "score": 5}`, http.StatusNotFound},
+		{"GET", "/leaderboard?n=0", "", http.StatusBadRequest},
+		{"GET", "/leaderboard?n=1000", "", http.StatusBadRequest},
+		{"GET", "/leaderboard?timespan=-1", "", http.StatusBadRequest},
+		{"GET", "/players/abc", "", http.StatusBadRequest},
+		{"GET", "/players/1", "", http.StatusNotFound},
+		{"GET", "/players/1/around", "", http.StatusNotFound},
+		{"DELETE", "/leaderboard", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range errorTests {
+		do(t, tc.method, tc.target, tc.body, tc.wantCode, nil)
+	}
+
+	var metrics map[string]latencySummary
+	do(t, "GET", "/metrics", "", http.StatusOK, &metrics)
+	for op, want := range map[string]int64{"addplayer": 5, "submit": 6, "top": 4, "around": 2, "player": 3} {
+		if got := metrics[op].Count; got != want {
+			t.Errorf("metrics[%q].Count = %d, want %d", op, got, want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// schema is the DDL for the Players and Scores tables. The descending
+// index on Score serves the ranking queries without a full table scan.
+var schema = []string{
+	`CREATE TABLE Players(
+	    PlayerId INT64 NOT NULL,
+	    PlayerName STRING(2048) NOT NULL
+	) PRIMARY KEY(PlayerId)`,
+	`CREATE TABLE Scores(
+	    PlayerId INT64 NOT NULL,
+	    Score INT64 NOT NULL,
+	    Timestamp TIMESTAMP NOT NULL
+	    OPTIONS(allow_commit_timestamp=true)
+	) PRIMARY KEY(PlayerId, Timestamp),
+	INTERLEAVE IN PARENT Players ON DELETE NO ACTION`,
+	`CREATE INDEX ScoresByScore ON Scores(Score DESC)`,
+}
+
+// Player IDs are drawn at random from [minPlayerID, maxPlayerID) so that
+// concurrent writers don't contend on a single hot key range.
+const (
+	minPlayerID = int64(1000000000)
+	maxPlayerID = int64(9000000000)
+)
+
+var errNotFound = errors.New("not found")
+
+// leaderboard implements the ranking operations on top of a Spanner
+// database created with schema.
+type leaderboard struct {
+	client *spanner.Client
+}
+
+// entry is a single score on the leaderboard. Rank is the 1-based position
+// of the score within the queried timespan; ties are broken by the earlier
+// timestamp and then by player ID.
+type entry struct {
+	Rank       int64     `json:"rank"`
+	PlayerID   int64     `json:"playerId"`
+	PlayerName string    `json:"playerName"`
+	Score      int64     `json:"score"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// player summarizes a player's scores.
+type player struct {
+	PlayerID   int64     `json:"playerId"`
+	PlayerName string    `json:"playerName"`
+	Scores     int64     `json:"scores"`
+	BestScore  int64     `json:"bestScore"`
+	LastPlayed time.Time `json:"lastPlayed,omitzero"`
+}
+
+// score is a row of the Scores table.
+type score struct {
+	PlayerID  int64     `json:"playerId"`
+	Score     int64     `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// addPlayers inserts a player for each name in a single transaction and
+// returns the generated IDs. A colliding ID aborts the whole batch, which is
+// retried with fresh IDs.
+func (l *leaderboard) addPlayers(ctx context.Context, names []string) ([]int64, error) {
+	for attempt := 1; ; attempt++ {
+		ids := make([]int64, len(names))
+		ms := make([]*spanner.Mutation, len(names))
+		for i, name := range names {
+			ids[i] = minPlayerID + rand.Int63n(maxPlayerID-minPlayerID)
+			ms[i] = spanner.Insert("Players", []string{"PlayerId", "PlayerName"}, []interface{}{ids[i], name})
+		}
+		_, err := l.client.Apply(ctx, ms)
+		if spanner.ErrCode(err) == codes.AlreadyExists && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ids, nil
+	}
+}
+
+// addScores inserts historical scores with explicit timestamps in a single
+// transaction.
+func (l *leaderboard) addScores(ctx context.Context, scores []score) error {
+	ms := make([]*spanner.Mutation, len(scores))
+	for i, s := range scores {
+		ms[i] = spanner.Insert("Scores", []string{"PlayerId", "Score", "Timestamp"},
+			[]interface{}{s.PlayerID, s.Score, s.Timestamp})
+	}
+	_, err := l.client.Apply(ctx, ms)
+	return err
+}
+
+// submitScore records a new score for playerID at the commit timestamp,
+// which it returns. It returns errNotFound if the player doesn't exist.
+func (l *leaderboard) submitScore(ctx context.Context, playerID, value int64) (time.Time, error) {
+	return l.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		if _, err := txn.ReadRow(ctx, "Players", spanner.Key{playerID}, []string{"PlayerId"}); err != nil {
+			if spanner.ErrCode(err) == codes.NotFound {
+				return errNotFound
+			}
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{
+			spanner.Insert("Scores", []string{"PlayerId", "Score", "Timestamp"},
+				[]interface{}{playerID, value, spanner.CommitTimestamp}),
+		})
+	})
+}
+
+// playerIDs returns the IDs of all players.
+func (l *leaderboard) playerIDs(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	iter := l.client.Single().Query(ctx, spanner.Statement{SQL: `SELECT PlayerId FROM Players`})
+	err := iter.Do(func(row *spanner.Row) error {
+		var id int64
+		if err := row.Columns(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+// countPlayers returns the number of players.
+func (l *leaderboard) countPlayers(ctx context.Context) (int64, error) {
+	iter := l.client.Single().Query(ctx, spanner.Statement{SQL: `SELECT COUNT(PlayerId) FROM Players`})
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = row.Columns(&n)
+	return n, err
+}
+
+// player looks up a player and summarizes their scores. It returns
+// errNotFound if the player doesn't exist.
+func (l *leaderboard) player(ctx context.Context, playerID int64) (*player, error) {
+	txn := l.client.ReadOnlyTransaction()
+	defer txn.Close()
+	row, err := txn.ReadRow(ctx, "Players", spanner.Key{playerID}, []string{"PlayerName"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &player{PlayerID: playerID}
+	if err := row.Columns(&p.PlayerName); err != nil {
+		return nil, err
+	}
+
+	iter := txn.Query(ctx, spanner.Statement{
+		SQL: `SELECT COUNT(Score), MAX(Score), MAX(Timestamp)
+		        FROM Scores
+		        WHERE PlayerId = @id`,
+		Params: map[string]interface{}{"id": playerID},
+	})
+	defer iter.Stop()
+	row, err = iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	var best spanner.NullInt64
+	var last spanner.NullTime
+	if err := row.Columns(&p.Scores, &best, &last); err != nil {
+		return nil, err
+	}
+	p.BestScore, p.LastPlayed = best.Int64, last.Time
+	return p, nil
+}
+
+// windowStart returns the earliest timestamp included in a timespan ending
+// now. A zero timespan covers all time.
+func windowStart(timespan time.Duration) time.Time {
+	if timespan <= 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return time.Now().Add(-timespan).UTC()
+}
+
+// top returns the n highest scores within timespan.
+func (l *leaderboard) top(ctx context.Context, n int, timespan time.Duration) ([]entry, error) {
+	iter := l.client.Single().Query(ctx, spanner.Statement{
+		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		        FROM Scores AS s
+		        JOIN Players AS p ON p.PlayerId = s.PlayerId
+		        WHERE s.Timestamp >= @since
+		        ORDER BY s.Score DESC, s.Timestamp, s.PlayerId
+		        LIMIT @n`,
+		Params: map[string]interface{}{"since": windowStart(timespan), "n": int64(n)},
+	})
+	entries, err := readEntries(iter)
+	for i := range entries {
+		entries[i].Rank = int64(i + 1)
+	}
+	return entries, err
+}
+
+// Conditions selecting scores ranked ahead of and behind the score
+// identified by @score, @ts and @id.
+const (
+	rankedAhead = `(s.Score > @score OR (s.Score = @score AND
+		(s.Timestamp < @ts OR (s.Timestamp = @ts AND s.PlayerId < @id))))`
+	rankedBehind = `(s.Score < @score OR (s.Score = @score AND
+		(s.Timestamp > @ts OR (s.Timestamp = @ts AND s.PlayerId > @id))))`
+)
+
+// around returns the player's best score within timespan together with up
+// to n scores ranked directly ahead of and behind it. It returns errNotFound
+// if the player has no scores in the timespan.
+func (l *leaderboard) around(ctx context.Context, playerID int64, n int, timespan time.Duration) ([]entry, error) {
+	txn := l.client.ReadOnlyTransaction()
+	defer txn.Close()
+	since := windowStart(timespan)
+
+	best, err := readEntries(txn.Query(ctx, spanner.Statement{
+		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		        FROM Scores AS s
+		        JOIN Players AS p ON p.PlayerId = s.PlayerId
+		        WHERE s.PlayerId = @id AND s.Timestamp >= @since
+		        ORDER BY s.Score DESC, s.Timestamp
+		        LIMIT 1`,
+		Params: map[string]interface{}{"id": playerID, "since": since},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if len(best) == 0 {
+		return nil, errNotFound
+	}
+	me := best[0]
+	params := map[string]interface{}{
+		"id":    playerID,
+		"score": me.Score,
+		"ts":    me.Timestamp,
+		"since": since,
+		"n":     int64(n),
+	}
+
+	iter := txn.Query(ctx, spanner.Statement{
+		SQL:    `SELECT COUNT(*) FROM Scores AS s WHERE s.Timestamp >= @since AND ` + rankedAhead,
+		Params: params,
+	})
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	var ahead int64
+	if err := row.Columns(&ahead); err != nil {
+		return nil, err
+	}
+	me.Rank = ahead + 1
+
+	// Scores ahead are read closest first and then reversed.
+	above, err := readEntries(txn.Query(ctx, spanner.Statement{
+		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		        FROM Scores AS s
+		        JOIN Players AS p ON p.PlayerId = s.PlayerId
+		        WHERE s.Timestamp >= @since AND ` + rankedAhead + `
+		        ORDER BY s.Score, s.Timestamp DESC, s.PlayerId DESC
+		        LIMIT @n`,
+		Params: params,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	below, err := readEntries(txn.Query(ctx, spanner.Statement{
+		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		        FROM Scores AS s
+		        JOIN Players AS p ON p.PlayerId = s.PlayerId
+		        WHERE s.Timestamp >= @since AND ` + rankedBehind + `
+		        ORDER BY s.Score DESC, s.Timestamp, s.PlayerId
+		        LIMIT @n`,
+		Params: params,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(above)+1+len(below))
+	for i := len(above) - 1; i >= 0; i-- {
+		above[i].Rank = me.Rank - int64(i+1)
+		entries = append(entries, above[i])
+	}
+	entries = append(entries, me)
+	for i, e := range below {
+		e.Rank = me.Rank + int64(i+1)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readEntries reads rows of (PlayerId, PlayerName, Score, Timestamp).
+func readEntries(iter *spanner.RowIterator) ([]entry, error) {
+	defer iter.Stop()
+	var entries []entry
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e entry
+		if err := row.Columns(&e.PlayerID, &e.PlayerName, &e.Score, &e.Timestamp); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
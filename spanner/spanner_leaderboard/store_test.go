@@ -0,0 +1,218 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"cloud.google.com/go/spanner/spannertest"
+	"cloud.google.com/go/spanner/spansql"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestLeaderboard returns a leaderboard backed by a fresh database on the
+// Spanner emulator if SPANNER_EMULATOR_HOST is set, and by an in-memory fake
+// otherwise.
+func newTestLeaderboard(t *testing.T) *leaderboard {
+	t.Helper()
+	ctx := context.Background()
+
+	if os.Getenv("SPANNER_EMULATOR_HOST") != "" {
+		db := "projects/test-project/instances/test-instance/databases/lb-" + randomID()
+		adminClient, dataClient := createClients(ctx, db)
+		if err := createDatabase(ctx, io.Discard, adminClient, db); err != nil {
+			t.Fatalf("createDatabase: %v", err)
+		}
+		t.Cleanup(func() {
+			dataClient.Close()
+			adminClient.DropDatabase(ctx, &adminpb.DropDatabaseRequest{Database: db})
+			adminClient.Close()
+		})
+		return &leaderboard{client: dataClient}
+	}
+
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("spannertest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	srv.SetLogger(t.Logf)
+	ddl, err := spansql.ParseDDL("schema", strings.Join(schema, ";\n"))
+	if err != nil {
+		t.Fatalf("ParseDDL: %v", err)
+	}
+	if err := srv.UpdateDDL(ddl); err != nil {
+		t.Fatalf("UpdateDDL: %v", err)
+	}
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	client, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("spanner.NewClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return &leaderboard{client: client}
+}
+
+// addTestPlayers creates a player per name and returns their IDs by name.
+func addTestPlayers(t *testing.T, lb *leaderboard, names ...string) map[string]int64 {
+	t.Helper()
+	ids, err := lb.addPlayers(context.Background(), names)
+	if err != nil {
+		t.Fatalf("addPlayers: %v", err)
+	}
+	byName := map[string]int64{}
+	for i, name := range names {
+		byName[name] = ids[i]
+	}
+	return byName
+}
+
+func entryNames(entries []entry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.PlayerName)
+	}
+	return names
+}
+
+func TestRanking(t *testing.T) {
+	ctx := context.Background()
+	lb := newTestLeaderboard(t)
+	ids := addTestPlayers(t, lb, "ann", "bob", "cat", "dan", "eve")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	day := 24 * time.Hour
+	err := lb.addScores(ctx, []score{
+		{PlayerID: ids["ann"], Score: 500, Timestamp: now.Add(-1 * time.Hour)},
+		{PlayerID: ids["bob"], Score: 400, Timestamp: now.Add(-2 * time.Hour)},
+		// cat ties bob but scored later, so ranks behind.
+		{PlayerID: ids["cat"], Score: 400, Timestamp: now.Add(-1 * time.Hour)},
+		{PlayerID: ids["cat"], Score: 100, Timestamp: now.Add(-3 * time.Hour)},
+		{PlayerID: ids["dan"], Score: 300, Timestamp: now.Add(-1 * time.Hour)},
+		// eve's high score is too old for the one day window.
+		{PlayerID: ids["eve"], Score: 900, Timestamp: now.Add(-10 * day)},
+	})
+	if err != nil {
+		t.Fatalf("addScores: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		timespan time.Duration
+		n        int
+		want     string
+	}{
+		{name: "all time", n: 3, want: "eve ann bob"},
+		{name: "one day", timespan: day, n: 10, want: "ann bob cat dan cat"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := lb.top(ctx, tc.n, tc.timespan)
+			if err != nil {
+				t.Fatalf("top: %v", err)
+			}
+			if names := strings.Join(entryNames(got), " "); names != tc.want {
+				t.Errorf("top(%d, %v) = %q, want %q", tc.n, tc.timespan, names, tc.want)
+			}
+			for i, e := range got {
+				if e.Rank != int64(i+1) {
+					t.Errorf("entry %d has rank %d, want %d", i, e.Rank, i+1)
+				}
+			}
+		})
+	}
+
+	t.Run("around", func(t *testing.T) {
+		got, err := lb.around(ctx, ids["cat"], 1, day)
+		if err != nil {
+			t.Fatalf("around: %v", err)
+		}
+		if names := strings.Join(entryNames(got), " "); names != "bob cat dan" {
+			t.Errorf("around(cat) = %q, want %q", names, "bob cat dan")
+		}
+		wantRanks := []int64{2, 3, 4}
+		for i, e := range got {
+			if i < len(wantRanks) && e.Rank != wantRanks[i] {
+				t.Errorf("around(cat)[%d] %s has rank %d, want %d", i, e.PlayerName, e.Rank, wantRanks[i])
+			}
+		}
+		if got[1].Score != 400 {
+			t.Errorf("around(cat) centered on score %d, want best score 400", got[1].Score)
+		}
+	})
+
+	t.Run("around at top", func(t *testing.T) {
+		got, err := lb.around(ctx, ids["eve"], 2, 0)
+		if err != nil {
+			t.Fatalf("around: %v", err)
+		}
+		if names := strings.Join(entryNames(got), " "); names != "eve ann bob" {
+			t.Errorf("around(eve) = %q, want %q", names, "eve ann bob")
+		}
+	})
+
+	t.Run("around outside window", func(t *testing.T) {
+		if _, err := lb.around(ctx, ids["eve"], 2, day); !errors.Is(err, errNotFound) {
+			t.Errorf("around(eve, 1 day) = %v, want errNotFound", err)
+		}
+	})
+}
+
+func TestSubmitScoreAndPlayer(t *testing.T) {
+	ctx := context.Background()
+	lb := newTestLeaderboard(t)
+	ids := addTestPlayers(t, lb, "ann")
+
+	p, err := lb.player(ctx, ids["ann"])
+	if err != nil {
+		t.Fatalf("player: %v", err)
+	}
+	if p.PlayerName != "ann" || p.Scores != 0 || !p.LastPlayed.IsZero() {
+		t.Errorf("player before scoring = %+v, want ann with no scores", p)
+	}
+
+	for _, v := range []int64{250, 750} {
+		if _, err := lb.submitScore(ctx, ids["ann"], v); err != nil {
+			t.Fatalf("submitScore(%d): %v", v, err)
+		}
+	}
+	p, err = lb.player(ctx, ids["ann"])
+	if err != nil {
+		t.Fatalf("player: %v", err)
+	}
+	if p.Scores != 2 || p.BestScore != 750 || p.LastPlayed.IsZero() {
+		t.Errorf("player after scoring = %+v, want 2 scores with best 750", p)
+	}
+
+	if _, err := lb.submitScore(ctx, 42, 100); !errors.Is(err, errNotFound) {
+		t.Errorf("submitScore(unknown player) = %v, want errNotFound", err)
+	}
+	if _, err := lb.player(ctx, 42); !errors.Is(err, errNotFound) {
+		t.Errorf("player(unknown) = %v, want errNotFound", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+)
+
+var databaseNamePattern = regexp.MustCompile("^(projects/[^/]+)/instances/([^/]+)/databases/([^/]+)$")
+
+// setUpEmulator creates the instance and database for db on the emulator at
+// SPANNER_EMULATOR_HOST if they don't exist yet, so that any command can run
+// against a fresh emulator. The database is created with the dialect of cmd
+// unless cmd creates the database itself.
+func setUpEmulator(ctx context.Context, adminClient *database.DatabaseAdminClient, cmd, db string) error {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		return errors.New("-emulator requires SPANNER_EMULATOR_HOST to be set")
+	}
+	matches := databaseNamePattern.FindStringSubmatch(db)
+	if matches == nil {
+		return fmt.Errorf("invalid database id %s", db)
+	}
+	project, instanceID := matches[1], matches[2]
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer instanceAdmin.Close()
+	instanceName := project + "/instances/" + instanceID
+	_, err = instanceAdmin.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: instanceName})
+	if spanner.ErrCode(err) == codes.NotFound {
+		op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+			Parent:     project,
+			InstanceId: instanceID,
+			Instance: &instancepb.Instance{
+				Config:      project + "/instanceConfigs/emulator-config",
+				DisplayName: instanceID,
+				NodeCount:   1,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("creating emulator instance: %w", err)
+		}
+		if _, err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("creating emulator instance: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if cmd == "createdatabase" || cmd == "pgcreatedatabase" {
+		return nil
+	}
+	_, err = adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: db})
+	if spanner.ErrCode(err) != codes.NotFound {
+		return err
+	}
+	create := createDatabase
+	if dialect(cmd) == "POSTGRESQL" {
+		create = pgCreateDatabase
+	}
+	if err := create(ctx, io.Discard, adminClient, db); err != nil {
+		return fmt.Errorf("creating emulator database: %w", err)
+	}
+	return nil
+}
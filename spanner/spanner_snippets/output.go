@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// rowEmitter is implemented by writers that take whole rows, like the
+// -format=json writer. The data snippets hand each row they read to it
+// instead of printing the row's values.
+type rowEmitter interface {
+	emitRow(row *spanner.Row) error
+}
+
+// writeDatabaseRoles writes the database roles of db as rows, like
+// listdatabaseroles prints them.
+func writeDatabaseRoles(ctx context.Context, jw *jsonWriter, adminClient *database.DatabaseAdminClient, db string) error {
+	iter := adminClient.ListDatabaseRoles(ctx, &adminpb.ListDatabaseRolesRequest{Parent: db})
+	for {
+		role, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(role.Name, db+"/databaseRoles/")
+		if err := jw.writeRecord(map[string]string{"DatabaseRole": name}); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonWriter writes the output of a command as JSON lines: rows become
+// objects keyed by column name, and each line of text the command prints
+// becomes {"message": line}. Close must be called to flush a final line
+// without a trailing newline.
+type jsonWriter struct {
+	enc *json.Encoder
+	buf bytes.Buffer
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (j *jsonWriter) Write(p []byte) (int, error) {
+	j.buf.Write(p)
+	for {
+		line, err := j.buf.ReadString('\n')
+		if err != nil {
+			// Keep the partial line until the rest arrives.
+			j.buf.WriteString(line)
+			return len(p), nil
+		}
+		if err := j.writeLine(strings.TrimSuffix(line, "\n")); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+func (j *jsonWriter) writeLine(line string) error {
+	if line == "" {
+		return nil
+	}
+	return j.enc.Encode(map[string]string{"message": line})
+}
+
+// emitRow writes row as an object keyed by column name.
+func (j *jsonWriter) emitRow(row *spanner.Row) error {
+	rec, err := rowRecord(row)
+	if err != nil {
+		return err
+	}
+	return j.enc.Encode(rec)
+}
+
+// rowRecord returns the columns of row keyed by name. INT64, FLOAT64 and
+// BOOL columns become JSON numbers and booleans, NULLs become null, and
+// other columns keep Spanner's JSON encoding, which is a string for STRING,
+// BYTES, TIMESTAMP and the like.
+func rowRecord(row *spanner.Row) (map[string]interface{}, error) {
+	rec := make(map[string]interface{}, row.Size())
+	for i, name := range row.ColumnNames() {
+		var col spanner.GenericColumnValue
+		if err := row.Column(i, &col); err != nil {
+			return nil, err
+		}
+		if _, ok := col.Value.GetKind().(*structpb.Value_NullValue); ok {
+			rec[name] = nil
+			continue
+		}
+		var err error
+		switch col.Type.GetCode() {
+		case sppb.TypeCode_INT64:
+			var v int64
+			err = col.Decode(&v)
+			rec[name] = v
+		case sppb.TypeCode_FLOAT64:
+			var v float64
+			err = col.Decode(&v)
+			rec[name] = v
+		case sppb.TypeCode_BOOL:
+			var v bool
+			err = col.Decode(&v)
+			rec[name] = v
+		default:
+			rec[name] = col.Value.AsInterface()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return rec, nil
+}
+
+// writeError reports a failed command.
+func (j *jsonWriter) writeError(cmd string, err error) error {
+	return j.enc.Encode(map[string]string{"command": cmd, "error": err.Error()})
+}
+
+// writeRecord writes v as a single JSON line.
+func (j *jsonWriter) writeRecord(v interface{}) error {
+	return j.enc.Encode(v)
+}
+
+// Close flushes any buffered partial line.
+func (j *jsonWriter) Close() error {
+	line := j.buf.String()
+	j.buf.Reset()
+	return j.writeLine(line)
+}
+
+// reportError prints that cmd failed in the output's format.
+func reportError(w io.Writer, cmd string, err error) {
+	if jw, ok := w.(*jsonWriter); ok {
+		jw.writeError(cmd, err)
+		return
+	}
+	fmt.Fprintf(w, "%s failed with %v", cmd, err)
+}
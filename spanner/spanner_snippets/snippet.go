@@ -146,6 +146,12 @@ func query(ctx context.Context, w io.Writer, client *spanner.Client) error {
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID, albumID int64
 		var albumTitle string
 		if err := row.Columns(&singerID, &albumID, &albumTitle); err != nil {
@@ -171,6 +177,12 @@ func read(ctx context.Context, w io.Writer, client *spanner.Client) error {
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID, albumID int64
 		var albumTitle string
 		if err := row.Columns(&singerID, &albumID, &albumTitle); err != nil {
@@ -230,6 +242,12 @@ func queryNewColumn(ctx context.Context, w io.Writer, client *spanner.Client) er
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID, albumID int64
 		var marketingBudget spanner.NullInt64
 		if err := row.ColumnByName("SingerId", &singerID); err != nil {
@@ -265,6 +283,12 @@ func readUsingIndex(ctx context.Context, w io.Writer, client *spanner.Client) er
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var albumID int64
 		var albumTitle string
 		if err := row.Columns(&albumID, &albumTitle); err != nil {
@@ -311,6 +335,12 @@ func readStoringIndex(ctx context.Context, w io.Writer, client *spanner.Client)
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var albumID int64
 		var marketingBudget spanner.NullInt64
 		var albumTitle string
@@ -343,6 +373,12 @@ func readOnlyTransaction(ctx context.Context, w io.Writer, client *spanner.Clien
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID int64
 		var albumID int64
 		var albumTitle string
@@ -362,6 +398,12 @@ func readOnlyTransaction(ctx context.Context, w io.Writer, client *spanner.Clien
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID int64
 		var albumID int64
 		var albumTitle string
@@ -417,6 +459,12 @@ func queryWithParameter(ctx context.Context, w io.Writer, client *spanner.Client
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID int64
 		var firstName, lastName string
 		if err := row.Columns(&singerID, &firstName, &lastName); err != nil {
@@ -579,6 +627,12 @@ func pgQueryParameter(ctx context.Context, w io.Writer, client *spanner.Client)
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var val Singers
 		if err := row.ToStruct(&val); err != nil {
 			return err
@@ -616,6 +670,12 @@ func pgQueryNewColumn(ctx context.Context, w io.Writer, client *spanner.Client)
 		if err != nil {
 			return err
 		}
+		if e, ok := w.(rowEmitter); ok {
+			if err := e.emitRow(row); err != nil {
+				return err
+			}
+			continue
+		}
 		var singerID, albumID int64
 		var marketingBudget spanner.NullInt64
 		if err := row.ColumnByName("singerid", &singerID); err != nil {
@@ -815,7 +875,83 @@ func listDatabaseRoles(ctx context.Context, w io.Writer, adminClient *database.D
 	return nil
 }
 
-func run(ctx context.Context, w io.Writer, cmd string, db string, arg string) error {
+// options are the global flags that apply to every command.
+type options struct {
+	format   string // "text" or "json"
+	emulator bool   // create the instance and database on the emulator first
+	dryRun   bool   // describe the command without running it
+}
+
+// dialect returns the database dialect a command expects.
+func dialect(cmd string) string {
+	if strings.HasPrefix(cmd, "pg") {
+		return "POSTGRESQL"
+	}
+	return "GOOGLE_STANDARD_SQL"
+}
+
+// commandKind returns "admin" or "data" for a known command and "" otherwise.
+func commandKind(cmd string) string {
+	if cmd == "enablefinegrainedaccess" || adminCommands[cmd] != nil {
+		return "admin"
+	}
+	if commands[cmd] != nil {
+		return "data"
+	}
+	return ""
+}
+
+// describe prints what run would do for cmd without contacting Spanner.
+func describe(w io.Writer, cmd, db, arg string, opts options) error {
+	if databaseNamePattern.FindStringSubmatch(db) == nil {
+		return fmt.Errorf("invalid database id %s", db)
+	}
+	if cmd == "enablefinegrainedaccess" && arg == "" {
+		return errors.New("IAM member must be specified")
+	}
+	plan := struct {
+		Command  string `json:"command"`
+		Kind     string `json:"kind"`
+		Database string `json:"database"`
+		Dialect  string `json:"dialect"`
+		Emulator string `json:"emulator,omitempty"`
+	}{cmd, commandKind(cmd), db, dialect(cmd), ""}
+	if opts.emulator {
+		plan.Emulator = os.Getenv("SPANNER_EMULATOR_HOST")
+	}
+	if jw, ok := w.(*jsonWriter); ok {
+		return jw.writeRecord(plan)
+	}
+	fmt.Fprintf(w, "Would run %s command %s against %s (%s)\n", plan.Kind, cmd, db, plan.Dialect)
+	if opts.emulator {
+		fmt.Fprintf(w, "Would create the instance and database on the emulator at %s if needed\n", plan.Emulator)
+	}
+	return nil
+}
+
+func run(ctx context.Context, w io.Writer, cmd string, db string, arg string, opts options) (err error) {
+	var jw *jsonWriter
+	if opts.format == "json" {
+		jw = newJSONWriter(w)
+		defer func() {
+			if cerr := jw.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = jw
+	}
+	if commandKind(cmd) == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.dryRun {
+		if err := describe(w, cmd, db, arg, opts); err != nil {
+			reportError(w, cmd, err)
+			return err
+		}
+		return nil
+	}
+
 	var databaseRole string
 	if cmd == "readdatawithdatabaserole" {
 		databaseRole = "parent"
@@ -831,6 +967,13 @@ func run(ctx context.Context, w io.Writer, cmd string, db string, arg string) er
 	}
 	defer adminClient.Close()
 
+	if opts.emulator {
+		if err := setUpEmulator(ctx, adminClient, cmd, db); err != nil {
+			reportError(w, cmd, err)
+			return err
+		}
+	}
+
 	dataClient, err := spanner.NewClientWithConfig(ctx, db, cfg)
 	if err != nil {
 		log.Fatal(err)
@@ -840,7 +983,15 @@ func run(ctx context.Context, w io.Writer, cmd string, db string, arg string) er
 	if cmd == "enablefinegrainedaccess" {
 		err := enableFineGrainedAccess(ctx, w, adminClient, db, arg)
 		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+			reportError(w, cmd, err)
+		}
+		return err
+	}
+
+	if jw != nil && cmd == "listdatabaseroles" {
+		err := writeDatabaseRoles(ctx, jw, adminClient, db)
+		if err != nil {
+			reportError(w, cmd, err)
 		}
 		return err
 	}
@@ -848,27 +999,22 @@ func run(ctx context.Context, w io.Writer, cmd string, db string, arg string) er
 	if adminCmdFn := adminCommands[cmd]; adminCmdFn != nil {
 		err := adminCmdFn(ctx, w, adminClient, db)
 		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+			reportError(w, cmd, err)
 		}
 		return err
 	}
 
 	// Normal mode
-	cmdFn := commands[cmd]
-	if cmdFn == nil {
-		flag.Usage()
-		os.Exit(2)
-	}
-	err = cmdFn(ctx, w, dataClient)
+	err = commands[cmd](ctx, w, dataClient)
 	if err != nil {
-		fmt.Fprintf(w, "%s failed with %v", cmd, err)
+		reportError(w, cmd, err)
 	}
 	return err
 }
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: spanner_snippets <command> <database_name> [iam_member]
+		fmt.Fprintf(os.Stderr, `Usage: spanner_snippets [flags] <command> <database_name> [iam_member]
 
 	Command can be one of: write, read, readdatawithdatabaserole, query, update,
 		querynewcolumn, querywithparameter, dmlwrite, dmlwritetxn, readindex,
@@ -881,11 +1027,20 @@ Examples:
 	spanner_snippets createdatabase projects/my-project/instances/my-instance/databases/example-db
 	spanner_snippets write projects/my-project/instances/my-instance/databases/example-db
 	spanner_snippets enablefinegrainedaccess projects/my-project/instances/my-instance/databases/example-db user:alice@example.com
+	spanner_snippets -format=json query projects/my-project/instances/my-instance/databases/example-db
+	SPANNER_EMULATOR_HOST=localhost:9010 spanner_snippets -emulator read projects/test-project/instances/test-instance/databases/example-db
+
+Flags:
 `)
+		flag.PrintDefaults()
 	}
 
+	var opts options
+	flag.StringVar(&opts.format, "format", "text", "output format: text or json (one JSON object per row or message)")
+	flag.BoolVar(&opts.emulator, "emulator", false, "create the instance and database on SPANNER_EMULATOR_HOST if they don't exist")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "print what the command would do without running it")
 	flag.Parse()
-	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || (opts.format != "text" && opts.format != "json") {
 		flag.Usage()
 		os.Exit(2)
 	}
@@ -893,7 +1048,7 @@ Examples:
 	cmd, db, arg := flag.Arg(0), flag.Arg(1), flag.Arg(2)
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
-	if err := run(ctx, os.Stdout, cmd, db, arg); err != nil {
+	if err := run(ctx, os.Stdout, cmd, db, arg, opts); err != nil {
 		os.Exit(1)
 	}
 }
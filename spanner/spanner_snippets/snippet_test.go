@@ -0,0 +1,276 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/google/uuid"
+)
+
+func decodeLines(t *testing.T, out string) []map[string]interface{} {
+	t.Helper()
+	var recs []map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decoding %q: %v", out, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestJSONWriter(t *testing.T) {
+	// Output written in pieces and without a final newline.
+	const output = "4 record(s) inserted.\nMoved 200000 from Album2's MarketingBudget to Album1's."
+	want := `{"message":"4 record(s) inserted."}{"message":"Moved 200000 from Album2's MarketingBudget to Album1's."}`
+
+	var b bytes.Buffer
+	jw := newJSONWriter(&b)
+	// Split writes mid-line to check lines are reassembled.
+	mid := len(output) / 2
+	fmt.Fprint(jw, output[:mid])
+	fmt.Fprint(jw, output[mid:])
+	if err := jw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := strings.ReplaceAll(b.String(), "\n", ""); got != want {
+		t.Errorf("JSON output = %s, want %s", got, want)
+	}
+}
+
+func TestWriteRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		values  []interface{}
+		want    string
+	}{
+		{
+			name:    "title with spaces",
+			columns: []string{"AlbumId", "AlbumTitle", "MarketingBudget"},
+			values:  []interface{}{int64(2), "Forever Hold Your Peace", int64(300000)},
+			want:    `{"AlbumId":2,"AlbumTitle":"Forever Hold Your Peace","MarketingBudget":300000}`,
+		},
+		{
+			name:    "numeric title",
+			columns: []string{"SingerId", "AlbumId", "AlbumTitle"},
+			values:  []interface{}{int64(1), int64(3), "1989"},
+			want:    `{"AlbumId":3,"AlbumTitle":"1989","SingerId":1}`,
+		},
+		{
+			name:    "NULL budget",
+			columns: []string{"SingerId", "AlbumId", "MarketingBudget"},
+			values:  []interface{}{int64(1), int64(2), spanner.NullInt64{}},
+			want:    `{"AlbumId":2,"MarketingBudget":null,"SingerId":1}`,
+		},
+		{
+			name:    "names with spaces",
+			columns: []string{"SingerId", "FirstName", "LastName"},
+			values:  []interface{}{int64(12), "Mary Ann", "Garcia Lopez"},
+			want:    `{"FirstName":"Mary Ann","LastName":"Garcia Lopez","SingerId":12}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			row, err := spanner.NewRow(tc.columns, tc.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := newJSONWriter(&b).emitRow(row); err != nil {
+				t.Fatalf("emitRow: %v", err)
+			}
+			if got := strings.TrimSpace(b.String()); got != tc.want {
+				t.Errorf("emitRow = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	var b bytes.Buffer
+	jw := newJSONWriter(&b)
+	reportError(jw, "query", errors.New("boom"))
+	recs := decodeLines(t, b.String())
+	if len(recs) != 1 || recs[0]["error"] != "boom" || recs[0]["command"] != "query" {
+		t.Errorf("reportError wrote %q, want a single error record", b.String())
+	}
+
+	b.Reset()
+	reportError(&b, "query", errors.New("boom"))
+	if got, want := b.String(), "query failed with boom"; got != want {
+		t.Errorf("reportError wrote %q, want %q", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	const db = "projects/p/instances/i/databases/d"
+	t.Setenv("SPANNER_EMULATOR_HOST", "localhost:9010")
+	ctx := context.Background()
+
+	var b bytes.Buffer
+	if err := run(ctx, &b, "pgqueryparameter", db, "", options{format: "text", dryRun: true, emulator: true}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, want := range []string{"data command pgqueryparameter", "POSTGRESQL", "localhost:9010"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("dry run output %q doesn't contain %q", b.String(), want)
+		}
+	}
+
+	b.Reset()
+	if err := run(ctx, &b, "addnewcolumn", db, "", options{format: "json", dryRun: true}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	recs := decodeLines(t, b.String())
+	if len(recs) != 1 || recs[0]["kind"] != "admin" || recs[0]["dialect"] != "GOOGLE_STANDARD_SQL" || recs[0]["database"] != db {
+		t.Errorf("JSON dry run = %s, want one admin GoogleSQL plan for %s", b.String(), db)
+	}
+
+	b.Reset()
+	if err := run(ctx, &b, "read", "not-a-database", "", options{format: "text", dryRun: true}); err == nil {
+		t.Errorf("dry run with invalid database succeeded: %q", b.String())
+	}
+}
+
+// emulatorStep is a command in an emulator test sequence and the output it
+// must produce, or a DDL statement setting up the schema for the next
+// commands.
+type emulatorStep struct {
+	cmd  string
+	want []string
+	ddl  string
+}
+
+// TestEmulator runs every command against the Spanner emulator, in an order
+// where earlier commands set up the schema and data later ones depend on.
+// Start the emulator with:
+//
+//	gcloud emulators spanner start
+//	export SPANNER_EMULATOR_HOST=localhost:9010
+func TestEmulator(t *testing.T) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set SPANNER_EMULATOR_HOST.")
+	}
+
+	googleSQL := []emulatorStep{
+		{cmd: "createdatabase", want: []string{"Created database"}},
+		{cmd: "write"},
+		{cmd: "read", want: []string{"1 1 Total Junk", "2 3 Terrified"}},
+		{cmd: "query", want: []string{"1 2 Go, Go, Go"}},
+		{cmd: "readonlytransaction", want: []string{"2 1 Green"}},
+		{cmd: "addnewcolumn", want: []string{"Added MarketingBudget column"}},
+		{cmd: "update"},
+		{cmd: "querynewcolumn", want: []string{"1 1 100000", "2 2 500000", "1 2 NULL"}},
+		{cmd: "dmlwritetxn", want: []string{"Moved 200000 from Album2's MarketingBudget to Album1's."}},
+		{cmd: "querynewcolumn", want: []string{"1 1 300000", "2 2 300000"}},
+		{cmd: "dmlwrite", want: []string{"4 record(s) inserted."}},
+		{cmd: "querywithparameter", want: []string{"12 Melissa Garcia"}},
+		{ddl: "CREATE INDEX AlbumsByAlbumTitle ON Albums(AlbumTitle)"},
+		{cmd: "readindex", want: []string{"2 Forever Hold Your Peace"}},
+		{cmd: "addstoringindex", want: []string{"Added storing index"}},
+		{cmd: "readstoringindex", want: []string{"2 Forever Hold Your Peace 300000"}},
+	}
+	postgreSQL := []emulatorStep{
+		{cmd: "pgcreatedatabase", want: []string{"Created database"}},
+		{cmd: "write"},
+		{cmd: "read", want: []string{"1 1 Total Junk"}},
+		{cmd: "pgaddnewcolumn", want: []string{"Added MarketingBudget column"}},
+		{cmd: "update"},
+		{cmd: "pgquerynewcolumn", want: []string{"1 1 100000", "2 2 500000"}},
+		{cmd: "pgdmlwritetxn", want: []string{"Moved 200000 from Album2's MarketingBudget to Album1's."}},
+		{cmd: "pgquerynewcolumn", want: []string{"1 1 300000", "2 2 300000"}},
+		{cmd: "pgdmlwrite", want: []string{"4 record(s) inserted."}},
+		{cmd: "pgqueryparameter", want: []string{"12 Melissa Garcia"}},
+		{cmd: "pgaddstoringindex", want: []string{"Added storing index"}},
+		{cmd: "readstoringindex", want: []string{"2 Forever Hold Your Peace 300000"}},
+	}
+	// The emulator doesn't implement IAM or fine-grained access control, so
+	// addanddropdatabaserole, listdatabaseroles, readdatawithdatabaserole and
+	// enablefinegrainedaccess are only covered by the Cloud Spanner tests.
+
+	for name, steps := range map[string][]emulatorStep{"googlesql": googleSQL, "postgresql": postgreSQL} {
+		t.Run(name, func(t *testing.T) {
+			db := fmt.Sprintf("projects/emulator-project/instances/test-instance/databases/%s-%s", name[:2], uuid.New().String()[:8])
+			for _, step := range steps {
+				if step.ddl != "" {
+					updateDDL(t, db, step.ddl)
+					continue
+				}
+				var b bytes.Buffer
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				err := run(ctx, &b, step.cmd, db, "", options{format: "text", emulator: true})
+				cancel()
+				if err != nil {
+					t.Fatalf("%s: %v\n%s", step.cmd, err, b.String())
+				}
+				for _, want := range step.want {
+					if !strings.Contains(b.String(), want) {
+						t.Errorf("%s output %q doesn't contain %q", step.cmd, b.String(), want)
+					}
+				}
+			}
+
+			// Rows read back as JSON carry typed columns.
+			var b bytes.Buffer
+			if err := run(context.Background(), &b, "read", db, "", options{format: "json", emulator: true}); err != nil {
+				t.Fatalf("read -format=json: %v", err)
+			}
+			recs := decodeLines(t, b.String())
+			if len(recs) != 5 {
+				t.Fatalf("read -format=json returned %d rows, want 5:\n%s", len(recs), b.String())
+			}
+			if recs[0]["SingerId"] != float64(1) || recs[0]["AlbumTitle"] != "Total Junk" {
+				t.Errorf("first JSON row = %v, want singer 1's Total Junk", recs[0])
+			}
+		})
+	}
+}
+
+// updateDDL applies a DDL statement to db.
+func updateDDL(t *testing.T, db, statement string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adminClient.Close()
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: []string{statement},
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", statement, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		t.Fatalf("%s: %v", statement, err)
+	}
+}